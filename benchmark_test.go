@@ -0,0 +1,102 @@
+package tinytoml
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// benchConfig mirrors the ServerConfig of examples/default_config
+type benchConfig struct {
+	Server struct {
+		Host string `toml:"host"`
+		Port int64  `toml:"port"`
+		Name string `toml:"name"`
+		Mode string `toml:"mode"`
+	} `toml:"server"`
+	TLS struct {
+		Enabled  bool   `toml:"enabled"`
+		CertFile string `toml:"cert_file"`
+		KeyFile  string `toml:"key_file"`
+	} `toml:"tls"`
+	Database struct {
+		Host     string `toml:"host"`
+		Port     int64  `toml:"port"`
+		Name     string `toml:"name"`
+		User     string `toml:"user"`
+		Password string `toml:"password"`
+		Pool     struct {
+			MaxOpen int64 `toml:"max_open"`
+			MaxIdle int64 `toml:"max_idle"`
+		} `toml:"pool"`
+	} `toml:"database"`
+	API struct {
+		Prefix      string   `toml:"prefix"`
+		Timeout     int64    `toml:"timeout"`
+		RateLimit   int64    `toml:"rate_limit"`
+		CorsOrigins []string `toml:"cors_origins"`
+	} `toml:"api"`
+}
+
+// loadBenchConfig reads the example configuration used by the benchmarks
+func loadBenchConfig(b *testing.B) []byte {
+	data, err := os.ReadFile("examples/default_config/config.toml")
+	if err != nil {
+		b.Fatalf("failed to read benchmark config: %v", err)
+	}
+	return data
+}
+
+func Benchmark_Unmarshal(b *testing.B) {
+	data := loadBenchConfig(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var config benchConfig
+		if err := Unmarshal(data, &config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_UnmarshalMap(b *testing.B) {
+	data := loadBenchConfig(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var config map[string]any
+		if err := Unmarshal(data, &config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Marshal(b *testing.B) {
+	var config benchConfig
+	if err := Unmarshal(loadBenchConfig(b), &config); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_MarshalFlatMap(b *testing.B) {
+	config := map[string]string{}
+	for i := 0; i < 20; i++ {
+		config[fmt.Sprintf("key_%02d", i)] = fmt.Sprintf("value %d", i)
+	}
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
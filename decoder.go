@@ -0,0 +1,524 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Decoder reads and decodes a TOML document from an input stream.
+// Hooks registered on a Decoder apply to every Decode call.
+type Decoder struct {
+	r               io.Reader
+	hooks           []mapstructure.DecodeHookFunc
+	pathHooks       []PathHookFunc
+	boolAliases     bool
+	boolFormat      BoolFormat
+	runeStrings     bool
+	partial         bool
+	noTabIndent     bool
+	noMixedIndent   bool
+	noLeadingZeros  bool
+	noDuplicateKeys bool
+	noUnknownKeys   bool
+	noMixedArrays   bool
+	unknownEscapes  bool
+	maxDepth        int
+	maxInputSize    int
+	maxLineLength   int
+	maxKeys         int
+	foldBlank       bool
+	arrayZeroFill   bool
+	arrayTruncate   bool
+	unixUnit        time.Duration
+	unixErr         error // invalid unit passed to AllowUnixTimestamps, returned by Decode
+	jsonTags        bool
+	keyFunc         func(string) string
+	normalizedKeys  bool
+	allowNull       bool
+	useNumber       bool
+	indexTables     bool
+	complexArrays   bool
+	separator       string
+	br              *bufio.Reader // buffers the input between documents when a separator is set
+	collectErrors   bool
+	collectWarnings bool
+	warnings        []Warning
+	blankKeys       map[string]bool
+	commentPrefixes []string
+	order           [][]string     // key paths in input order, recorded while decoding into an OrderedMap
+	rawText         bool           // tables keep their source sections, for targets with ",raw" fields
+	keyLines        map[string]int // line of each key path, recorded while re-parsing after a failed decode
+	lenient         bool           // values that fail to decode become zero values, set by ValidateAgainst
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// RegisterHook adds a custom conversion applied while decoding into the target.
+// Hooks run in registration order, each receiving the output of the previous one,
+// and are composed into the mapstructure DecodeHook.
+func (d *Decoder) RegisterHook(hook mapstructure.DecodeHookFunc) {
+	d.hooks = append(d.hooks, hook)
+}
+
+// PathHookFunc transforms the value stored under a key before it is decoded.
+// path is the dotted key path from the document root (server.data_dir), with
+// segments that are not bare keys quoted as in a table header and elements of
+// arrays of tables given by index (mounts[0].dir). The returned value replaces
+// the parsed one.
+type PathHookFunc func(path string, value any) (any, error)
+
+// RegisterPathHook adds a transform called for every key holding a value, after
+// the document is parsed and before it is decoded into the target. Unlike
+// RegisterHook, it knows the key it converts, for path-specific rules such as
+// expanding environment variables only in keys ending in .dir. Tables are not
+// passed, only the values inside them. Path hooks run in registration order.
+func (d *Decoder) RegisterPathHook(hook PathHookFunc) {
+	d.pathHooks = append(d.pathHooks, hook)
+}
+
+// applyPathHooks runs the path hooks over every value of a parsed table found
+// at prefix, in sorted key order, replacing the values in place; the tables of
+// arrays of tables are walked element by element
+func (d *Decoder) applyPathHooks(m map[string]any, prefix string) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if key != rawKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := joinPath(prefix, key)
+		if table, ok := m[key].(map[string]any); ok {
+			if err := d.applyPathHooks(table, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if elems, ok := tableArray(m[key]); ok {
+			for i, elem := range elems {
+				if err := d.applyPathHooks(elem.(map[string]any), fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value := m[key]
+		for _, hook := range d.pathHooks {
+			var err error
+			if value, err = hook(name, value); err != nil {
+				return errorf(err, "key", name)
+			}
+		}
+		m[key] = value
+	}
+	return nil
+}
+
+// RegisterConverter registers convert for every decode target of type T, a typed
+// alternative to RegisterHook for users who do not want to write mapstructure hooks.
+// It receives values parsed as S: string, int64, float64, bool, time.Time or []any,
+// or []byte to receive the bytes of a string value. Values of any other type are
+// passed on unchanged, so they fail or convert as they would without the converter.
+// Converters run in registration order together with hooks added by RegisterHook.
+func RegisterConverter[S, T any](d *Decoder, convert func(S) (T, error)) {
+	target := reflect.TypeFor[T]()
+	d.RegisterHook(func(from, to reflect.Type, data any) (any, error) {
+		if to != target {
+			return data, nil
+		}
+
+		src, ok := data.(S)
+		if !ok {
+			// A []byte source reads string values
+			s, isString := data.(string)
+			if !isString {
+				return data, nil
+			}
+			if src, ok = any([]byte(s)).(S); !ok {
+				return data, nil
+			}
+		}
+
+		result, err := convert(src)
+		if err != nil {
+			return nil, errorf(err, "convert", fmt.Sprint(data), "type", target.String())
+		}
+		return result, nil
+	})
+}
+
+// AllowBoolAliases makes the decoder accept yes/no and on/off (in any case)
+// as booleans in bool fields, both as bare values and as quoted strings. Other
+// targets, such as string fields or map[string]any, receive the word as a string.
+// Standard TOML only allows true and false, which remains the default.
+func (d *Decoder) AllowBoolAliases() {
+	d.boolAliases = true
+}
+
+// SetBoolFormat makes the decoder also accept the booleans written by an Encoder
+// with the same format: bare True and False for BoolTitle, and the integers
+// 1 and 0 decoded into bool fields for BoolNumeric. Untyped targets such as
+// map[string]any keep 1 and 0 as integers. true and false are always accepted.
+func (d *Decoder) SetBoolFormat(format BoolFormat) {
+	d.boolFormat = format
+}
+
+// AllowRuneStrings lets single-character strings decode into int32 (rune) targets
+// as their code point, so sep = "," fills a rune field with ','.
+// Strings of any other length are rejected for int32 targets.
+func (d *Decoder) AllowRuneStrings() {
+	d.runeStrings = true
+}
+
+// AllowPartialResult makes a failed decode still store everything parsed before
+// the failing line into the target, for inspecting a partially valid config.
+// The error is returned as usual; the target is best-effort and incomplete.
+func (d *Decoder) AllowPartialResult() {
+	d.partial = true
+}
+
+// DisallowTabIndent makes the decoder reject lines whose leading whitespace
+// contains a tab, enforcing a spaces-only indentation policy. Tabs after the
+// indentation, such as inside strings or between tokens, are still allowed.
+func (d *Decoder) DisallowTabIndent() {
+	d.noTabIndent = true
+}
+
+// DisallowMixedIndent makes the decoder reject continuation lines of a
+// multi-line array whose indentation mixes tabs and spaces, either within one
+// line or across the lines of the same array, as a formatting check for teams
+// enforcing a consistent style. Parsing itself ignores indentation either way.
+func (d *Decoder) DisallowMixedIndent() {
+	d.noMixedIndent = true
+}
+
+// DisallowLeadingZeros makes the decoder reject decimal integers with leading
+// zeros (port = 042), in values and arrays alike, as standard TOML does. By
+// default they parse as decimal (42), which can hide a zip code or version
+// that was meant as a string. A lone 0 and 0x, 0o and 0b integers are allowed.
+func (d *Decoder) DisallowLeadingZeros() {
+	d.noLeadingZeros = true
+}
+
+// DisallowDuplicateKeys makes the decoder reject a key assigned twice in the
+// same table and a table header repeated, as standard TOML does. By default the
+// last value wins and repeated headers merge into one table. Appending with +=
+// is not a duplicate.
+func (d *Decoder) DisallowDuplicateKeys() {
+	d.noDuplicateKeys = true
+}
+
+// DisallowUnknownKeys makes the decoder reject keys that match no field of a
+// struct target, catching typos such as hots = "localhost". Keys are matched
+// as decoding matches them, including inside arrays of tables, and tables held
+// by map or interface fields accept any content. Map targets are not checked.
+func (d *Decoder) DisallowUnknownKeys() {
+	d.noUnknownKeys = true
+}
+
+// DisallowMixedArrays makes the decoder reject arrays whose elements have
+// different types (vals = [1, "a"]), as TOML before 1.0 did. Integers and
+// floats are different types here; nested arrays count as one type whatever
+// their content. The check runs while parsing, so it applies to any and map
+// targets as well, and to the arrays joined by +=.
+func (d *Decoder) DisallowMixedArrays() {
+	d.noMixedArrays = true
+}
+
+// SetKeyFunc names struct fields that have no explicit key in their tag by
+// fn(field name), so a struct decodes from snake_case keys without tagging
+// every field: SetKeyFunc(SnakeCase) fills MaxConns from max_conns. SnakeCase,
+// KebabCase and LowerCase are provided; use the same function as the Encoder
+// that wrote the document. A nil fn restores matching by field name.
+func (d *Decoder) SetKeyFunc(fn func(fieldName string) string) {
+	d.keyFunc = fn
+}
+
+// AllowNull makes the decoder accept a bare null as a value (port = null), for
+// three-valued configs and override layers that clear a base value. A null key
+// is present but unset: it decodes into a pointer or interface field as nil and
+// into a map field as a nil map, replacing what the target held, and is stored
+// as a nil value in maps. Other fields cannot represent it and fail to decode.
+// null is not TOML, so it is rejected by default, and arrays cannot hold it.
+func (d *Decoder) AllowNull() {
+	d.allowNull = true
+}
+
+// UseNumber makes the decoder store integers and floats as Number, the literal
+// text they were written with, in map[string]any and any targets, so a value
+// such as rate = 3.140 marshals back unchanged instead of as 3.14. Struct fields
+// and other typed targets still receive the parsed number. Path hooks and hooks
+// added with RegisterHook run before that conversion and receive Number values.
+func (d *Decoder) UseNumber() {
+	d.useNumber = true
+}
+
+// AllowIndexTables makes the decoder read tables whose keys are all array
+// indices, such as [items.0] and [items.1] or items.0 = "a", as arrays when
+// they decode into a slice or array: the elements are assembled in index
+// order, as [[items]] blocks would be. Tables decoded into maps and any keep
+// their keys, and hooks added with RegisterHook still receive tables. The
+// indices must run from 0 without gaps or leading zeros. This is a
+// non-standard layout found in configs migrated from other formats; it does
+// not apply to OrderedMap targets.
+func (d *Decoder) AllowIndexTables() {
+	d.indexTables = true
+}
+
+// AllowComplexArrays makes the decoder fill complex64 and complex128 struct
+// fields, and slices and arrays of them, from two-element number arrays
+// [real, imag], as written by an Encoder with SetComplexArrays. Complex values
+// held by maps are not supported.
+func (d *Decoder) AllowComplexArrays() {
+	d.complexArrays = true
+}
+
+// AllowNormalizedKeys makes struct fields match keys that equal their name or
+// tag ignoring case, underscores and hyphens, so an untagged MaxOpen field
+// decodes from max_open, max-open or maxopen without a key function. An exact
+// match is still preferred; among several normalized matches, which one wins
+// is unspecified. By default a key must match ignoring case only.
+func (d *Decoder) AllowNormalizedKeys() {
+	d.normalizedKeys = true
+}
+
+// CollectErrors makes the decoder keep parsing after a line fails, so one
+// Decode reports every line-level error in the document as a *ValidationError,
+// in line order, for a "check my whole file" workflow. Lines after an error are
+// parsed in the table that was open before it, so one mistake can cause
+// follow-up errors. The target is not decoded unless AllowPartialResult is set.
+// Exceeding the key count limit still stops parsing.
+func (d *Decoder) CollectErrors() {
+	d.collectErrors = true
+}
+
+// CollectWarnings makes the decoder record constructs that are accepted but
+// suspicious, such as a key assigned twice (the last value wins), a repeated
+// table header, leading zeros in integers or unknown escapes kept literally.
+// Read them with Warnings after Decode; they never make Decode fail.
+func (d *Decoder) CollectWarnings() {
+	d.collectWarnings = true
+}
+
+// Warnings returns the warnings recorded by the last Decode, in input order,
+// when CollectWarnings is set. Warnings found before a failing line are kept.
+func (d *Decoder) Warnings() []Warning {
+	return d.warnings
+}
+
+// warn records a warning when CollectWarnings is set
+func (d *Decoder) warn(line int, code, format string, args ...any) {
+	if d.collectWarnings {
+		d.warnings = append(d.warnings, Warning{Line: line, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// AllowUnknownEscapes makes the decoder keep unknown escape sequences in
+// strings as a literal backslash and character, so path = "C:\Users" reads
+// as C:\Users instead of failing. Known escapes (\t, \n, \r, \", \\) are
+// still resolved, so "C:\new" still contains a newline; doubling backslashes
+// remains the portable form.
+func (d *Decoder) AllowUnknownEscapes() {
+	d.unknownEscapes = true
+}
+
+// FoldBlankStrings makes the decoder treat whitespace-only strings (name = "   ")
+// as empty, for sources that write blanks to mean unset. With keys, only values
+// stored under those key names, in any table, are folded; without keys, every
+// string is. Strings are kept verbatim unless enabled.
+func (d *Decoder) FoldBlankStrings(keys ...string) {
+	d.foldBlank = true
+	for _, key := range keys {
+		if d.blankKeys == nil {
+			d.blankKeys = make(map[string]bool)
+		}
+		d.blankKeys[key] = true
+	}
+}
+
+// AllowCommentPrefixes makes the decoder treat each prefix (such as "//" or ";")
+// as the start of a comment in addition to #, for near-TOML files from other
+// ecosystems. As with #, a prefix inside a quoted string is kept as text, so
+// url = "http://host" is unaffected. Empty prefixes are ignored. Only # starts
+// a comment by default.
+func (d *Decoder) AllowCommentPrefixes(prefixes ...string) {
+	for _, prefix := range prefixes {
+		if prefix != "" {
+			d.commentPrefixes = append(d.commentPrefixes, prefix)
+		}
+	}
+}
+
+// AllowUnixTimestamps lets integers decode into time.Time targets as Unix
+// timestamps counted in unit: time.Second reads created = 1700000000, and
+// time.Millisecond, time.Microsecond or time.Nanosecond read finer timestamps.
+// Times are returned in UTC. RFC 3339 date-times are still accepted; without
+// this option an integer cannot decode into a time.Time. A unit of 0 disables it.
+// The unit must divide a second evenly or be a whole number of seconds; any other
+// unit, such as 1500ms, makes Decode fail.
+func (d *Decoder) AllowUnixTimestamps(unit time.Duration) {
+	d.unixUnit, d.unixErr = 0, nil
+	if unit != 0 && !validUnixUnit(unit) {
+		d.unixErr = errorf(fmt.Errorf(errInvalidUnit), unit.String())
+		return
+	}
+	d.unixUnit = unit
+}
+
+// AllowJSONTags makes struct fields that have no toml tag decode from the key
+// named by their json tag (json:"max_conns"), then by their field name, the
+// way many Go config libraries resolve names for structs shared with
+// encoding/json. A toml tag still takes precedence and json:"-" skips the field.
+func (d *Decoder) AllowJSONTags() {
+	d.jsonTags = true
+}
+
+// AllowArrayZeroFill lets a TOML array shorter than a fixed-size Go array target
+// decode by zero-filling the remaining elements, so point = [1, 2] fills a [4]int
+// with [1 2 0 0]. By default the lengths must match exactly.
+func (d *Decoder) AllowArrayZeroFill() {
+	d.arrayZeroFill = true
+}
+
+// AllowArrayTruncation lets a TOML array longer than a fixed-size Go array target
+// decode by dropping the extra elements. By default the lengths must match exactly.
+func (d *Decoder) AllowArrayTruncation() {
+	d.arrayTruncate = true
+}
+
+// defaultMaxDepth bounds table and array nesting when no limit is set
+const defaultMaxDepth = 100
+
+// SetMaxDepth limits how deeply tables (path segments, e.g. [a.b.c] is 3) and
+// arrays ([[1]] is 2) may nest, so untrusted input such as thousands of "["
+// fails with an error instead of exhausting the stack while decoding.
+// The default is 100; a depth below 1 restores it.
+func (d *Decoder) SetMaxDepth(depth int) {
+	d.maxDepth = depth
+}
+
+// SetMaxInputSize limits the size of a document in bytes. Decode stops reading
+// once the limit is passed, so an oversized upload is never held in memory.
+// A size below 1 removes the limit, which is the default.
+func (d *Decoder) SetMaxInputSize(size int) {
+	d.maxInputSize = size
+}
+
+// SetMaxLineLength limits the length of each line in bytes, checked before the
+// line is tokenized. Multi-line arrays and strings are also checked once joined
+// into one logical line. A length below 1 removes the limit, which is the default.
+func (d *Decoder) SetMaxLineLength(length int) {
+	d.maxLineLength = length
+}
+
+// SetMaxKeys limits the number of key-value pairs and table headers in a
+// document. A count below 1 removes the limit, which is the default.
+func (d *Decoder) SetMaxKeys(count int) {
+	d.maxKeys = count
+}
+
+// depthLimit returns the nesting limit in effect
+func (d *Decoder) depthLimit() int {
+	if d.maxDepth < 1 {
+		return defaultMaxDepth
+	}
+	return d.maxDepth
+}
+
+// Decode reads the TOML document from the input and stores the result in v.
+// The target follows the same rules as Unmarshal.
+// With SetDocumentSeparator, each call reads and decodes the next document of
+// the stream instead, returning io.EOF once no documents are left.
+func (d *Decoder) Decode(v any) error {
+	if d.unixErr != nil {
+		return errorf(d.unixErr)
+	}
+	if d.separator != "" {
+		data, err := d.nextDocument()
+		if err != nil {
+			return err
+		}
+		return d.unmarshal(data, v)
+	}
+
+	r := d.r
+	if d.maxInputSize > 0 {
+		// One byte past the limit is enough to tell the input is too large
+		r = io.LimitReader(r, int64(d.maxInputSize)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return errorf(err)
+	}
+	return d.unmarshal(data, v)
+}
+
+// SetDocumentSeparator splits the input into several documents at every line
+// that is exactly sep, ignoring surrounding whitespace (e.g. "---"), so each
+// Decode call reads and decodes only the next document into its target. Once
+// the stream is exhausted, Decode returns io.EOF. Documents are read one at a
+// time, and the input size limit and line numbers in errors apply per document.
+// A separator line inside a multi-line string still splits the document.
+// Blank documents, such as after a leading, doubled or trailing separator, are
+// skipped rather than decoded as empty.
+func (d *Decoder) SetDocumentSeparator(sep string) {
+	d.separator = strings.TrimSpace(sep)
+}
+
+// nextDocument reads the input up to the next separator line or the end of the
+// stream, returning io.EOF when nothing is left to read
+// Blank documents, before a leading separator or between two in a row, are skipped
+func (d *Decoder) nextDocument() ([]byte, error) {
+	if d.br == nil {
+		d.br = bufio.NewReader(d.r)
+	}
+
+	var doc, line []byte
+	for {
+		// ReadSlice returns at most a buffer at a time, so a line without a
+		// newline cannot grow past the size limit before it is checked
+		chunk, err := d.br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if d.maxInputSize > 0 && len(doc)+len(line) > d.maxInputSize {
+			return nil, errorf(fmt.Errorf(errInputTooLarge), fmt.Sprintf("max %d bytes", d.maxInputSize))
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		if string(bytes.TrimSpace(line)) == d.separator {
+			if len(bytes.TrimSpace(doc)) > 0 {
+				return doc, nil
+			}
+			doc = doc[:0]
+		} else {
+			doc = append(doc, line...)
+		}
+		line = line[:0]
+
+		if err == io.EOF {
+			if len(bytes.TrimSpace(doc)) == 0 {
+				return nil, io.EOF
+			}
+			return doc, nil
+		}
+		if err != nil {
+			return nil, errorf(err)
+		}
+	}
+}
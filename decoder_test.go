@@ -0,0 +1,1876 @@
+package tinytoml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestDecoder_RegisterHook(t *testing.T) {
+	type Config struct {
+		Timeout time.Duration `toml:"timeout"`
+		Mode    string        `toml:"mode"`
+	}
+
+	upperHook := func(from, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || to.Kind() != reflect.String {
+			return data, nil
+		}
+		return strings.ToUpper(data.(string)), nil
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		hooks    []mapstructure.DecodeHookFunc
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "no hooks",
+			input:    `mode = "debug"`,
+			hooks:    nil,
+			expected: Config{Mode: "debug"},
+			wantErr:  false,
+		},
+		{
+			name: "duration hook",
+			input: `timeout = "1m30s"
+mode = "debug"`,
+			hooks:    []mapstructure.DecodeHookFunc{mapstructure.StringToTimeDurationHookFunc()},
+			expected: Config{Timeout: 90 * time.Second, Mode: "debug"},
+			wantErr:  false,
+		},
+		{
+			name: "composed hooks",
+			input: `timeout = "5s"
+mode = "debug"`,
+			hooks:    []mapstructure.DecodeHookFunc{mapstructure.StringToTimeDurationHookFunc(), upperHook},
+			expected: Config{Timeout: 5 * time.Second, Mode: "DEBUG"},
+			wantErr:  false,
+		},
+		{
+			name:     "duration without hook",
+			input:    `timeout = "5s"`,
+			hooks:    nil,
+			wantErr:  true,
+			errormsg: "timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			for _, hook := range tt.hooks {
+				dec.RegisterHook(hook)
+			}
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decode() error = nil, wantErr %v", tt.wantErr)
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRegisterConverter(t *testing.T) {
+	type Level int
+	type Secret struct{ value string }
+	type Config struct {
+		Level  Level    `toml:"level"`
+		Levels []Level  `toml:"levels"`
+		Token  Secret   `toml:"token"`
+		Name   string   `toml:"name"`
+		Limit  int      `toml:"limit"`
+		Extras []string `toml:"extras"`
+	}
+
+	levels := map[string]Level{"debug": 0, "info": 1, "warn": 2}
+	parseLevel := func(s string) (Level, error) {
+		level, ok := levels[s]
+		if !ok {
+			return 0, fmt.Errorf("unknown level %q", s)
+		}
+		return level, nil
+	}
+	parseSecret := func(b []byte) (Secret, error) {
+		return Secret{value: string(bytes.TrimPrefix(b, []byte("env:")))}, nil
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name: "typed targets",
+			input: `level = "warn"
+levels = ["debug", "info"]
+token = "env:API_TOKEN"
+name = "warn"
+limit = 3`,
+			expected: Config{Level: 2, Levels: []Level{0, 1}, Token: Secret{value: "API_TOKEN"}, Name: "warn", Limit: 3},
+			wantErr:  false,
+		},
+		{
+			name:     "other source types pass through",
+			input:    `level = 1`,
+			expected: Config{Level: 1},
+			wantErr:  false,
+		},
+		{
+			name:     "converter error",
+			input:    `level = "loud"`,
+			wantErr:  true,
+			errormsg: `unknown level "loud" [convert, loud, type, `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			RegisterConverter(dec, parseLevel)
+			RegisterConverter(dec, parseSecret)
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_RegisterPathHook(t *testing.T) {
+	type Mount struct {
+		Dir string `toml:"dir"`
+	}
+	type Config struct {
+		Name    string   `toml:"name"`
+		DataDir string   `toml:"server.data_dir"`
+		LogDir  string   `toml:"log.dir"`
+		Dirs    []string `toml:"dirs"`
+		Port    int      `toml:"server.port"`
+		Mounts  []Mount  `toml:"mounts"`
+	}
+
+	// Expands $HOME only in keys ending in dir, and records every path seen
+	var seen []string
+	expandDirs := func(path string, value any) (any, error) {
+		seen = append(seen, path)
+		s, ok := value.(string)
+		if !ok || !strings.HasSuffix(path, "dir") {
+			return value, nil
+		}
+		if strings.Contains(s, "$UNSET") {
+			return nil, fmt.Errorf("unset variable in %q", s)
+		}
+		return strings.ReplaceAll(s, "$HOME", "/home/app"), nil
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		paths    []string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name: "path-specific transform",
+			input: `name = "$HOME"
+dirs = ["$HOME"]
+[server]
+data_dir = "$HOME/data"
+port = 8080
+[log]
+dir = "$HOME/log"`,
+			expected: Config{Name: "$HOME", DataDir: "/home/app/data", LogDir: "/home/app/log", Dirs: []string{"$HOME"}, Port: 8080},
+			paths:    []string{"dirs", "log.dir", "name", "server.data_dir", "server.port"},
+		},
+		{
+			name:     "arrays of tables element by element",
+			input:    "[[mounts]]\ndir = \"$HOME/a\"\n[[mounts]]\ndir = \"$HOME/b\"",
+			expected: Config{Mounts: []Mount{{Dir: "/home/app/a"}, {Dir: "/home/app/b"}}},
+			paths:    []string{"mounts[0].dir", "mounts[1].dir"},
+		},
+		{
+			name:     "hook error names the key",
+			input:    "[log]\ndir = \"$UNSET/log\"",
+			wantErr:  true,
+			errormsg: "unset variable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			dec := NewDecoder(strings.NewReader(tt.input))
+			dec.RegisterPathHook(expandDirs)
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) || !strings.Contains(err.Error(), "log.dir") {
+					t.Errorf("Decode() error = %v, want error containing %v and the key", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+			if !reflect.DeepEqual(seen, tt.paths) {
+				t.Errorf("path hook saw %v, want %v", seen, tt.paths)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowBoolAliases(t *testing.T) {
+	type Config struct {
+		Debug   bool   `toml:"debug"`
+		Verbose bool   `toml:"verbose"`
+		Cache   bool   `toml:"cache"`
+		Mode    string `toml:"mode"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		aliases  bool
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "bare aliases",
+			input:    "debug = yes\nverbose = Off\ncache = on",
+			aliases:  true,
+			expected: Config{Debug: true, Verbose: false, Cache: true},
+			wantErr:  false,
+		},
+		{
+			name:     "quoted aliases into bool fields",
+			input:    "debug = \"YES\"\nverbose = \"no\"\nmode = \"on\"",
+			aliases:  true,
+			expected: Config{Debug: true, Verbose: false, Mode: "on"},
+			wantErr:  false,
+		},
+		{
+			name:     "bare alias into string field",
+			input:    "debug = yes\nmode = on",
+			aliases:  true,
+			expected: Config{Debug: true, Mode: "on"},
+			wantErr:  false,
+		},
+		{
+			name:     "standard booleans still accepted",
+			input:    "debug = true\ncache = false",
+			aliases:  true,
+			expected: Config{Debug: true},
+			wantErr:  false,
+		},
+		{
+			name:     "unknown word",
+			input:    "debug = maybe",
+			aliases:  true,
+			wantErr:  true,
+			errormsg: errInvalidValue,
+		},
+		{
+			name:     "strict by default",
+			input:    "debug = yes",
+			aliases:  false,
+			wantErr:  true,
+			errormsg: errInvalidValue,
+		},
+		{
+			name:     "quoted alias strict by default",
+			input:    `debug = "yes"`,
+			aliases:  false,
+			wantErr:  true,
+			errormsg: "debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.aliases {
+				dec.AllowBoolAliases()
+			}
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decode() error = nil, wantErr %v", tt.wantErr)
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// Untyped targets keep the word, only bool targets convert it
+	dec := NewDecoder(strings.NewReader("debug = yes"))
+	dec.AllowBoolAliases()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if m["debug"] != "yes" {
+		t.Errorf("Decode() = %v, want debug = \"yes\"", m)
+	}
+}
+
+func TestDecoder_AllowRuneStrings(t *testing.T) {
+	type Config struct {
+		Sep   rune   `toml:"sep"`
+		Marks []rune `toml:"marks"`
+		Name  string `toml:"name"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		allow    bool
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "single characters",
+			input:    "sep = \",\"\nmarks = [\"é\", \"✓\"]\nname = \"x\"",
+			allow:    true,
+			expected: Config{Sep: ',', Marks: []rune{'é', '✓'}, Name: "x"},
+			wantErr:  false,
+		},
+		{
+			name:     "replacement character",
+			input:    "sep = \"\uFFFD\"",
+			allow:    true,
+			expected: Config{Sep: '\uFFFD'},
+			wantErr:  false,
+		},
+		{
+			name:     "invalid UTF-8",
+			input:    "sep = \"\xff\"",
+			allow:    true,
+			wantErr:  true,
+			errormsg: errInvalidRune,
+		},
+		{
+			name:     "integers still accepted",
+			input:    "sep = 44",
+			allow:    true,
+			expected: Config{Sep: ','},
+			wantErr:  false,
+		},
+		{
+			name:     "multiple characters",
+			input:    `sep = ", "`,
+			allow:    true,
+			wantErr:  true,
+			errormsg: errInvalidRune,
+		},
+		{
+			name:     "empty string",
+			input:    `sep = ""`,
+			allow:    true,
+			wantErr:  true,
+			errormsg: errInvalidRune,
+		},
+		{
+			name:     "strings rejected by default",
+			input:    `sep = ","`,
+			allow:    false,
+			wantErr:  true,
+			errormsg: "sep",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.allow {
+				dec.AllowRuneStrings()
+			}
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decode() error = nil, wantErr %v", tt.wantErr)
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowPartialResult(t *testing.T) {
+	type Config struct {
+		Name   string `toml:"name"`
+		Port   int64  `toml:"port"`
+		Server struct {
+			Host string `toml:"host"`
+			Mode string `toml:"mode"`
+		} `toml:"server"`
+	}
+
+	input := `name = "app"
+
+[server]
+host = "localhost"
+mode = unquoted
+port = 8080`
+
+	tests := []struct {
+		name     string
+		partial  bool
+		expected Config
+	}{
+		{
+			name:    "partial result",
+			partial: true,
+			expected: func() Config {
+				var c Config
+				c.Name = "app"
+				c.Server.Host = "localhost"
+				return c
+			}(),
+		},
+		{
+			name:     "untouched by default",
+			partial:  false,
+			expected: Config{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(input))
+			if tt.partial {
+				dec.AllowPartialResult()
+			}
+
+			var got Config
+			err := dec.Decode(&got)
+			if err == nil || !strings.Contains(err.Error(), "line 5") {
+				t.Errorf("Decode() error = %v, want error containing line 5", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_DisallowTabIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		disallow bool
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "tabs allowed by default",
+			input:    "[server]\n\thost = \"localhost\"",
+			disallow: false,
+			wantErr:  false,
+		},
+		{
+			name:     "space indentation",
+			input:    "[server]\n    host = \"local\thost\"\n\t\nport\t= 80",
+			disallow: true,
+			wantErr:  false,
+		},
+		{
+			name:     "tab indented key",
+			input:    "[server]\n\thost = \"localhost\"",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errTabIndent + " [line 2]",
+		},
+		{
+			name:     "mixed indentation",
+			input:    "name = \"app\"\n  \tport = 80",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errTabIndent + " [line 2]",
+		},
+		{
+			name:     "tab indented array element",
+			input:    "ports = [\n    80,\n\t443,\n]",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errTabIndent + " [line 3]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.disallow {
+				dec.DisallowTabIndent()
+			}
+
+			var got map[string]any
+			err := dec.Decode(&got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errormsg) {
+				t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+			}
+		})
+	}
+}
+
+func TestDecoder_DisallowMixedIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		disallow bool
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "mixed allowed by default",
+			input:    "ports = [\n    80,\n\t443,\n]",
+			disallow: false,
+			wantErr:  false,
+		},
+		{
+			name:     "consistent tabs",
+			input:    "ports = [\n\t80,\n\t\t443,\n\n]",
+			disallow: true,
+			wantErr:  false,
+		},
+		{
+			name:     "consistent spaces with blank line",
+			input:    "ports = [\n  80,\n \t\n    443,\n]",
+			disallow: true,
+			wantErr:  false,
+		},
+		{
+			name:     "each array checked on its own",
+			input:    "a = [\n  1,\n]\nb = [\n\t2,\n]",
+			disallow: true,
+			wantErr:  false,
+		},
+		{
+			name:     "tabs and spaces in one line",
+			input:    "ports = [\n  \t80,\n]",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errMixedIndent + " [tabs and spaces] [line 2]",
+		},
+		{
+			name:     "tabs after spaces",
+			input:    "ports = [\n    80,\n\t443,\n]",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errMixedIndent + " [indent tabs, array indent spaces] [line 3]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.disallow {
+				dec.DisallowMixedIndent()
+			}
+
+			var got map[string]any
+			err := dec.Decode(&got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errormsg) {
+				t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+			}
+		})
+	}
+}
+
+func TestDecoder_DisallowLeadingZeros(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		disallow bool
+		expected map[string]any
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "leading zeros parse by default",
+			input:    "zip = 02139\nids = [007, 8]",
+			disallow: false,
+			expected: map[string]any{"zip": int64(2139), "ids": []any{int64(7), int64(8)}},
+		},
+		{
+			name:     "zero and prefixed integers allowed",
+			input:    "a = 0\nb = -0\nc = 0x2A\nd = [0, +0, 0o17, 0b101]\ne = 0.5",
+			disallow: true,
+			expected: map[string]any{
+				"a": int64(0), "b": int64(0), "c": int64(42),
+				"d": []any{int64(0), int64(0), int64(15), int64(5)},
+				"e": 0.5,
+			},
+		},
+		{
+			name:     "leading zero value",
+			input:    "zip = 02139",
+			disallow: true,
+			wantErr:  true,
+			errormsg: errInvalidInteger + " [leading zero, 02139]",
+		},
+		{
+			name:     "signed leading zero",
+			input:    "offset = -00",
+			disallow: true,
+			wantErr:  true,
+			errormsg: "[leading zero, -00]",
+		},
+		{
+			name:     "leading zero array element",
+			input:    "ids = [8, 007]",
+			disallow: true,
+			wantErr:  true,
+			errormsg: "[leading zero, 007]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.disallow {
+				dec.DisallowLeadingZeros()
+			}
+
+			var got map[string]any
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_CollectWarnings(t *testing.T) {
+	input := `port = 8080
+port = 9090
+ids = [1, 007]
+[server]
+path = "C:\Temp"
+[server]
+zip = 02139
+`
+	expected := []Warning{
+		{Line: 2, Code: WarnDuplicateKey, Message: "key 'port' is defined again, the last value wins"},
+		{Line: 3, Code: WarnLeadingZero, Message: "leading zero in 007, read as a decimal integer"},
+		{Line: 5, Code: WarnUnknownEscape, Message: "unknown escape sequence kept as a literal backslash"},
+		{Line: 6, Code: WarnDuplicateTable, Message: "table [server] is defined again and merged with the earlier one"},
+		{Line: 7, Code: WarnLeadingZero, Message: "leading zero in 02139, read as a decimal integer"},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.AllowUnknownEscapes()
+	dec.CollectWarnings()
+	var got map[string]any
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(dec.Warnings(), expected) {
+		t.Errorf("Warnings() = %v, want %v", dec.Warnings(), expected)
+	}
+	if got["port"] != int64(9090) {
+		t.Errorf("Decode() port = %v, want 9090", got["port"])
+	}
+	if want := "line 2: duplicate-key: key 'port' is defined again, the last value wins"; dec.Warnings()[0].String() != want {
+		t.Errorf("Warning.String() = %q, want %q", dec.Warnings()[0].String(), want)
+	}
+
+	// Nothing is recorded unless enabled
+	dec = NewDecoder(strings.NewReader("a = 1\na = 2"))
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if warnings := dec.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() = %v, want none", warnings)
+	}
+}
+
+func TestDecoder_FoldBlankStrings(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host"`
+		Name string `toml:"name"`
+	}
+	type Config struct {
+		Name   string   `toml:"name"`
+		Note   string   `toml:"note"`
+		Tags   []string `toml:"tags"`
+		Server Server   `toml:"server"`
+	}
+
+	input := `name = "   "
+note = "\t"
+tags = [" ", "a"]
+
+[server]
+host = "  "
+name = " x "`
+
+	tests := []struct {
+		name     string
+		fold     bool
+		keys     []string
+		expected Config
+	}{
+		{
+			name:     "verbatim by default",
+			fold:     false,
+			expected: Config{Name: "   ", Note: "\t", Tags: []string{" ", "a"}, Server: Server{Host: "  ", Name: " x "}},
+		},
+		{
+			name:     "all strings",
+			fold:     true,
+			expected: Config{Name: "", Note: "", Tags: []string{"", "a"}, Server: Server{Host: "", Name: " x "}},
+		},
+		{
+			name:     "listed keys in any table",
+			fold:     true,
+			keys:     []string{"name", "host"},
+			expected: Config{Name: "", Note: "\t", Tags: []string{" ", "a"}, Server: Server{Host: "", Name: " x "}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(input))
+			if tt.fold {
+				dec.FoldBlankStrings(tt.keys...)
+			}
+
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowUnknownEscapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		allow    bool
+		expected map[string]any
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "windows path",
+			input:    `path = "C:\Users\App"`,
+			allow:    true,
+			expected: map[string]any{"path": `C:\Users\App`},
+			wantErr:  false,
+		},
+		{
+			name:     "known escapes still resolved",
+			input:    `msg = "a\tb\\c\d"`,
+			allow:    true,
+			expected: map[string]any{"msg": "a\tb\\c\\d"},
+			wantErr:  false,
+		},
+		{
+			name:     "array elements",
+			input:    `paths = ["C:\Temp", "D:\Data"] # drives`,
+			allow:    true,
+			expected: map[string]any{"paths": []any{`C:\Temp`, `D:\Data`}},
+			wantErr:  false,
+		},
+		{
+			name:     "error by default with hint",
+			input:    `path = "C:\Users"`,
+			allow:    false,
+			wantErr:  true,
+			errormsg: errInvalidEscape + ` \U: write a literal backslash as \\`,
+		},
+		{
+			name:     "error by default in arrays",
+			input:    `paths = ["C:\Temp"]`,
+			allow:    false,
+			wantErr:  true,
+			errormsg: errInvalidEscape + ` \T`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.allow {
+				dec.AllowUnknownEscapes()
+			}
+
+			var got map[string]any
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowCommentPrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		prefixes []string
+		expected map[string]any
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "full-line and inline comments",
+			input:    "// generated\n; legacy\nport = 8080 // http\nhost = \"a\" ; primary\n# still a comment",
+			prefixes: []string{"//", ";"},
+			expected: map[string]any{"port": int64(8080), "host": "a"},
+		},
+		{
+			name:     "prefixes inside strings are kept",
+			input:    `url = "http://host;x" // remote`,
+			prefixes: []string{"//", ";"},
+			expected: map[string]any{"url": "http://host;x"},
+		},
+		{
+			name:     "multi-line arrays and tables",
+			input:    "[server] // main\nports = [ // list\n  80, ; web\n  443,\n]",
+			prefixes: []string{"//", ";"},
+			expected: map[string]any{"server": map[string]any{"ports": []any{int64(80), int64(443)}}},
+		},
+		{
+			name:     "empty prefix ignored",
+			input:    "port = 1",
+			prefixes: []string{""},
+			expected: map[string]any{"port": int64(1)},
+		},
+		{
+			name:     "error by default",
+			input:    "port = 8080 // http",
+			wantErr:  true,
+			errormsg: errUnexpectedToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.prefixes != nil {
+				dec.AllowCommentPrefixes(tt.prefixes...)
+			}
+
+			var got map[string]any
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowUnixTimestamps(t *testing.T) {
+	type Config struct {
+		Created time.Time   `toml:"created"`
+		Events  []time.Time `toml:"events"`
+		Count   int64       `toml:"count"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		unit     time.Duration
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "seconds",
+			input:    "created = 1700000000\ncount = 5",
+			unit:     time.Second,
+			expected: Config{Created: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), Count: 5},
+		},
+		{
+			name:     "milliseconds in arrays",
+			input:    "events = [1700000000123, -1500]",
+			unit:     time.Millisecond,
+			expected: Config{Events: []time.Time{time.Date(2023, 11, 14, 22, 13, 20, 123000000, time.UTC), time.Date(1969, 12, 31, 23, 59, 58, 500000000, time.UTC)}},
+		},
+		{
+			name:     "date-times still accepted",
+			input:    "created = 2023-11-14T22:13:20Z",
+			unit:     time.Second,
+			expected: Config{Created: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)},
+		},
+		{
+			name:     "integers rejected by default",
+			input:    "created = 1700000000",
+			wantErr:  true,
+			errormsg: "created",
+		},
+		{
+			name:     "whole seconds unit",
+			input:    "created = 2",
+			unit:     time.Hour,
+			expected: Config{Created: time.Date(1970, 1, 1, 2, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "unit not dividing a second",
+			input:    "created = 1",
+			unit:     1500 * time.Millisecond,
+			wantErr:  true,
+			errormsg: errInvalidUnit + " [1.5s]",
+		},
+		{
+			name:     "negative unit",
+			input:    "created = 1",
+			unit:     -time.Second,
+			wantErr:  true,
+			errormsg: errInvalidUnit,
+		},
+		{
+			name:     "overflow",
+			input:    "created = 9223372036854775807",
+			unit:     time.Hour,
+			wantErr:  true,
+			errormsg: errIntegerOverflow + " [timestamp, 9223372036854775807, unit, 1h0m0s]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			dec.AllowUnixTimestamps(tt.unit)
+
+			var got Config
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowJSONTags(t *testing.T) {
+	type Pool struct {
+		MaxConns int64 `json:"max_conns,omitempty"`
+	}
+	type Config struct {
+		Name    string `json:"name"`
+		Port    int64  `toml:"listen_port" json:"port"`
+		Secret  string `json:"-"`
+		Enabled bool
+		Pool    Pool `json:"database_pool"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		allow    bool
+		expected Config
+	}{
+		{
+			name:     "json names",
+			input:    "name = \"app\"\nlisten_port = 80\nenabled = true\n[database_pool]\nmax_conns = 5",
+			allow:    true,
+			expected: Config{Name: "app", Port: 80, Enabled: true, Pool: Pool{MaxConns: 5}},
+		},
+		{
+			name:     "toml tag takes precedence",
+			input:    "port = 80",
+			allow:    true,
+			expected: Config{},
+		},
+		{
+			name:     "field names no longer match tagged fields",
+			input:    "Secret = \"x\"\n[pool]\nMaxConns = 5",
+			allow:    true,
+			expected: Config{},
+		},
+		{
+			name:     "field names by default",
+			input:    "name = \"app\"\nSecret = \"x\"\n[database_pool]\nmax_conns = 5",
+			allow:    false,
+			expected: Config{Name: "app", Secret: "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.allow {
+				dec.AllowJSONTags()
+			}
+
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_SetKeyFunc(t *testing.T) {
+	type Pool struct {
+		MaxConns int64
+	}
+	type Config struct {
+		ServerName string
+		ListenPort int64  `toml:"port"`
+		DataDir    string `toml:",section=storage"`
+		Tags       []string
+		DBPool     Pool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		keyFunc  func(string) string
+		expected Config
+	}{
+		{
+			name:    "snake case",
+			input:   "server_name = \"app\"\nport = 80\ntags = [\"a\"]\n[storage]\ndata_dir = \"/var\"\n[db_pool]\nmax_conns = 5",
+			keyFunc: SnakeCase,
+			expected: Config{
+				ServerName: "app", ListenPort: 80, DataDir: "/var", Tags: []string{"a"},
+				DBPool: Pool{MaxConns: 5},
+			},
+		},
+		{
+			name:     "kebab case",
+			input:    "server-name = \"app\"\n[db-pool]\nmax-conns = 5",
+			keyFunc:  KebabCase,
+			expected: Config{ServerName: "app", DBPool: Pool{MaxConns: 5}},
+		},
+		{
+			name:     "field names no longer match",
+			input:    "ServerName = \"app\"\n[storage]\nDataDir = \"/var\"",
+			keyFunc:  SnakeCase,
+			expected: Config{},
+		},
+		{
+			name:     "field names by default",
+			input:    "ServerName = \"app\"\nserver_name = \"other\"",
+			keyFunc:  nil,
+			expected: Config{ServerName: "app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			dec.SetKeyFunc(tt.keyFunc)
+
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowNormalizedKeys(t *testing.T) {
+	type Pool struct {
+		MaxOpen int64
+	}
+	type Config struct {
+		ServerName string
+		DataDir    string `toml:"data_dir"`
+		DBPool     Pool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		allow    bool
+		expected Config
+	}{
+		{
+			name:     "snake case keys",
+			input:    "server_name = \"app\"\n[db_pool]\nmax_open = 5",
+			allow:    true,
+			expected: Config{ServerName: "app", DBPool: Pool{MaxOpen: 5}},
+		},
+		{
+			name:     "kebab case keys and tags",
+			input:    "server-name = \"app\"\ndata-dir = \"/var\"\n[db-pool]\nmax-open = 5",
+			allow:    true,
+			expected: Config{ServerName: "app", DataDir: "/var", DBPool: Pool{MaxOpen: 5}},
+		},
+		{
+			name:     "exact match preferred",
+			input:    "ServerName = \"exact\"\nserver_name = \"normalized\"",
+			allow:    true,
+			expected: Config{ServerName: "exact"},
+		},
+		{
+			name:     "case-insensitive only by default",
+			input:    "servername = \"app\"\nserver_name = \"other\"\n[db_pool]\nmax_open = 5",
+			allow:    false,
+			expected: Config{ServerName: "app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.allow {
+				dec.AllowNormalizedKeys()
+			}
+
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_AllowNull(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host"`
+	}
+	type Config struct {
+		Port    *int64            `toml:"port"`
+		Name    *string           `toml:"name"`
+		Labels  map[string]string `toml:"labels"`
+		Extra   any               `toml:"extra"`
+		Backup  *Server           `toml:"backup"`
+		Servers []Server          `toml:"servers"`
+		Title   string            `toml:"title"`
+	}
+	port, name := int64(80), "base"
+
+	// null cannot unset a plain string, even inside a table array element
+	dec := NewDecoder(strings.NewReader("port = null\nlabels = null\nextra = null\nbackup = null\n[[servers]]\nhost = null"))
+	dec.AllowNull()
+	got := Config{}
+	err := dec.Decode(&got)
+	if err == nil || !strings.Contains(err.Error(), errInvalidNull+" [type, string, ") {
+		t.Errorf("Decode() error = %v, want error containing %v", err, errInvalidNull)
+	}
+
+	// Decoding over a base config: null clears, absent keeps, values set
+	dec = NewDecoder(strings.NewReader("port = null\nlabels = null\nextra = null\nbackup = null\ntitle = \"t\""))
+	dec.AllowNull()
+	got = Config{Port: &port, Name: &name, Labels: map[string]string{"a": "b"}, Extra: 1, Backup: &Server{Host: "x"}}
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	expected := Config{Name: &name, Title: "t"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Decode() = %+v, want %+v", got, expected)
+	}
+
+	// Maps keep null keys as present with a nil value
+	dec = NewDecoder(strings.NewReader("a = null\n[t]\nb = null\n[[list]]\nc = null"))
+	dec.AllowNull()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := map[string]any{"a": nil, "t": map[string]any{"b": nil}, "list": []any{map[string]any{"c": nil}}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Decode() = %v, want %v", m, want)
+	}
+
+	// null is rejected by default and inside arrays
+	for _, input := range []string{"port = null", "ports = [1, null]"} {
+		dec = NewDecoder(strings.NewReader(input))
+		if input != "port = null" {
+			dec.AllowNull()
+		}
+		if err := dec.Decode(&m); err == nil {
+			t.Errorf("Decode(%q) error = nil, want error", input)
+		}
+	}
+}
+
+func TestDecoder_UseNumber(t *testing.T) {
+	input := "mask = 0x1F\nport = +8080\nrate = 3.140\nweights = [1.50, 2]\n"
+
+	t.Run("roundtrip keeps the text", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader(input))
+		dec.UseNumber()
+		var got map[string]any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if got["rate"] != Number("3.140") {
+			t.Errorf("Decode() rate = %#v, want Number(\"3.140\")", got["rate"])
+		}
+		output, err := Marshal(got)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(output) != input {
+			t.Errorf("Marshal() = %q, want %q", output, input)
+		}
+	})
+
+	t.Run("typed targets get parsed numbers", func(t *testing.T) {
+		type Config struct {
+			Mask    int       `toml:"mask"`
+			Port    uint16    `toml:"port"`
+			Rate    Number    `toml:"rate"`
+			Weights []float64 `toml:"weights"`
+			Extra   any       `toml:"extra"`
+		}
+		dec := NewDecoder(strings.NewReader(input + "extra = [1.0, 2.50]"))
+		dec.UseNumber()
+		var got Config
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		want := Config{Mask: 31, Port: 8080, Rate: "3.140", Weights: []float64{1.5, 2}, Extra: []any{Number("1.0"), Number("2.50")}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("range checks still apply", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader("port = 70000"))
+		dec.UseNumber()
+		var got struct {
+			Port uint16 `toml:"port"`
+		}
+		if err := dec.Decode(&got); err == nil || !strings.Contains(err.Error(), errIntegerOverflow) {
+			t.Errorf("Decode() error = %v, want error containing %v", err, errIntegerOverflow)
+		}
+	})
+
+	t.Run("invalid number is not marshaled", func(t *testing.T) {
+		_, err := Marshal(map[string]any{"rate": Number("fast")})
+		if err == nil || !strings.Contains(err.Error(), errInvalidValue) {
+			t.Errorf("Marshal() error = %v, want error containing %v", err, errInvalidValue)
+		}
+	})
+}
+
+func TestDecoder_AllowIndexTables(t *testing.T) {
+	type Item struct {
+		Name  string   `toml:"name"`
+		Ports []string `toml:"ports"`
+	}
+	type Config struct {
+		Items []Item            `toml:"items"`
+		Tags  []any             `toml:"tags"`
+		Ports map[string]string `toml:"ports"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "index tables in order",
+			input:    "[items.1]\nname = \"b\"\n[items.0]\nname = \"a\"\nports.0 = \"80\"\nports.1 = \"443\"",
+			expected: Config{Items: []Item{{Name: "a", Ports: []string{"80", "443"}}, {Name: "b"}}},
+		},
+		{
+			name:     "dotted index keys",
+			input:    "tags.0 = \"x\"\ntags.1 = 2",
+			expected: Config{Tags: []any{"x", int64(2)}},
+		},
+		{
+			name:     "index tables inside array of tables",
+			input:    "[[items]]\nname = \"a\"\nports.0 = \"80\"",
+			expected: Config{Items: []Item{{Name: "a", Ports: []string{"80"}}}},
+		},
+		{
+			name:     "gap",
+			input:    "tags.0 = 1\ntags.2 = 3",
+			wantErr:  true,
+			errormsg: errInvalidIndex + " [missing index 1 of 2]",
+		},
+		{
+			name:     "not starting at zero",
+			input:    "[items.1]\nname = \"b\"",
+			wantErr:  true,
+			errormsg: errInvalidIndex + " [missing index 0 of 1]",
+		},
+		{
+			name:     "map target keeps the indices as keys",
+			input:    "[ports]\n\"80\" = \"http\"\n\"443\" = \"https\"",
+			expected: Config{Ports: map[string]string{"80": "http", "443": "https"}},
+		},
+		{
+			name:     "leading zero",
+			input:    "tags.00 = 1",
+			wantErr:  true,
+			errormsg: errInvalidIndex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			dec.AllowIndexTables()
+
+			var got Config
+			err := dec.Decode(&got)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+
+	// Without the option index segments are rejected like any digit bare key,
+	// quoted ones stay table keys
+	for _, input := range []string{"tags.0 = 1", "[tags.0]", "0 = 1", "[0]"} {
+		var m map[string]any
+		if err := Unmarshal([]byte(input), &m); err == nil || !strings.Contains(err.Error(), errInvalidKey) && !strings.Contains(err.Error(), errInvalidTableName) {
+			t.Errorf("Unmarshal(%q) error = %v, want an invalid key or table name error", input, err)
+		}
+	}
+	var m map[string]any
+	if err := Unmarshal([]byte("tags.\"0\" = 1"), &m); err != nil || !reflect.DeepEqual(m, map[string]any{"tags": map[string]any{"0": int64(1)}}) {
+		t.Errorf("Unmarshal() = %v, %v, want the index as a key", m, err)
+	}
+}
+
+func TestDecoder_AllowComplexArrays(t *testing.T) {
+	type Gain struct {
+		Input complex128 `toml:"input"`
+	}
+	type Filter struct {
+		Gain   `toml:",inline"`
+		Pole   complex128   `toml:"stage.pole"`
+		Zero   complex64    `toml:"zero,section=stage"`
+		Taps   []complex128 `json:"taps"`
+		Offset complex128
+	}
+
+	input := "input = [1, 2]\ntaps = [[3, 4], [5, 6]]\noffset = [7, 8]\n[stage]\npole = [0.5, -0.5]\nzero = [1, 0]"
+	dec := NewDecoder(strings.NewReader(input))
+	dec.AllowComplexArrays()
+	dec.AllowJSONTags()
+	dec.SetKeyFunc(SnakeCase)
+	var got Filter
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := Filter{
+		Gain:   Gain{Input: complex(1, 2)},
+		Pole:   complex(0.5, -0.5),
+		Zero:   complex(1, 0),
+		Taps:   []complex128{complex(3, 4), complex(5, 6)},
+		Offset: complex(7, 8),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %v, want %v", got, want)
+	}
+
+	// Malformed values name the key they were found under
+	dec = NewDecoder(strings.NewReader("[stage]\npole = [1, \"x\"]"))
+	dec.AllowComplexArrays()
+	err := dec.Decode(&Filter{})
+	if err == nil || !strings.Contains(err.Error(), errInvalidValue) || !strings.Contains(err.Error(), "[key, stage.pole]") {
+		t.Errorf("Decode() error = %v, want error containing %v for key stage.pole", err, errInvalidValue)
+	}
+}
+
+func TestDecoder_CollectErrors(t *testing.T) {
+	input := "name = \"app\"\nport = 80 80\n[server\nhost = \"localhost\"\n= 5\nratio = 1.5.5\n[server]\nport = 8080"
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.CollectErrors()
+	dec.AllowPartialResult()
+
+	var got map[string]any
+	err := dec.Decode(&got)
+
+	var collected *ValidationError
+	if !errors.As(err, &collected) {
+		t.Fatalf("Decode() error = %v, want *ValidationError", err)
+	}
+	want := []string{
+		errUnexpectedToken + " [key, port, value 80, unexpected 80, line 2]",
+		errInvalidTableHeader + " [missing closing bracket] [table header, [server] [line 3",
+		errMissingKey + " [line 5]",
+		errInvalidFloat,
+	}
+	if len(collected.Errors) != len(want) {
+		t.Fatalf("Decode() collected %d errors, want %d:\n%v", len(collected.Errors), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(collected.Errors[i].Error(), w) {
+			t.Errorf("error %d = %v, want error containing %v", i, collected.Errors[i], w)
+		}
+	}
+
+	// The lines that parsed still reach the target with AllowPartialResult
+	if got["name"] != "app" || got["host"] != "localhost" || !reflect.DeepEqual(got["server"], map[string]any{"port": int64(8080)}) {
+		t.Errorf("Decode() = %v, want the valid lines decoded", got)
+	}
+
+	// Without the option parsing stops at the first error
+	err = NewDecoder(strings.NewReader(input)).Decode(&map[string]any{})
+	if errors.As(err, &collected) || !strings.Contains(err.Error(), errUnexpectedToken) {
+		t.Errorf("Decode() error = %v, want only the first error", err)
+	}
+}
+
+func TestDecoder_DisallowMixedArrays(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantErr  bool
+		errormsg string
+	}{
+		{name: "uniform arrays", input: "ints = [1, 2]\nnames = [\"a\"]\nempty = []\nnested = [[1], [\"a\"]]"},
+		{name: "integer and string", input: "vals = [1, \"two\"]", wantErr: true, errormsg: errMixedArray + " [element 1 is string, element 0 is integer] [line 1]"},
+		{name: "first offending element", input: "vals = [true, false, 1, \"x\"]", wantErr: true, errormsg: "element 2 is integer, element 0 is boolean"},
+		{name: "inside nested array", input: "vals = [[1], [\"x\", 2]]", wantErr: true, errormsg: "element 1 is integer, element 0 is string"},
+		{name: "multiline array", input: "[t]\nvals = [\n  1,\n  2.5,\n]", wantErr: true, errormsg: "element 1 is float, element 0 is integer] [line 2]"},
+		{name: "append joins types", input: "vals = [1]\nvals += [\"x\"]", wantErr: true, errormsg: errMixedArray + " [element 1 is string, element 0 is integer, key, vals, line 2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Schemaless targets are checked too
+			for _, target := range []any{new(any), &map[string]any{}} {
+				dec := NewDecoder(strings.NewReader(tt.input))
+				dec.DisallowMixedArrays()
+				err := dec.Decode(target)
+
+				if tt.wantErr {
+					if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+						t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+					}
+				} else if err != nil {
+					t.Errorf("Decode() error = %v", err)
+				}
+
+				// Mixed arrays are accepted by default
+				if err := NewDecoder(strings.NewReader(tt.input)).Decode(target); err != nil {
+					t.Errorf("Decode() without the option error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_ArrayLength(t *testing.T) {
+	type Config struct {
+		Slots [4]int       `toml:"slots"`
+		Pairs [2][2]string `toml:"pairs"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		zeroFill bool
+		truncate bool
+		expected Config
+		errormsg string
+	}{
+		{
+			name:     "under-length is an error by default",
+			input:    "slots = [1, 2]",
+			errormsg: errArrayLength + " [got 2, want 4, type, [4]int]",
+		},
+		{
+			name:     "under-length zero-filled",
+			input:    "slots = [1, 2]\npairs = [[\"a\"]]",
+			zeroFill: true,
+			expected: Config{Slots: [4]int{1, 2, 0, 0}, Pairs: [2][2]string{{"a", ""}, {"", ""}}},
+		},
+		{
+			name:     "over-length is an error by default",
+			input:    "slots = [1, 2, 3, 4, 5]",
+			zeroFill: true,
+			errormsg: errArrayLength + " [got 5, want 4, type, [4]int]",
+		},
+		{
+			name:     "over-length truncated",
+			input:    "slots = [1, 2, 3, 4, 5]",
+			truncate: true,
+			expected: Config{Slots: [4]int{1, 2, 3, 4}, Pairs: [2][2]string{{"x", "x"}, {"x", "x"}}},
+		},
+		{
+			name:     "truncation does not zero-fill",
+			input:    "slots = [1]",
+			truncate: true,
+			errormsg: errArrayLength + " [got 1, want 4, type, [4]int]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			if tt.zeroFill {
+				dec.AllowArrayZeroFill()
+			}
+			if tt.truncate {
+				dec.AllowArrayTruncation()
+			}
+
+			// Pre-filled targets show zero-fill writes every element
+			got := Config{Slots: [4]int{9, 9, 9, 9}, Pairs: [2][2]string{{"x", "x"}, {"x", "x"}}}
+			err := dec.Decode(&got)
+
+			if tt.errormsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Decode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecoder_SetMaxDepth(t *testing.T) {
+	deepArray := "x = " + strings.Repeat("[", 1000) + strings.Repeat("]", 1000)
+	deepTable := "[" + strings.TrimSuffix(strings.Repeat("a.", 101), ".") + "]\nx = 1"
+	deepKey := strings.TrimSuffix(strings.Repeat("a.", 102), ".") + " = 1"
+
+	tests := []struct {
+		name     string
+		input    string
+		depth    int
+		wantErr  bool
+		errormsg string
+	}{
+		{name: "deep array with default limit", input: deepArray, depth: 0, wantErr: true, errormsg: errNestingDepth},
+		{name: "deep table with default limit", input: deepTable, depth: 0, wantErr: true, errormsg: errNestingDepth + " [line 1]"},
+		{name: "deep dotted key with default limit", input: deepKey, depth: 0, wantErr: true, errormsg: errNestingDepth + " [line 1]"},
+		{name: "array within limit", input: "x = [[1]]", depth: 2, wantErr: false},
+		{name: "array over limit", input: "x = [[[1]]]", depth: 2, wantErr: true, errormsg: errNestingDepth},
+		{name: "table within limit", input: "[a.b]\nx = 1", depth: 2, wantErr: false},
+		{name: "table over limit", input: "[a.b.c]\nx = 1", depth: 2, wantErr: true, errormsg: errNestingDepth},
+		{name: "dotted key at limit", input: "a.b.c = 1", depth: 2, wantErr: false},
+		{name: "dotted key under header at limit", input: "[a]\nb.c = 1", depth: 2, wantErr: false},
+		{name: "dotted key over limit", input: "a.b.c.d = 1", depth: 2, wantErr: true, errormsg: errNestingDepth},
+		{name: "dotted key under header over limit", input: "[a.b]\nc.d = 1", depth: 2, wantErr: true, errormsg: errNestingDepth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.input))
+			dec.SetMaxDepth(tt.depth)
+
+			var got map[string]any
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	input := "name = \"app\"\nports = [\n    80,\n    443,\n]\n\n[server]\nhost = \"localhost\""
+
+	tests := []struct {
+		name     string
+		setup    func(dec *Decoder)
+		wantErr  bool
+		errormsg string
+	}{
+		{name: "no limits", setup: func(dec *Decoder) {}, wantErr: false},
+		{name: "input within limit", setup: func(dec *Decoder) { dec.SetMaxInputSize(len(input)) }, wantErr: false},
+		{name: "input over limit", setup: func(dec *Decoder) { dec.SetMaxInputSize(len(input) - 1) }, wantErr: true, errormsg: errInputTooLarge},
+		{name: "lines within limit", setup: func(dec *Decoder) { dec.SetMaxLineLength(20) }, wantErr: false},
+		{name: "joined array over limit", setup: func(dec *Decoder) { dec.SetMaxLineLength(19) }, wantErr: true, errormsg: errLineTooLong + " [max 19 bytes, line 2]"},
+		{name: "first line over limit", setup: func(dec *Decoder) { dec.SetMaxLineLength(7) }, wantErr: true, errormsg: errLineTooLong + " [max 7 bytes, line 1]"},
+		{name: "keys within limit", setup: func(dec *Decoder) { dec.SetMaxKeys(4) }, wantErr: false},
+		{name: "keys over limit", setup: func(dec *Decoder) { dec.SetMaxKeys(3) }, wantErr: true, errormsg: errTooManyKeys + " [max 3, line 8]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(input))
+			tt.setup(dec)
+
+			var got map[string]any
+			err := dec.Decode(&got)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Decode() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+			}
+		})
+	}
+
+	// Short physical lines still fail when joined into a long logical line
+	for _, input := range []string{"x = [\n1,\n2,\n3,\n4]", "s = \"\"\"\nabcdefgh\nabcdefgh\n\"\"\""} {
+		dec := NewDecoder(strings.NewReader(input))
+		dec.SetMaxLineLength(16)
+		var got map[string]any
+		if err := dec.Decode(&got); err == nil || !strings.Contains(err.Error(), errLineTooLong+" [max 16 bytes, line 1]") {
+			t.Errorf("Decode(%q) error = %v, want error containing %v", input, err, errLineTooLong)
+		}
+	}
+}
+
+// endlessReader yields the same byte forever, a line that never ends
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func TestDecoder_SetDocumentSeparator(t *testing.T) {
+	type Service struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+	}
+
+	input := "name = \"api\"\nport = 8080\n---\nname = \"web\"\n  ---  \n\nport = 443\n---\n"
+
+	dec := NewDecoder(strings.NewReader(input))
+	dec.SetDocumentSeparator("---")
+
+	want := []Service{{Name: "api", Port: 8080}, {Name: "web"}, {Port: 443}}
+	for i, w := range want {
+		var got Service
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode() document %d error = %v", i, err)
+		}
+		if got != w {
+			t.Errorf("Decode() document %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	var rest Service
+	if err := dec.Decode(&rest); err != io.EOF {
+		t.Errorf("Decode() after last document error = %v, want io.EOF", err)
+	}
+
+	t.Run("error in one document", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader("port = 1\n---\nport = \"x\nname = \"b\""))
+		dec.SetDocumentSeparator("---")
+
+		var first map[string]any
+		if err := dec.Decode(&first); err != nil || first["port"] != int64(1) {
+			t.Fatalf("Decode() = %v, %v, want port 1", first, err)
+		}
+		var second map[string]any
+		err := dec.Decode(&second)
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("Decode() error = %v, want error containing %v", err, "line 1")
+		}
+	})
+
+	t.Run("leading and doubled separators", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader("---\nport = 1\n---\n\n---\nport = 2\n---\n---\n"))
+		dec.SetDocumentSeparator("---")
+
+		for _, want := range []int{1, 2} {
+			var got Service
+			if err := dec.Decode(&got); err != nil || got.Port != want {
+				t.Fatalf("Decode() = %+v, %v, want port %d", got, err, want)
+			}
+		}
+		var rest Service
+		if err := dec.Decode(&rest); err != io.EOF {
+			t.Errorf("Decode() after last document error = %v, want io.EOF", err)
+		}
+	})
+
+	t.Run("line over size limit", func(t *testing.T) {
+		// The line never ends, so it must be rejected while it is being read
+		dec := NewDecoder(endlessReader{})
+		dec.SetDocumentSeparator("---")
+		dec.SetMaxInputSize(1 << 16)
+
+		var got map[string]any
+		err := dec.Decode(&got)
+		if err == nil || !strings.Contains(err.Error(), errInputTooLarge) {
+			t.Errorf("Decode() error = %v, want error containing %v", err, errInputTooLarge)
+		}
+	})
+
+	t.Run("document over size limit", func(t *testing.T) {
+		dec := NewDecoder(strings.NewReader("a = 1\n---\nname = \"a long value\"\n"))
+		dec.SetDocumentSeparator("---")
+		dec.SetMaxInputSize(10)
+
+		var got map[string]any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		err := dec.Decode(&got)
+		if err == nil || !strings.Contains(err.Error(), errInputTooLarge) {
+			t.Errorf("Decode() error = %v, want error containing %v", err, errInputTooLarge)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldDesc describes one key of the TOML document a struct maps to.
+// Key is the dotted path from the document root (e.g. "database.pool.max_open"),
+// with segments that are not bare keys quoted as in a table header.
+// Comment and Default hold the raw `comment` and `default` struct tags, if any,
+// and Required is set by the tag option toml:"name,required".
+type FieldDesc struct {
+	Key      string
+	Type     reflect.Type
+	Comment  string
+	Default  string
+	Required bool
+}
+
+// Describe walks the toml tags of a struct type and lists every key it maps to,
+// in field declaration order. Nested structs contribute their keys under the
+// table path (pointers to structs included), inline fields are flattened and
+// dotted tags are split into paths. A struct type nested in itself, such as
+// type Node struct{ Next *Node }, is expanded once: the repeated field is listed
+// as a single key of the struct type.
+// Only the type of v is inspected, so a zero value or nil pointer is enough.
+func Describe(v any) ([]FieldDesc, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errorf(fmt.Errorf(errNilValue))
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil, errorf(fmt.Errorf(errUnsupported), "type", t.String())
+	}
+
+	var fields []FieldDesc
+	if err := describeStruct(t, nil, &fields, map[reflect.Type]bool{}); err != nil {
+		return nil, errorf(err)
+	}
+	return fields, nil
+}
+
+// describeStruct appends the keys of struct type t, found under path, to fields
+// visiting holds the struct types on the current path, so recursive types end
+func describeStruct(t reflect.Type, path []string, fields *[]FieldDesc, visiting map[reflect.Type]bool) error {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := parseTag(field)
+		if tag.skip || tag.has("raw") || tag.has("remaining") {
+			continue
+		}
+		tomlName := tag.name
+
+		if tag.has("inline") {
+			// Pointers to structs are flattened too, as Marshal writes them
+			if !isStructType(field.Type) {
+				return errorf(fmt.Errorf(errUnsupported), "inline", field.Name)
+			}
+			inlineType := field.Type
+			if inlineType.Kind() == reflect.Pointer {
+				inlineType = inlineType.Elem()
+			}
+			if visiting[inlineType] {
+				continue
+			}
+			if err := describeStruct(inlineType, path, fields, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fieldPath := append(path[:len(path):len(path)], strings.Split(tomlName, ".")...)
+		if isStructType(field.Type) {
+			structType := field.Type
+			if structType.Kind() == reflect.Pointer {
+				structType = structType.Elem()
+			}
+			if !visiting[structType] {
+				if err := describeStruct(structType, fieldPath, fields, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		*fields = append(*fields, FieldDesc{
+			Key:      formatPath(fieldPath),
+			Type:     field.Type,
+			Comment:  field.Tag.Get("comment"),
+			Default:  field.Tag.Get("default"),
+			Required: tag.has("required"),
+		})
+	}
+	return nil
+}
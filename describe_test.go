@@ -0,0 +1,96 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	type Pool struct {
+		MaxOpen int64 `toml:"max_open" comment:"Maximum open connections" default:"10"`
+	}
+	type Common struct {
+		Name string `toml:"name" comment:"Instance name"`
+	}
+	type Owner struct {
+		Owner string `toml:"owner"`
+	}
+	type Config struct {
+		Common  `toml:",inline"`
+		Host    string    `toml:"server.host" default:"localhost"`
+		Started time.Time `toml:"started"`
+		Tags    []string  `toml:"tags,required"`
+		Skipped string    `toml:"-"`
+		hidden  string
+		Pool    Pool   `toml:"database.pool"`
+		Owner   *Owner `toml:",inline"`
+	}
+
+	want := []FieldDesc{
+		{Key: "name", Type: reflect.TypeOf(""), Comment: "Instance name"},
+		{Key: "server.host", Type: reflect.TypeOf(""), Default: "localhost"},
+		{Key: "started", Type: reflect.TypeOf(time.Time{})},
+		{Key: "tags", Type: reflect.TypeOf([]string{}), Required: true},
+		{Key: "database.pool.max_open", Type: reflect.TypeOf(int64(0)), Comment: "Maximum open connections", Default: "10"},
+		{Key: "owner", Type: reflect.TypeOf("")},
+	}
+
+	got, err := Describe(Config{})
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %v, want %v", got, want)
+	}
+
+	// A nil pointer carries the type, which is all Describe needs
+	got, err = Describe((*Config)(nil))
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %v, want %v", got, want)
+	}
+
+	if _, err := Describe(nil); err == nil || !strings.Contains(err.Error(), errNilValue) {
+		t.Errorf("Describe(nil) error = %v, want error containing %v", err, errNilValue)
+	}
+	for _, v := range []any{map[string]any{}, 42, time.Time{}} {
+		if _, err := Describe(v); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+			t.Errorf("Describe(%T) error = %v, want error containing %v", v, err, errUnsupported)
+		}
+	}
+}
+
+func TestDescribe_RecursiveType(t *testing.T) {
+	type Tree struct {
+		Name  string `toml:"name"`
+		Left  *Tree  `toml:"left"`
+		Right *Tree  `toml:"right"`
+		Meta  struct {
+			Owner *Tree `toml:"owner"`
+		} `toml:"meta"`
+	}
+
+	want := []FieldDesc{
+		{Key: "name", Type: reflect.TypeOf("")},
+		{Key: "left", Type: reflect.TypeOf(&Tree{})},
+		{Key: "right", Type: reflect.TypeOf(&Tree{})},
+		{Key: "meta.owner", Type: reflect.TypeOf(&Tree{})},
+	}
+	got, err := Describe(Tree{})
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %v, want %v", got, want)
+	}
+
+	// ValidateAgainst follows the document, however deep the type nests
+	err = ValidateAgainst([]byte("[left.right]\nnmae = \"leaf\""), &Tree{})
+	if err == nil || !strings.Contains(err.Error(), errUnknownKey+" [key, left.right.nmae]") {
+		t.Errorf("ValidateAgainst() error = %v, want unknown key left.right.nmae", err)
+	}
+}
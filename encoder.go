@@ -0,0 +1,157 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Encoder writes TOML documents to an output stream.
+// Formatting options set on an Encoder apply to every Encode call.
+type Encoder struct {
+	w       io.Writer
+	pretty  bool
+	format  formatOptions
+	options marshalOptions
+	unixErr error // invalid unit passed to SetUnixTimestamps, returned by Encode
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, format: newFormatOptions(""), options: marshalOptions{floatPrecision: -1}}
+}
+
+// SetIndent enables the MarshalIndent layout for subsequent documents:
+// blank lines between table sections and nesting indented by indent.
+func (e *Encoder) SetIndent(indent string) {
+	e.pretty = true
+	e.format.indent = indent
+}
+
+// SetArrayWidth sets the line width above which arrays in indented output
+// are split onto one element per line (default 80). Shorter arrays stay inline.
+// A width of 0 keeps every array inline. Has no effect without SetIndent.
+func (e *Encoder) SetArrayWidth(width int) {
+	e.format.arrayWidth = width
+}
+
+// SetTableSpacing sets the number of blank lines written before each table
+// header in indented output (default 1; negative values count as 0).
+// When beforeFirst is false, the first header directly follows the root keys;
+// output never starts with blank lines. Has no effect without SetIndent.
+func (e *Encoder) SetTableSpacing(blankLines int, beforeFirst bool) {
+	e.format.tableSpacing = max(blankLines, 0)
+	e.format.spaceFirst = beforeFirst
+}
+
+// SetFloatPrecision fixes the number of digits after the decimal point for
+// float values (e.g. 2 writes 19.5 as 19.50). Output stays re-parseable as
+// a float, but rounding means the exact value may not round-trip.
+// A precision of 0 still writes one decimal place (20.0); a negative
+// precision restores the default shortest round-trip form.
+func (e *Encoder) SetFloatPrecision(precision int) {
+	e.options.floatPrecision = max(precision, -1)
+}
+
+// SetRuneStrings writes int32 values as single-character strings (sep = ",")
+// instead of integers. Go cannot tell rune from int32, so this applies to every
+// int32 field, map value and array element. Decode such output with a Decoder
+// that has AllowRuneStrings enabled.
+func (e *Encoder) SetRuneStrings(enabled bool) {
+	e.options.runeStrings = enabled
+}
+
+// SetSkipUnsupported omits struct fields and map entries whose values cannot be
+// represented in TOML (chan, func, complex, unsafe pointers) instead of failing
+// the whole encode, so structs mixing config with runtime handles can be written
+// as they are. Arrays containing such elements are still an error.
+func (e *Encoder) SetSkipUnsupported(enabled bool) {
+	e.options.skipUnsupported = enabled
+}
+
+// SetUnixTimestamps writes time.Time values as integer Unix timestamps counted in
+// unit (time.Second, time.Millisecond, time.Microsecond or time.Nanosecond)
+// instead of RFC 3339 date-times, dropping the offset and any precision finer
+// than unit. Decode such output with a Decoder that has AllowUnixTimestamps
+// enabled with the same unit. A unit of 0 restores RFC 3339 output. The unit must
+// divide a second evenly or be a whole number of seconds; any other unit, such
+// as 1500ms, makes Encode fail.
+func (e *Encoder) SetUnixTimestamps(unit time.Duration) {
+	e.options.unixUnit, e.unixErr = 0, nil
+	if unit != 0 && !validUnixUnit(unit) {
+		e.unixErr = errorf(fmt.Errorf(errInvalidUnit), unit.String())
+		return
+	}
+	e.options.unixUnit = unit
+}
+
+// SetJSONTags names struct fields that have no toml tag after their json tag
+// (json:"max_conns"), so structs shared with encoding/json need no second set
+// of tags. A toml tag still takes precedence, json:"-" skips the field, and
+// json options such as omitempty are ignored.
+func (e *Encoder) SetJSONTags(enabled bool) {
+	e.options.jsonTags = enabled
+}
+
+// SetKeyFunc names struct fields that have no explicit key in their tag by
+// fn(field name) instead of the field name itself, so SetKeyFunc(SnakeCase)
+// writes MaxConns as max_conns without tagging every field. Tag names, and
+// json tags with SetJSONTags, still take precedence. A nil fn restores the
+// field names.
+func (e *Encoder) SetKeyFunc(fn func(fieldName string) string) {
+	e.options.keyFunc = fn
+}
+
+// SetStringers writes values of integer types that implement fmt.Stringer, such
+// as enums, as their quoted String() text (level = "debug") instead of the
+// underlying number. The String method must have a value receiver. Decode such
+// output with a converter from the enum's parse function, e.g.
+// RegisterConverter(dec, ParseLevel).
+func (e *Encoder) SetStringers(enabled bool) {
+	e.options.stringers = enabled
+}
+
+// SetComplexArrays writes complex64 and complex128 values, which TOML has no
+// type for, as two-element float arrays [real, imag] instead of failing as
+// unsupported. Decode such output with a Decoder that has AllowComplexArrays
+// enabled.
+func (e *Encoder) SetComplexArrays(enabled bool) {
+	e.options.complexArrays = enabled
+}
+
+// SetSkipNilElements leaves nil pointers out of slices and arrays, such as a
+// []*Server or []*int, instead of failing the encode, since TOML has no null.
+// Non-nil pointer elements are always written as the values they point to.
+func (e *Encoder) SetSkipNilElements(enabled bool) {
+	e.options.skipNilElements = enabled
+}
+
+// SetBoolFormat writes booleans as True/False (BoolTitle) or 1/0 (BoolNumeric)
+// for legacy consumers. Such output is not valid TOML; decode it back with
+// (*Decoder).SetBoolFormat. The default, BoolLower, writes true/false.
+func (e *Encoder) SetBoolFormat(format BoolFormat) {
+	e.options.boolFormat = format
+}
+
+// Encode writes the TOML encoding of v to the stream.
+// The value follows the same rules as Marshal.
+func (e *Encoder) Encode(v any) error {
+	if e.unixErr != nil {
+		return errorf(e.unixErr)
+	}
+	var buf bytes.Buffer
+	if err := e.marshal(&buf, v); err != nil {
+		return errorf(err)
+	}
+	data := buf.Bytes()
+	if e.pretty {
+		data = formatTOML(data, e.format)
+	}
+
+	if _, err := e.w.Write(data); err != nil {
+		return errorf(err)
+	}
+	return nil
+}
@@ -0,0 +1,941 @@
+package tinytoml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoder_SetArrayWidth(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	input := map[string]any{
+		"ports": []int{8080, 8081},
+		"server": map[string]any{
+			"hosts": []string{"alpha.example.com", "beta.example.com", "gamma.example.com"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		width    int
+		expected string
+	}{
+		{
+			name:  "all arrays inline",
+			width: 0,
+			expected: `ports = [8080, 8081]
+
+[server]
+  hosts = ["alpha.example.com", "beta.example.com", "gamma.example.com"]
+`,
+		},
+		{
+			name:  "long arrays split",
+			width: 40,
+			expected: `ports = [8080, 8081]
+
+[server]
+  hosts = [
+    "alpha.example.com",
+    "beta.example.com",
+    "gamma.example.com",
+  ]
+`,
+		},
+		{
+			name:  "every multi-element array split",
+			width: 1,
+			expected: `ports = [
+  8080,
+  8081,
+]
+
+[server]
+  hosts = [
+    "alpha.example.com",
+    "beta.example.com",
+    "gamma.example.com",
+  ]
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetIndent("  ")
+			enc.SetArrayWidth(test.width)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+				return
+			}
+
+			// Split arrays must parse back to the same document
+			var plain, pretty map[string]any
+			raw, _ := Marshal(input)
+			if err := Unmarshal(raw, &plain); err != nil {
+				t.Errorf("-- %s failed: plain output does not parse: %s\n", fn, err.Error())
+				return
+			}
+			if err := Unmarshal(buf.Bytes(), &pretty); err != nil {
+				t.Errorf("-- %s failed: encoded output does not parse: %s\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(plain, pretty) {
+				t.Errorf("-- %s failed: encoded output differs.\n- plain: %v\n- pretty: %v\n\n", fn, plain, pretty)
+			}
+		})
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(map[string]any{"name": "app"}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if buf.String() != "name = \"app\"\n" {
+		t.Errorf("Encode() = %q, want %q", buf.String(), "name = \"app\"\n")
+	}
+
+	if err := NewEncoder(&buf).Encode(make(chan int)); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("Encode() error = %v, want error containing %v", err, errUnsupported)
+	}
+}
+
+func TestEncoder_SetFloatPrecision(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	input := map[string]any{
+		"price":  19.5,
+		"rates":  []float64{0.125, 3},
+		"amount": float32(2.5),
+		"count":  3,
+	}
+
+	tests := []struct {
+		name      string
+		precision int
+		expected  string
+	}{
+		{
+			name:      "default shortest form",
+			precision: -1,
+			expected:  "amount = 2.5\ncount = 3\nprice = 19.5\nrates = [0.125, 3.0]\n",
+		},
+		{
+			name:      "two decimals",
+			precision: 2,
+			expected:  "amount = 2.50\ncount = 3\nprice = 19.50\nrates = [0.12, 3.00]\n",
+		},
+		{
+			name:      "zero decimals keeps float form",
+			precision: 0,
+			expected:  "amount = 2.0\ncount = 3\nprice = 20.0\nrates = [0.0, 3.0]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetFloatPrecision(test.precision)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+				return
+			}
+
+			// Fixed precision output must still parse as floats
+			var parsed map[string]any
+			if err := Unmarshal(buf.Bytes(), &parsed); err != nil {
+				t.Errorf("-- %s failed: encoded output does not parse: %s\n", fn, err.Error())
+				return
+			}
+			if _, ok := parsed["price"].(float64); !ok {
+				t.Errorf("-- %s failed: price reparsed as %T, want float64\n", fn, parsed["price"])
+			}
+		})
+	}
+}
+
+func TestEncoder_SetRuneStrings(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Config struct {
+		Sep    rune   `toml:"sep"`
+		Quote  rune   `toml:"quote"`
+		Marks  []rune `toml:"marks"`
+		Width  int64  `toml:"width"`
+		Offset int32  `toml:"offset"`
+	}
+	input := Config{Sep: ',', Quote: '"', Marks: []rune{'é', '✓'}, Width: 80, Offset: 'x'}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		input    any
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "runes as integers by default",
+			enabled:  false,
+			input:    input,
+			expected: "marks = [233, 10003]\noffset = 120\nquote = 34\nsep = 44\nwidth = 80\n",
+		},
+		{
+			name:     "runes as strings",
+			enabled:  true,
+			input:    input,
+			expected: "marks = [\"é\", \"✓\"]\noffset = \"x\"\nquote = \"\\\"\"\nsep = \",\"\nwidth = 80\n",
+		},
+		{
+			name:     "invalid rune",
+			enabled:  true,
+			input:    map[string]any{"sep": int32(-1)},
+			wantErr:  true,
+			errormsg: errInvalidRune,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetRuneStrings(test.enabled)
+
+			err := enc.Encode(test.input)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncoder_SetUnixTimestamps(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Config struct {
+		Created time.Time   `toml:"created"`
+		Events  []time.Time `toml:"events"`
+	}
+	input := Config{
+		Created: time.Date(2023, 11, 14, 23, 13, 20, 123456789, time.FixedZone("", 60*60)),
+		Events:  []time.Time{time.Date(1969, 12, 31, 23, 59, 58, 500000000, time.UTC)},
+	}
+
+	tests := []struct {
+		name     string
+		unit     time.Duration
+		expected string
+	}{
+		{
+			name:     "date-times by default",
+			unit:     0,
+			expected: "created = 2023-11-14T23:13:20.123456789+01:00\nevents = [1969-12-31T23:59:58.5Z]\n",
+		},
+		{
+			name:     "seconds",
+			unit:     time.Second,
+			expected: "created = 1700000000\nevents = [-2]\n",
+		},
+		{
+			name:     "milliseconds",
+			unit:     time.Millisecond,
+			expected: "created = 1700000000123\nevents = [-1500]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetUnixTimestamps(test.unit)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+
+			// Output decodes back with the same unit
+			if test.unit == 0 {
+				return
+			}
+			dec := NewDecoder(&buf)
+			dec.AllowUnixTimestamps(test.unit)
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			want := input.Created.Truncate(test.unit)
+			if !got.Created.Equal(want) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %s\n- got: %s\n\n", fn, want, got.Created)
+			}
+		})
+	}
+
+	// Units that lose time and timestamps that overflow int64 are errors
+	errorTests := []struct {
+		unit     time.Duration
+		input    Config
+		errormsg string
+	}{
+		{unit: 1500 * time.Millisecond, input: input, errormsg: errInvalidUnit + " [1.5s]"},
+		{unit: time.Nanosecond, input: Config{Created: time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)}, errormsg: errIntegerOverflow},
+	}
+	for _, test := range errorTests {
+		enc := NewEncoder(&bytes.Buffer{})
+		enc.SetUnixTimestamps(test.unit)
+		if err := enc.Encode(test.input); err == nil || !strings.Contains(err.Error(), test.errormsg) {
+			t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+		}
+	}
+}
+
+// logLevel is an enum with a String method, written as text by SetStringers
+type logLevel int
+
+func (l logLevel) String() string {
+	return [...]string{"debug", "info", "warn"}[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for l := logLevel(0); l <= 2; l++ {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+func TestEncoder_SetStringers(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Config struct {
+		Level  logLevel   `toml:"level"`
+		Levels []logLevel `toml:"levels"`
+		Limit  int        `toml:"limit"`
+	}
+	input := Config{Level: 2, Levels: []logLevel{0, 1}, Limit: 10}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected string
+	}{
+		{
+			name:     "numbers by default",
+			enabled:  false,
+			expected: "level = 2\nlevels = [0, 1]\nlimit = 10\n",
+		},
+		{
+			name:     "stringers as text",
+			enabled:  true,
+			expected: "level = \"warn\"\nlevels = [\"debug\", \"info\"]\nlimit = 10\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetStringers(test.enabled)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+
+			// Output decodes back through the enum's parse function
+			if !test.enabled {
+				return
+			}
+			dec := NewDecoder(&buf)
+			RegisterConverter(dec, parseLogLevel)
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(got, input) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %v\n- got: %v\n\n", fn, input, got)
+			}
+		})
+	}
+}
+
+func TestEncoder_SetComplexArrays(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Filter struct {
+		Gain  complex128    `toml:"gain"`
+		Poles []complex64   `toml:"poles"`
+		Pair  [2]complex128 `toml:"pair"`
+		Order int           `toml:"order"`
+	}
+	input := Filter{Gain: complex(1.5, -2), Poles: []complex64{complex(0.5, 0.25)}, Pair: [2]complex128{1i, 2}, Order: 2}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "unsupported by default",
+			enabled:  false,
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+		{
+			name:     "complex as arrays",
+			enabled:  true,
+			expected: "gain = [1.5, -2.0]\norder = 2\npair = [[0.0, 1.0], [2.0, 0.0]]\npoles = [[0.5, 0.25]]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetComplexArrays(test.enabled)
+
+			err := enc.Encode(input)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+
+			// Output decodes back with AllowComplexArrays
+			dec := NewDecoder(&buf)
+			dec.AllowComplexArrays()
+			var got Filter
+			if err := dec.Decode(&got); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(got, input) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %v\n- got: %v\n\n", fn, input, got)
+			}
+		})
+	}
+
+	// Malformed pairs are rejected
+	for _, doc := range []string{"gain = [1.0]", "gain = [1.0, \"x\"]", "gain = 1.0", "pair = [[1, 2]]"} {
+		dec := NewDecoder(strings.NewReader(doc))
+		dec.AllowComplexArrays()
+		var got Filter
+		if err := dec.Decode(&got); err == nil {
+			t.Errorf("-- %s failed: want error for %q but got none\n\n", fn, doc)
+		}
+	}
+}
+
+func TestEncoder_SetSkipNilElements(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Server struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	}
+	type Config struct {
+		Ports   []*int    `toml:"ports"`
+		Servers []*Server `toml:"servers"`
+		Primary *Server   `toml:"primary"`
+		Backup  *Server   `toml:"backup"`
+	}
+	one, two := 80, 443
+	full := Config{
+		Ports:   []*int{&one, &two},
+		Servers: []*Server{{Host: "a", Port: 1}, {Host: "b", Port: 2}},
+		Primary: &Server{Host: "main", Port: 8080},
+	}
+	holes := Config{
+		Ports:   []*int{&one, nil, &two},
+		Servers: []*Server{nil, {Host: "a", Port: 1}},
+	}
+
+	tests := []struct {
+		name     string
+		input    Config
+		skip     bool
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "pointers dereferenced",
+			input:    full,
+			expected: "ports = [80, 443]\n[primary]\nhost = \"main\"\nport = 8080\n[[servers]]\nhost = \"a\"\nport = 1\n[[servers]]\nhost = \"b\"\nport = 2\n",
+		},
+		{
+			name:     "nil elements rejected by default",
+			input:    holes,
+			wantErr:  true,
+			errormsg: errNilValue,
+		},
+		{
+			name:     "nil elements skipped",
+			input:    holes,
+			skip:     true,
+			expected: "ports = [80, 443]\n[[servers]]\nhost = \"a\"\nport = 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetSkipNilElements(test.skip)
+
+			err := enc.Encode(test.input)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+		})
+	}
+
+	// Output decodes back into the pointer fields
+	data, err := Marshal(full)
+	if err != nil {
+		t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+	var got Config
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+	if !reflect.DeepEqual(got, full) {
+		t.Errorf("-- %s failed: wrong roundtrip.\n- want: %+v\n- got: %+v\n\n", fn, full, got)
+	}
+
+	// A nil top-level pointer has nothing to encode
+	if _, err := Marshal((*Config)(nil)); err == nil || !strings.Contains(err.Error(), errNilValue) {
+		t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, errNilValue, err)
+	}
+
+	// Pointer cycles are reported instead of recursing forever
+	type Node struct {
+		Name string `toml:"name"`
+		Next *Node  `toml:"next"`
+	}
+	self := &Node{Name: "a"}
+	self.Next = self
+	pair := &Node{Name: "a", Next: &Node{Name: "b"}}
+	pair.Next.Next = pair
+	loop := map[string]any{"name": "a"}
+	loop["next"] = loop
+	for _, v := range []any{self, pair, loop} {
+		if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), errCycle) {
+			t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, errCycle, err)
+		}
+	}
+
+	// A pointer shared by sibling fields is not a cycle
+	shared := &Server{Host: "main", Port: 8080}
+	if _, err := Marshal(Config{Primary: shared, Backup: shared, Servers: []*Server{shared, shared}}); err != nil {
+		t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+}
+
+func TestEncoder_SetBoolFormat(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Config struct {
+		Debug bool   `toml:"debug"`
+		Cache bool   `toml:"cache"`
+		Flags []bool `toml:"flags"`
+		Level int    `toml:"level"`
+	}
+	input := Config{Debug: true, Flags: []bool{false, true}, Level: 1}
+
+	tests := []struct {
+		name     string
+		format   BoolFormat
+		expected string
+	}{
+		{
+			name:     "lowercase by default",
+			format:   BoolLower,
+			expected: "cache = false\ndebug = true\nflags = [false, true]\nlevel = 1\n",
+		},
+		{
+			name:     "title case",
+			format:   BoolTitle,
+			expected: "cache = False\ndebug = True\nflags = [False, True]\nlevel = 1\n",
+		},
+		{
+			name:     "numeric",
+			format:   BoolNumeric,
+			expected: "cache = 0\ndebug = 1\nflags = [0, 1]\nlevel = 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetBoolFormat(test.format)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+
+			// Output decodes back under the same format
+			dec := NewDecoder(&buf)
+			dec.SetBoolFormat(test.format)
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(got, input) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %v\n- got: %v\n\n", fn, input, got)
+			}
+		})
+	}
+
+	// Alternate spellings are rejected without the option, and numeric
+	// booleans only accept 1 and 0
+	for _, doc := range []struct {
+		input  string
+		format BoolFormat
+	}{
+		{"debug = True", BoolLower},
+		{"debug = 1", BoolLower},
+		{"debug = TRUE", BoolTitle},
+		{"debug = 2", BoolNumeric},
+	} {
+		dec := NewDecoder(strings.NewReader(doc.input))
+		dec.SetBoolFormat(doc.format)
+		var got Config
+		if err := dec.Decode(&got); err == nil {
+			t.Errorf("-- %s failed: want error for %q but got none\n\n", fn, doc.input)
+		}
+	}
+}
+
+func TestEncoder_SetJSONTags(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Pool struct {
+		MaxConns int `json:"max_conns,omitempty"`
+	}
+	type Config struct {
+		Name    string `json:"name"`
+		Port    int    `toml:"listen_port" json:"port"`
+		Secret  string `json:"-"`
+		Enabled bool
+		Pool    Pool `json:"database_pool"`
+	}
+	input := Config{Name: "app", Port: 80, Secret: "x", Enabled: true, Pool: Pool{MaxConns: 5}}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected string
+	}{
+		{
+			name:     "field names by default",
+			enabled:  false,
+			expected: "Enabled = true\nlisten_port = 80\nName = \"app\"\nSecret = \"x\"\n[Pool]\nMaxConns = 5\n",
+		},
+		{
+			name:     "json names",
+			enabled:  true,
+			expected: "Enabled = true\nlisten_port = 80\nname = \"app\"\n[database_pool]\nmax_conns = 5\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetJSONTags(test.enabled)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncoder_SetKeyFunc(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Pool struct {
+		MaxConns int
+	}
+	type Config struct {
+		ServerName string
+		ListenPort int    `toml:"port"`
+		DataDir    string `toml:",section=storage"`
+		UserID     int    `json:"uid"`
+		DBPool     Pool
+	}
+	input := Config{ServerName: "app", ListenPort: 80, DataDir: "/var", UserID: 7, DBPool: Pool{MaxConns: 5}}
+
+	tests := []struct {
+		name     string
+		keyFunc  func(string) string
+		jsonTags bool
+		expected string
+	}{
+		{
+			name:     "field names by default",
+			expected: "port = 80\nServerName = \"app\"\nUserID = 7\n[DBPool]\nMaxConns = 5\n[storage]\nDataDir = \"/var\"\n",
+		},
+		{
+			name:     "snake case",
+			keyFunc:  SnakeCase,
+			expected: "port = 80\nserver_name = \"app\"\nuser_id = 7\n[db_pool]\nmax_conns = 5\n[storage]\ndata_dir = \"/var\"\n",
+		},
+		{
+			name:     "json tags take precedence",
+			keyFunc:  KebabCase,
+			jsonTags: true,
+			expected: "port = 80\nserver-name = \"app\"\nuid = 7\n[db-pool]\nmax-conns = 5\n[storage]\ndata-dir = \"/var\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetKeyFunc(test.keyFunc)
+			enc.SetJSONTags(test.jsonTags)
+
+			if err := enc.Encode(input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+
+			// Output decodes back with the same options
+			dec := NewDecoder(&buf)
+			dec.SetKeyFunc(test.keyFunc)
+			if test.jsonTags {
+				dec.AllowJSONTags()
+			}
+			var got Config
+			if err := dec.Decode(&got); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(got, input) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %+v\n- got: %+v\n\n", fn, input, got)
+			}
+		})
+	}
+}
+
+func TestEncoder_SetTableSpacing(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	input := map[string]any{
+		"name": "app",
+		"a":    map[string]any{"x": 1},
+		"b":    map[string]any{"y": 2},
+	}
+
+	tests := []struct {
+		name        string
+		blankLines  int
+		beforeFirst bool
+		input       any
+		expected    string
+	}{
+		{
+			name:        "default spacing",
+			blankLines:  1,
+			beforeFirst: true,
+			input:       input,
+			expected:    "name = \"app\"\n\n[a]\n  x = 1\n\n[b]\n  y = 2\n",
+		},
+		{
+			name:        "no blank lines",
+			blankLines:  0,
+			beforeFirst: true,
+			input:       input,
+			expected:    "name = \"app\"\n[a]\n  x = 1\n[b]\n  y = 2\n",
+		},
+		{
+			name:        "two blank lines, first table attached",
+			blankLines:  2,
+			beforeFirst: false,
+			input:       input,
+			expected:    "name = \"app\"\n[a]\n  x = 1\n\n\n[b]\n  y = 2\n",
+		},
+		{
+			name:        "no leading blank lines without root keys",
+			blankLines:  2,
+			beforeFirst: true,
+			input:       map[string]any{"a": map[string]any{"x": 1}},
+			expected:    "[a]\n  x = 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetIndent("  ")
+			enc.SetTableSpacing(test.blankLines, test.beforeFirst)
+
+			if err := enc.Encode(test.input); err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %q\n- got: %q\n\n", fn, test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncoder_SetSkipUnsupported(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Runtime struct {
+		Name    string        `toml:"name"`
+		Done    chan struct{} `toml:"done"`
+		OnClose func()        `toml:"on_close"`
+		Handler any           `toml:"handler"`
+		Port    int64         `toml:"server.port"`
+	}
+	input := Runtime{Name: "app", Done: make(chan struct{}), OnClose: func() {}, Handler: func() {}, Port: 80}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		input    any
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "strict by default",
+			enabled:  false,
+			input:    input,
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+		{
+			name:     "struct fields skipped",
+			enabled:  true,
+			input:    input,
+			expected: "name = \"app\"\n[server]\nport = 80\n",
+		},
+		{
+			name:     "map entries skipped",
+			enabled:  true,
+			input:    map[string]any{"a": 1, "cb": func() {}, "ch": make(chan int)},
+			expected: "a = 1\n",
+		},
+		{
+			name:     "array elements still rejected",
+			enabled:  true,
+			input:    map[string]any{"cbs": []any{func() {}}},
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetSkipUnsupported(test.enabled)
+
+			err := enc.Encode(test.input)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if buf.String() != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, buf.String())
+			}
+		})
+	}
+}
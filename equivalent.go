@@ -0,0 +1,36 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"reflect"
+)
+
+// Equivalent reports whether a and b encode to the same TOML document, for
+// asserting config equality in tests. Both values are marshaled and the parsed
+// documents compared, so unexported or runtime fields that Marshal skips are
+// ignored, and a struct equals a map holding the same keys and values.
+// Values that cannot be marshaled are never equivalent.
+func Equivalent(a, b any) bool {
+	docA, err := marshalDocument(a)
+	if err != nil {
+		return false
+	}
+	docB, err := marshalDocument(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(docA, docB)
+}
+
+// marshalDocument marshals v and parses the output back into its generic form
+func marshalDocument(v any) (map[string]any, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return nil, errorf(err)
+	}
+	doc, err := (&Decoder{}).parse(data)
+	if err != nil {
+		return nil, errorf(err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,68 @@
+package tinytoml
+
+import (
+	"testing"
+)
+
+func TestEquivalent(t *testing.T) {
+	type Config struct {
+		Name    string   `toml:"name"`
+		Port    int      `toml:"port"`
+		Tags    []string `toml:"tags"`
+		cache   map[string]string
+		Handler func() `toml:"-"`
+	}
+
+	base := Config{Name: "app", Port: 8080, Tags: []string{"a"}, cache: map[string]string{"k": "v"}}
+
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{
+			name: "unexported and skipped fields ignored",
+			a:    base,
+			b:    Config{Name: "app", Port: 8080, Tags: []string{"a"}, Handler: func() {}},
+			want: true,
+		},
+		{
+			name: "different value",
+			a:    base,
+			b:    Config{Name: "app", Port: 9090, Tags: []string{"a"}},
+			want: false,
+		},
+		{
+			name: "struct and map with the same document",
+			a:    base,
+			b:    map[string]any{"name": "app", "port": int64(8080), "tags": []any{"a"}},
+			want: true,
+		},
+		{
+			name: "integer and float differ",
+			a:    map[string]any{"port": 8080},
+			b:    map[string]any{"port": 8080.0},
+			want: false,
+		},
+		{
+			name: "unsupported values",
+			a:    map[string]any{"ch": make(chan int)},
+			b:    map[string]any{"ch": make(chan int)},
+			want: false,
+		},
+		{
+			name: "nil",
+			a:    nil,
+			b:    nil,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equivalent(tt.a, tt.b); got != tt.want {
+				t.Errorf("Equivalent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
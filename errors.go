@@ -0,0 +1,76 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OverflowError reports an integer literal outside the range of its target.
+// BitSize is 64 for values parsed from the document and the size of the
+// Go field for narrower integer targets. Line is set for literals rejected
+// while parsing and 0 for range checks against the decode target, and for
+// unsigned values too large to marshal as a TOML integer.
+type OverflowError struct {
+	Literal  string
+	BitSize  int
+	Unsigned bool
+	Line     int
+}
+
+// Error describes the offending literal together with the range it must fit
+func (e *OverflowError) Error() string {
+	var bounds string
+	if e.Unsigned {
+		bounds = fmt.Sprintf("min 0, max %d", ^uint64(0)>>(64-e.BitSize))
+	} else {
+		min := int64(-1) << (e.BitSize - 1)
+		bounds = fmt.Sprintf("min %d, max %d", min, ^min)
+	}
+
+	return fmt.Sprintf("%s: %s does not fit in %d bits (%s)", errIntegerOverflow, e.Literal, e.BitSize, bounds)
+}
+
+// ValidationError collects every problem found in a document: type mismatches,
+// unknown keys and missing required keys for ValidateAgainst, and the errors of
+// each failing line for a Decoder with CollectErrors.
+// Each problem is reachable through errors.Is and errors.As via Unwrap.
+type ValidationError struct {
+	Errors []error
+}
+
+// Error lists the problems one per line after a count
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "  * " + err.Error()
+	}
+	return fmt.Sprintf("%d problem(s) found:\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// Unwrap returns the individual problems
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Warning codes reported by a Decoder with CollectWarnings enabled
+const (
+	WarnDuplicateKey   = "duplicate-key"   // a key is assigned again and the last value wins
+	WarnDuplicateTable = "duplicate-table" // a table header repeats and the tables are merged
+	WarnLeadingZero    = "leading-zero"    // a decimal integer has leading zeros (042 reads as 42)
+	WarnUnknownEscape  = "unknown-escape"  // an unknown escape is kept as a literal backslash
+)
+
+// Warning reports a construct that is suspicious but accepted while decoding,
+// such as a key assigned twice. Line is the 1-based line it was found on and
+// Code is one of the Warn constants, for filtering without matching Message.
+type Warning struct {
+	Line    int
+	Code    string
+	Message string
+}
+
+// String formats the warning as "line N: code: message"
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.Line, w.Code, w.Message)
+}
@@ -0,0 +1,118 @@
+package tinytoml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOverflowError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		literal  string
+		line     int
+		errormsg string
+	}{
+		{
+			name:     "decimal above max",
+			input:    "name = \"app\"\n\ncount = 9223372036854775808",
+			literal:  "9223372036854775808",
+			line:     3,
+			errormsg: "does not fit in 64 bits (min -9223372036854775808, max 9223372036854775807) [line 3]",
+		},
+		{
+			name:     "hexadecimal below min",
+			input:    "offset = -0x8000000000000001",
+			literal:  "-0x8000000000000001",
+			line:     1,
+			errormsg: "[line 1]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			err := Unmarshal([]byte(tt.input), &got)
+
+			var overflow *OverflowError
+			if !errors.As(err, &overflow) {
+				t.Fatalf("Unmarshal() error = %v, want *OverflowError", err)
+			}
+			if overflow.Literal != tt.literal || overflow.BitSize != 64 || overflow.Line != tt.line {
+				t.Errorf("OverflowError = %+v, want literal %s, 64 bits, line %d", *overflow, tt.literal, tt.line)
+			}
+			if !strings.Contains(err.Error(), tt.errormsg) {
+				t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+			}
+		})
+	}
+}
+
+func TestOverflowError_NarrowTargets(t *testing.T) {
+	type Config struct {
+		Small  int8    `toml:"small"`
+		Medium int16   `toml:"medium"`
+		Byte   uint8   `toml:"byte"`
+		Flags  []int32 `toml:"flags"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "values in range",
+			input:    "small = -128\nmedium = 32767\nbyte = 255\nflags = [1, -2147483648]",
+			expected: Config{Small: -128, Medium: 32767, Byte: 255, Flags: []int32{1, -2147483648}},
+			wantErr:  false,
+		},
+		{
+			name:     "int8 overflow",
+			input:    "small = 300",
+			wantErr:  true,
+			errormsg: "300 does not fit in 8 bits (min -128, max 127)",
+		},
+		{
+			name:     "uint8 overflow",
+			input:    "byte = 256",
+			wantErr:  true,
+			errormsg: "256 does not fit in 8 bits (min 0, max 255)",
+		},
+		{
+			name:     "negative unsigned",
+			input:    "byte = -1",
+			wantErr:  true,
+			errormsg: "-1 does not fit in 8 bits (min 0, max 255)",
+		},
+		{
+			name:     "int32 overflow in array",
+			input:    "flags = [1, 2147483648]",
+			wantErr:  true,
+			errormsg: "2147483648 does not fit in 32 bits",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			err := Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if got.Small != tt.expected.Small || got.Medium != tt.expected.Medium || got.Byte != tt.expected.Byte || len(got.Flags) != len(tt.expected.Flags) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// escapeSequences maps the character after a backslash to the character it stands for
+var escapeSequences = map[byte]byte{
+	't':  '\t',
+	'n':  '\n',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// EscapeString returns s escaped for use between the double quotes of a TOML
+// basic string: tab, newline, carriage return, quote and backslash become
+// \t, \n, \r, \" and \\. The surrounding quotes are not added.
+func EscapeString(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, c := range s {
+		switch c {
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// UnescapeString is the inverse of EscapeString: it resolves the escape sequences
+// in the content of a TOML basic string, given without its surrounding quotes.
+// An unknown escape, a trailing backslash or an unescaped quote is an error.
+func UnescapeString(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 && strings.IndexByte(s, '"') < 0 {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", errorf(fmt.Errorf(errUnterminatedEscape))
+			}
+			unescaped, ok := escapeSequences[s[i+1]]
+			if !ok {
+				return "", errorf(invalidEscapeError(s[i+1]))
+			}
+			sb.WriteByte(unescaped)
+			i++
+		case '"':
+			return "", errorf(fmt.Errorf(errInvalidString), "unescaped quote")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// invalidEscapeError reports an unknown escape sequence with a hint for the
+// usual cause, Windows paths such as "C:\Users" written with single backslashes
+func invalidEscapeError(c byte) error {
+	return fmt.Errorf(`%s \%c: write a literal backslash as \\ (e.g. "C:\\Users") or allow unknown escapes on the Decoder`, errInvalidEscape, c)
+}
+
+// escapeUnknown doubles the backslash of every unknown escape sequence inside
+// the strings of a line, so "C:\Users" reads as the literal text C:\Users
+// Known escapes and text outside strings are left untouched
+func escapeUnknown(line string) string {
+	if strings.IndexByte(line, '\\') < 0 {
+		return line
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(line) + 4)
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			inString = !inString
+		case inString && c == '\\':
+			if i+1 < len(line) {
+				if _, ok := escapeSequences[line[i+1]]; ok {
+					sb.WriteByte(c)
+					sb.WriteByte(line[i+1])
+					i++
+					continue
+				}
+			}
+			sb.WriteByte('\\') // unknown or trailing escape becomes a literal backslash
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
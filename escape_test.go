@@ -0,0 +1,92 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscapeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "plain text", input: "hello", expected: "hello"},
+		{name: "empty", input: "", expected: ""},
+		{name: "control characters", input: "a\tb\nc\rd", expected: `a\tb\nc\rd`},
+		{name: "quote and backslash", input: `say "C:\"`, expected: `say \"C:\\\"`},
+		{name: "multi-byte characters", input: "café ✓", expected: "café ✓"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EscapeString(tt.input)
+			if got != tt.expected {
+				t.Errorf("EscapeString() = %q, want %q", got, tt.expected)
+			}
+
+			back, err := UnescapeString(got)
+			if err != nil {
+				t.Fatalf("UnescapeString() error = %v", err)
+			}
+			if back != tt.input {
+				t.Errorf("UnescapeString(EscapeString()) = %q, want %q", back, tt.input)
+			}
+		})
+	}
+}
+
+func TestUnescapeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{name: "every escape", input: `\t\n\r\"\\`, expected: "\t\n\r\"\\", wantErr: false},
+		{name: "escaped backslash before letter", input: `C:\\new`, expected: `C:\new`, wantErr: false},
+		{name: "unknown escape", input: `C:\Users`, wantErr: true, errormsg: errInvalidEscape},
+		{name: "trailing backslash", input: `end\`, wantErr: true, errormsg: errUnterminatedEscape},
+		{name: "unescaped quote", input: `a"b`, wantErr: true, errormsg: errInvalidString},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnescapeString(tt.input)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("UnescapeString() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnescapeString() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("UnescapeString() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEscapeString_Roundtrip(t *testing.T) {
+	input := map[string]any{
+		"path":  `C:\Program Files\"App"`,
+		"lines": []any{"a\tb", `quote "x"`, `back\slash`, "new\nline"},
+	}
+
+	data, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got map[string]any
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("roundtrip = %q, want %q", got, input)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/LixenWraith/tinytoml"
 )
@@ -49,4 +51,25 @@ enabled = true
 
 	fmt.Printf("\nJSON output:\n%s\n", jsonBytes)
 	fmt.Printf("\nTOML output:\n%s\n", tomlBytes)
+
+	// A struct tagged for JSON only can be shared as is
+	type Service struct {
+		Name     string `json:"name"`
+		MaxConns int    `json:"max_conns,omitempty"`
+	}
+
+	dec := tinytoml.NewDecoder(strings.NewReader("name = \"api\"\nmax_conns = 20\n"))
+	dec.AllowJSONTags()
+	var service Service
+	if err := dec.Decode(&service); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\nJSON-tagged Config: %+v\n", service)
+
+	enc := tinytoml.NewEncoder(os.Stdout)
+	enc.SetJSONTags(true)
+	fmt.Println("\nJSON-tagged TOML output:")
+	if err := enc.Encode(service); err != nil {
+		log.Fatal(err)
+	}
 }
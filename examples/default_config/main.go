@@ -9,142 +9,109 @@ import (
 )
 
 // ServerConfig represents a typical server application configuration
+// Defaults and documentation live in the struct tags, so the commented
+// config file is generated with MarshalTemplate instead of written by hand
 type ServerConfig struct {
-	// Basic server settings
 	Server struct {
-		Host string `toml:"host"` // Server host address
-		Port int64  `toml:"port"` // Server port number
-		Name string `toml:"name"` // Server instance name
-		Mode string `toml:"mode"` // Running mode (development/production)
-	} `toml:"server"`
+		Host string `toml:"host" default:"localhost" comment:"Server host address"`
+		Port int64  `toml:"port" default:"8080" comment:"Server port number"`
+		Name string `toml:"name" default:"app-server" comment:"Server instance name"`
+		Mode string `toml:"mode" default:"development" comment:"Running mode (development/production)"`
+	} `toml:"server" comment:"Basic server settings"`
 
-	// TLS/SSL configuration
 	TLS struct {
-		Enabled  bool   `toml:"enabled"`   // Enable/disable TLS
-		CertFile string `toml:"cert_file"` // Path to certificate file
-		KeyFile  string `toml:"key_file"`  // Path to private key file
-	} `toml:"tls"`
+		Enabled  bool   `toml:"enabled" comment:"Enable/disable TLS"`
+		CertFile string `toml:"cert_file" default:"cert/server.crt" comment:"Path to certificate file"`
+		KeyFile  string `toml:"key_file" default:"cert/server.key" comment:"Path to private key file"`
+	} `toml:"tls" comment:"TLS/SSL configuration"`
 
-	// Database connection settings
 	Database struct {
-		Host     string `toml:"host"`     // Database host
-		Port     int64  `toml:"port"`     // Database port
-		Name     string `toml:"name"`     // Database name
-		User     string `toml:"user"`     // Database user
-		Password string `toml:"password"` // Database password
+		Host     string `toml:"host" default:"localhost" comment:"Database host"`
+		Port     int64  `toml:"port" default:"5432" comment:"Database port"`
+		Name     string `toml:"name" default:"appdb" comment:"Database name"`
+		User     string `toml:"user" default:"dbuser" comment:"Database user"`
+		Password string `toml:"password" default:"dbpass" comment:"Database password"`
 		Pool     struct {
-			MaxOpen int64 `toml:"max_open"` // Maximum open connections
-			MaxIdle int64 `toml:"max_idle"` // Maximum idle connections
+			MaxOpen int64 `toml:"max_open" default:"10" comment:"Maximum open connections"`
+			MaxIdle int64 `toml:"max_idle" default:"5" comment:"Maximum idle connections"`
 		} `toml:"pool"`
-	} `toml:"database"`
+	} `toml:"database" comment:"Database connection settings"`
 
-	// API related settings
 	API struct {
-		Prefix      string   `toml:"prefix"`       // API route prefix
-		Timeout     int64    `toml:"timeout"`      // Request timeout in seconds
-		RateLimit   int64    `toml:"rate_limit"`   // Requests per minute
-		CorsOrigins []string `toml:"cors_origins"` // Allowed CORS origins
-	} `toml:"api"`
-}
-
-// getDefaultConfig returns a ServerConfig with default values
-func getDefaultConfig() ServerConfig {
-	config := ServerConfig{}
-
-	// Set default values for server
-	config.Server.Host = "localhost"
-	config.Server.Port = 8080
-	config.Server.Name = "app-server"
-	config.Server.Mode = "development"
-
-	// Set default TLS configuration
-	config.TLS.Enabled = false
-	config.TLS.CertFile = "cert/server.crt"
-	config.TLS.KeyFile = "cert/server.key"
-
-	// Set default database configuration
-	config.Database.Host = "localhost"
-	config.Database.Port = 5432
-	config.Database.Name = "appdb"
-	config.Database.User = "dbuser"
-	config.Database.Password = "dbpass"
-	config.Database.Pool.MaxOpen = 10
-	config.Database.Pool.MaxIdle = 5
-
-	// Set default API configuration
-	config.API.Prefix = "/api/v1"
-	config.API.Timeout = 30
-	config.API.RateLimit = 100
-	config.API.CorsOrigins = []string{"http://localhost:3000", "https://app.example.com"}
-
-	return config
+		Prefix      string   `toml:"prefix" default:"/api/v1" comment:"API route prefix"`
+		Timeout     int64    `toml:"timeout" default:"30" comment:"Request timeout in seconds"`
+		RateLimit   int64    `toml:"rate_limit" default:"100" comment:"Requests per minute"`
+		CorsOrigins []string `toml:"cors_origins" default:"[\"http://localhost:3000\", \"https://app.example.com\"]" comment:"Allowed CORS origins"`
+	} `toml:"api" comment:"API related settings"`
 }
 
 func main() {
 	const configFile = "./examples/default_config/config.toml"
-	var config ServerConfig
 
-	// Check if config file exists and load it
-	if data, err := os.ReadFile(configFile); err == nil {
-		fmt.Println("Loading existing config file...")
-		if err := tinytoml.Unmarshal(data, &config); err != nil {
-			log.Fatalf("Failed to parse config file: %v", err)
+	// Generate a commented config file with default values on first run
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		fmt.Println("Config file not found, writing default template...")
+		data, err := tinytoml.MarshalTemplate(ServerConfig{})
+		if err != nil {
+			log.Fatalf("Failed to generate config template: %v", err)
+		}
+		// Written atomically, so an interrupted first run never leaves a truncated config
+		if err := tinytoml.WriteFile(configFile, data, tinytoml.SaveOptions{Perm: 0644, Sync: true}); err != nil {
+			log.Fatalf("Failed to write config file: %v", err)
 		}
-	} else {
-		fmt.Println("Config file not found, using default values...")
-		config = getDefaultConfig()
 	}
 
-	// Write config to file
-	data, err := tinytoml.Marshal(config)
+	data, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Fatalf("Failed to marshal config: %v", err)
+		log.Fatalf("Failed to read config file: %v", err)
 	}
 
-	if err := os.WriteFile(configFile, data, 0644); err != nil {
-		log.Fatalf("Failed to write config file: %v", err)
+	var config ServerConfig
+	if err := tinytoml.Unmarshal(data, &config); err != nil {
+		log.Fatalf("Failed to parse config file: %v", err)
 	}
-	fmt.Printf("Configuration saved to %s\n", configFile)
+	fmt.Printf("Loaded %s: serving %s on %s:%d\n", configFile, config.Server.Name, config.Server.Host, config.Server.Port)
 }
 
 /* Example output for first run (no config file):
-Config file not found, using default values...
-Configuration saved to config.toml
+Config file not found, writing default template...
+Loaded ./examples/default_config/config.toml: serving app-server on localhost:8080
 
 Example output for subsequent runs (with existing config):
-Loading existing config file...
-Configuration saved to config.toml
+Loaded ./examples/default_config/config.toml: serving app-server on localhost:8080
 
 Generated config.toml will contain:
+# Basic server settings
 [server]
-host = "localhost"
-port = 8080
-name = "app-server"
-mode = "development"
+host = "localhost" # Server host address
+port = 8080 # Server port number
+name = "app-server" # Server instance name
+mode = "development" # Running mode (development/production)
 
+# TLS/SSL configuration
 [tls]
-enabled = false
-cert_file = "cert/server.crt"
-key_file = "cert/server.key"
+enabled = false # Enable/disable TLS
+cert_file = "cert/server.crt" # Path to certificate file
+key_file = "cert/server.key" # Path to private key file
 
+# Database connection settings
 [database]
-host = "localhost"
-port = 5432
-name = "appdb"
-user = "dbuser"
-password = "dbpass"
+host = "localhost" # Database host
+port = 5432 # Database port
+name = "appdb" # Database name
+user = "dbuser" # Database user
+password = "dbpass" # Database password
 
 [database.pool]
-max_open = 10
-max_idle = 5
+max_open = 10 # Maximum open connections
+max_idle = 5 # Maximum idle connections
 
+# API related settings
 [api]
-prefix = "/api/v1"
-timeout = 30
-rate_limit = 100
-cors_origins = ["http://localhost:3000", "https://app.example.com"]
-
-If you modify config.toml and remove some fields,
-the next run will restore them with default values
-while preserving any customized values you set.
+prefix = "/api/v1" # API route prefix
+timeout = 30 # Request timeout in seconds
+rate_limit = 100 # Requests per minute
+cors_origins = ["http://localhost:3000", "https://app.example.com"] # Allowed CORS origins
+
+Edit config.toml to change settings; delete it to regenerate the defaults.
 */
@@ -75,4 +75,15 @@ licenses.used = 15`
 	if err := tinytoml.Unmarshal(output, &verified); err != nil {
 		log.Fatalf("Verification unmarshal failed: %v", err)
 	}
+
+	// Read typed arrays from the schemaless result
+	hosts, err := tinytoml.AsStringSlice(verified["hosts"])
+	if err != nil {
+		log.Fatalf("Reading hosts failed: %v", err)
+	}
+	ports, err := tinytoml.AsIntSlice(verified["ports"])
+	if err != nil {
+		log.Fatalf("Reading ports failed: %v", err)
+	}
+	fmt.Printf("Hosts: %v, ports: %v\n", hosts, ports)
 }
@@ -0,0 +1,90 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultFilePerm is the permission of files created by SaveFile and WriteFile
+// when no mode is given
+const defaultFilePerm os.FileMode = 0644
+
+// SaveOptions controls how SaveFile and WriteFile write a config file
+type SaveOptions struct {
+	// Perm is the permission of the written file. When zero, an existing file
+	// keeps its permission and a new one gets 0644.
+	Perm os.FileMode
+	// Sync flushes the file and its directory to stable storage before
+	// returning, so the new content survives a power loss.
+	Sync bool
+}
+
+// SaveFile marshals v like Marshal and writes it to path with WriteFile,
+// replacing the file atomically.
+func SaveFile(path string, v any, opts SaveOptions) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return errorf(err)
+	}
+	if err := WriteFile(path, data, opts); err != nil {
+		return errorf(err)
+	}
+	return nil
+}
+
+// WriteFile atomically replaces the file at path with data, such as the
+// output of MarshalIndent or MarshalTemplate. The data is written to a
+// temporary file in the same directory, which is then renamed over path, so
+// a crash mid-write leaves either the old or the new file, never a truncated one.
+func WriteFile(path string, data []byte, opts SaveOptions) error {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = defaultFilePerm
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errorf(err, "path", path)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if tmpName != "" {
+			os.Remove(tmpName) // only left behind when a step failed
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errorf(err, "path", path)
+	}
+	if opts.Sync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return errorf(err, "path", path)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return errorf(err, "path", path)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return errorf(err, "path", path)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return errorf(err, "path", path)
+	}
+	tmpName = ""
+
+	if opts.Sync {
+		// Persist the rename; best effort, as some platforms cannot sync a directory
+		if d, err := os.Open(dir); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package tinytoml
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSaveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+
+	type Config struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+	}
+
+	if err := SaveFile(path, Config{Name: "app", Port: 8080}, SaveOptions{Perm: 0600, Sync: true}); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "name = \"app\"\nport = 8080\n" {
+		t.Errorf("SaveFile() wrote %q", data)
+	}
+
+	// Saving again replaces the content and keeps the existing permission
+	if err := SaveFile(path, Config{Name: "other"}, SaveOptions{}); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	var got Config
+	data, _ = os.ReadFile(path)
+	if err := Unmarshal(data, &got); err != nil || got.Name != "other" {
+		t.Errorf("SaveFile() wrote %q, error = %v", data, err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("SaveFile() mode = %v, error = %v, want 0600", info.Mode().Perm(), err)
+		}
+	}
+
+	// A failed marshal leaves the old file untouched and no temporary files behind
+	if err := SaveFile(path, make(chan int), SaveOptions{}); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("SaveFile() error = %v, want error containing %v", err, errUnsupported)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the config file", len(entries))
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "new.toml")
+
+	if err := WriteFile(path, []byte("a = 1\n"), SaveOptions{}); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != defaultFilePerm {
+			t.Errorf("WriteFile() mode = %v, error = %v, want %v", info.Mode().Perm(), err, defaultFilePerm)
+		}
+	}
+
+	// The rename fails onto a directory, and the temporary file is cleaned up
+	target := filepath.Join(dir, "sub")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "keep"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(target, []byte("a = 1\n"), SaveOptions{}); err == nil {
+		t.Errorf("WriteFile() onto a directory error = nil, want error")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d entries, want the file and subdirectory only", len(entries))
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "x.toml"), nil, SaveOptions{}); err == nil {
+		t.Errorf("WriteFile() into a missing directory error = nil, want error")
+	}
+}
@@ -0,0 +1,203 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// MarshalIndent is like Marshal but formats the output for readability.
+// Each table section, including array of tables blocks ([[name]]), is preceded
+// by a blank line. Headers are indented by indent once per nesting level below
+// the root, and keys are indented one level deeper than their header.
+// Arrays whose line would exceed 80 characters are split one element per line;
+// shorter arrays such as pair = [1, 2] stay inline.
+func MarshalIndent(v any, indent string) ([]byte, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return data, errorf(err)
+	}
+	return formatTOML(data, newFormatOptions(indent)), nil
+}
+
+// MarshalAligned is like Marshal but pads keys so the = signs of each table
+// section line up in one column, as in hand-formatted configs. Alignment is
+// reset at every table header, and the padding is plain whitespace, so the
+// output parses to the same document.
+func MarshalAligned(v any) ([]byte, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return data, errorf(err)
+	}
+	return alignTOML(data), nil
+}
+
+// alignTOML is the line-oriented pass behind MarshalAligned.
+// It expects the one-statement-per-line output produced by Marshal.
+func alignTOML(data []byte) []byte {
+	var buf bytes.Buffer
+	var section []string
+
+	// flush writes the key-value lines of one section, padded to its longest key
+	flush := func() {
+		width := 0
+		for _, line := range section {
+			if key, _, ok := splitKeyValue(line); ok {
+				width = max(width, utf8.RuneCountInString(key))
+			}
+		}
+		for _, line := range section {
+			if key, value, ok := splitKeyValue(line); ok {
+				buf.WriteString(key)
+				buf.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(key)))
+				buf.WriteString(" = ")
+				buf.WriteString(value)
+			} else {
+				buf.WriteString(line)
+			}
+			buf.WriteString("\n")
+		}
+		section = section[:0]
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			flush()
+			buf.WriteString(line)
+			buf.WriteString("\n")
+			continue
+		}
+		section = append(section, line)
+	}
+	flush()
+
+	return buf.Bytes()
+}
+
+// splitKeyValue splits a "key = value" line at the first = outside a quoted key
+func splitKeyValue(line string) (string, string, bool) {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(line[i:], " = "):
+			return line[:i], line[i+3:], true
+		}
+	}
+	return "", "", false
+}
+
+// formatOptions controls the layout produced by formatTOML
+type formatOptions struct {
+	indent       string // indentation unit per nesting level
+	arrayWidth   int    // arrays on lines longer than this are split per element, 0 keeps all arrays inline
+	tableSpacing int    // blank lines before each table header
+	spaceFirst   bool   // separate the first header from the root keys above it
+}
+
+// defaultArrayWidth is the line width above which MarshalIndent splits arrays
+const defaultArrayWidth = 80
+
+// newFormatOptions returns the default MarshalIndent layout for indent:
+// one blank line before every table header that follows other output,
+// and arrays split only when their line is longer than defaultArrayWidth
+func newFormatOptions(indent string) formatOptions {
+	return formatOptions{indent: indent, arrayWidth: defaultArrayWidth, tableSpacing: 1, spaceFirst: true}
+}
+
+// formatTOML is the line-oriented formatting pass behind MarshalIndent.
+// It expects the one-statement-per-line output produced by Marshal.
+func formatTOML(data []byte, opts formatOptions) []byte {
+	var buf bytes.Buffer
+	depth := 0
+	headers := 0
+
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			depth = headerDepth(line)
+			// Output never starts with blank lines
+			if buf.Len() > 0 && (headers > 0 || opts.spaceFirst) {
+				buf.WriteString(strings.Repeat("\n", opts.tableSpacing))
+			}
+			headers++
+			buf.WriteString(strings.Repeat(opts.indent, depth-1))
+			buf.WriteString(line)
+			buf.WriteString("\n")
+			continue
+		}
+
+		prefix := strings.Repeat(opts.indent, depth)
+		if opts.arrayWidth > 0 && len(prefix)+len(line) > opts.arrayWidth {
+			if key, elements, ok := splitArrayLine(line); ok {
+				buf.WriteString(prefix)
+				buf.WriteString(key)
+				buf.WriteString(" = [\n")
+				for _, elem := range elements {
+					buf.WriteString(prefix)
+					buf.WriteString(opts.indent)
+					buf.WriteString(elem)
+					buf.WriteString(",\n")
+				}
+				buf.WriteString(prefix)
+				buf.WriteString("]\n")
+				continue
+			}
+		}
+
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+// splitArrayLine splits a "key = [...]" line into its key and top-level array elements
+// Returns false for non-array values and arrays with fewer than two elements
+func splitArrayLine(line string) (string, []string, bool) {
+	key, value, found := splitKeyValue(line)
+	if !found || !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return "", nil, false
+	}
+
+	elements := splitArrayElements(value[1 : len(value)-1])
+	if len(elements) < 2 {
+		return "", nil, false
+	}
+	return key, elements, true
+}
+
+// headerDepth returns the number of path segments in a table or array of tables header
+// Dots inside quoted segments do not count as separators
+func headerDepth(header string) int {
+	name := strings.TrimSpace(strings.Trim(header, "[]"))
+	depth := 1
+	inString := false
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '.':
+			if !inString {
+				depth++
+			}
+		}
+	}
+	return depth
+}
@@ -0,0 +1,351 @@
+package tinytoml
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMarshalIndent(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	tests := []struct {
+		name     string
+		input    any
+		indent   string
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "flat map",
+			input:    map[string]any{"name": "app", "port": 8080},
+			indent:   "  ",
+			expected: "name = \"app\"\nport = 8080\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "nested tables",
+			input: map[string]any{
+				"name": "app",
+				"server": map[string]any{
+					"host": "localhost",
+					"tls": map[string]any{
+						"enabled": true,
+					},
+				},
+				"database": map[string]any{
+					"port": 5432,
+				},
+			},
+			indent: "  ",
+			expected: `name = "app"
+
+[database]
+  port = 5432
+
+[server]
+  host = "localhost"
+
+  [server.tls]
+    enabled = true
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "strings are always quoted",
+			input: map[string]any{
+				"bare_str": "simple",
+				"server": map[string]any{
+					"host":  "localhost",
+					"flag":  "true",
+					"port":  "8080",
+					"empty": "",
+				},
+			},
+			indent: "  ",
+			expected: `bare_str = "simple"
+
+[server]
+  empty = ""
+  flag = "true"
+  host = "localhost"
+  port = "8080"
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "only long arrays split",
+			input: map[string]any{
+				"pair": []int{1, 2},
+				"server": map[string]any{
+					"hosts": []string{"alpha.example.com", "beta.example.com", "gamma.example.com", "delta.example.com"},
+				},
+			},
+			indent: "  ",
+			expected: `pair = [1, 2]
+
+[server]
+  hosts = [
+    "alpha.example.com",
+    "beta.example.com",
+    "gamma.example.com",
+    "delta.example.com",
+  ]
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "unsupported type",
+			input:    make(chan int),
+			indent:   "  ",
+			expected: "",
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := MarshalIndent(test.input, test.indent)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("-- %s failed: want error but got none.\n- input: %v\n- want: %s\n- got : %s\n\n", fn, test.input, test.expected, result)
+					return
+				}
+
+				if !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: got wrong error.\n- input: %v\n- want: %s\n- got: %s\n- error: %s\n\n", fn, test.input, test.expected, result, err.Error())
+					return
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- input: %v\n- want: %s\n- got : %s\n- error: %s\n\n", fn, test.input, test.expected, result, err.Error())
+				return
+			}
+
+			if string(result) != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- input: %v\n- want: %s\n- got: %s\n\n", fn, test.input, test.expected, result)
+				return
+			}
+
+			// Formatting must not change the parsed document
+			var plain, pretty map[string]any
+			raw, _ := Marshal(test.input)
+			if err := Unmarshal(raw, &plain); err != nil {
+				t.Errorf("-- %s failed: plain output does not parse: %s\n", fn, err.Error())
+				return
+			}
+			if err := Unmarshal(result, &pretty); err != nil {
+				t.Errorf("-- %s failed: indented output does not parse: %s\n", fn, err.Error())
+				return
+			}
+			if !reflect.DeepEqual(plain, pretty) {
+				t.Errorf("-- %s failed: indented output differs.\n- plain: %v\n- pretty: %v\n\n", fn, plain, pretty)
+			}
+		})
+	}
+}
+
+func TestMarshalIndent_Quoting(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	input := map[string]any{
+		"simple": "simple",
+		"spaced": "two words",
+		"number": "42",
+		"escape": "tab\there \"quoted\" back\\slash",
+		"server": map[string]any{
+			"host":  "localhost",
+			"hosts": []string{"a", "b c"},
+		},
+	}
+
+	plain, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("-- %s failed: Marshal error: %s\n", fn, err.Error())
+	}
+	pretty, err := MarshalIndent(input, "    ")
+	if err != nil {
+		t.Fatalf("-- %s failed: MarshalIndent error: %s\n", fn, err.Error())
+	}
+
+	// Apart from layout, both paths must emit byte-identical key-value lines
+	lines := func(data []byte) []string {
+		var result []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				result = append(result, line)
+			}
+		}
+		return result
+	}
+	if !reflect.DeepEqual(lines(plain), lines(pretty)) {
+		t.Errorf("-- %s failed: quoting differs.\n- Marshal: %q\n- MarshalIndent: %q\n\n", fn, lines(plain), lines(pretty))
+	}
+}
+
+func TestMarshal_QuotedKeySites(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	// Keys that are not bare keys, including one containing " = ", are quoted
+	// the same way wherever a key is written: key-value lines, table headers
+	// and array-of-tables headers, in every layout
+	long := strings.Repeat("x", 40)
+	input := map[string]any{
+		"a b":   int64(1),
+		"k = v": []any{long, long},
+		"my table": map[string]any{
+			"c d": "s = t",
+			"rows": []any{
+				map[string]any{"e f": true},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		marshal  func(any) ([]byte, error)
+		expected string
+	}{
+		{
+			name:     "Marshal",
+			marshal:  Marshal,
+			expected: "\"a b\" = 1\n\"k = v\" = [\"" + long + "\", \"" + long + "\"]\n[\"my table\"]\n\"c d\" = \"s = t\"\n[[\"my table\".rows]]\n\"e f\" = true\n",
+		},
+		{
+			name:     "MarshalAligned",
+			marshal:  MarshalAligned,
+			expected: "\"a b\"   = 1\n\"k = v\" = [\"" + long + "\", \"" + long + "\"]\n[\"my table\"]\n\"c d\" = \"s = t\"\n[[\"my table\".rows]]\n\"e f\" = true\n",
+		},
+		{
+			name:     "MarshalIndent",
+			marshal:  func(v any) ([]byte, error) { return MarshalIndent(v, "  ") },
+			expected: "\"a b\" = 1\n\"k = v\" = [\n  \"" + long + "\",\n  \"" + long + "\",\n]\n\n[\"my table\"]\n  \"c d\" = \"s = t\"\n\n  [[\"my table\".rows]]\n    \"e f\" = true\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := test.marshal(input)
+			if err != nil {
+				t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+			}
+			if string(output) != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, output)
+			}
+
+			var got map[string]any
+			if err := Unmarshal(output, &got); err != nil {
+				t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+			}
+			if !reflect.DeepEqual(got, input) {
+				t.Errorf("-- %s failed: wrong roundtrip.\n- want: %v\n- got: %v\n\n", fn, input, got)
+			}
+		})
+	}
+}
+
+func TestMarshalAligned(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	input := map[string]any{
+		"name":    "app",
+		"version": 2,
+		"a = b":   true,
+		"server": map[string]any{
+			"host":      "localhost",
+			"port":      8080,
+			"max_conns": 100,
+		},
+		"servers": []map[string]any{
+			{"name": "alpha", "weight": 1},
+			{"id": 2},
+		},
+	}
+	expected := `"a = b" = true
+name    = "app"
+version = 2
+[server]
+host      = "localhost"
+max_conns = 100
+port      = 8080
+[[servers]]
+name   = "alpha"
+weight = 1
+[[servers]]
+id = 2
+`
+
+	result, err := MarshalAligned(input)
+	if err != nil {
+		t.Fatalf("-- %s failed: MarshalAligned error: %s\n", fn, err.Error())
+	}
+	if string(result) != expected {
+		t.Errorf("-- %s failed: wrong result.\n- want: %q\n- got: %q\n\n", fn, expected, result)
+	}
+
+	// The padding must not change the parsed document
+	plain, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("-- %s failed: Marshal error: %s\n", fn, err.Error())
+	}
+	var want, got map[string]any
+	if err := Unmarshal(plain, &want); err != nil {
+		t.Fatalf("-- %s failed: Unmarshal error: %s\n", fn, err.Error())
+	}
+	if err := Unmarshal(result, &got); err != nil {
+		t.Fatalf("-- %s failed: Unmarshal aligned error: %s\n", fn, err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("-- %s failed: reparse differs.\n- want: %v\n- got: %v\n\n", fn, want, got)
+	}
+}
+
+func Test_formatTOML(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "array of tables blocks",
+			input:    "title = \"fleet\"\n[[servers]]\nname = \"alpha\"\n[[servers]]\nname = \"beta\"\n[servers.tls]\nenabled = true\n",
+			expected: "title = \"fleet\"\n\n[[servers]]\n\tname = \"alpha\"\n\n[[servers]]\n\tname = \"beta\"\n\n\t[servers.tls]\n\t\tenabled = true\n",
+		},
+		{
+			name:     "quoted header segment",
+			input:    "[\"a.b\"]\nx = 1\n[\"a.b\".c]\ny = 2\n",
+			expected: "[\"a.b\"]\n\tx = 1\n\n\t[\"a.b\".c]\n\t\ty = 2\n",
+		},
+		{
+			name:     "leading table",
+			input:    "[server]\nport = 80\n",
+			expected: "[server]\n\tport = 80\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(formatTOML([]byte(test.input), newFormatOptions("\t")))
+			if result != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- input: %v\n- want: %q\n- got: %q\n\n", fn, test.input, test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,1020 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	int64SliceType   = reflect.TypeOf([]int64(nil))
+	float64SliceType = reflect.TypeOf([]float64(nil))
+	float32SliceType = reflect.TypeOf([]float32(nil))
+)
+
+// builtinHooks returns the decode hooks the Decoder applies after any
+// user-registered hooks when decoding into a value of type t
+// Hooks that rewrite a table work on a copy of it, so the parsed document is
+// left untouched and can be decoded again
+func (d *Decoder) builtinHooks(t reflect.Type) []mapstructure.DecodeHookFunc {
+	var hooks []mapstructure.DecodeHookFunc
+	if d.indexTables {
+		// Ahead of the rest, so they see the assembled arrays
+		hooks = append(hooks, indexTableHook)
+	}
+	if d.useNumber {
+		// First of the built-in hooks, so the rest see int64 and float64 as usual
+		// User hooks run before it and still see Number
+		hooks = append(hooks, numberHook)
+	}
+	if d.foldBlank {
+		// Next, so the hooks after it see the folded values
+		hooks = append(hooks, d.blankStringHook)
+	}
+	if typeHasOption(t, "remaining") {
+		// Runs before the hooks that rename or add keys, on the table as parsed
+		hooks = append(hooks, d.remainingHook)
+	}
+	if d.jsonTags || d.keyFunc != nil {
+		hooks = append(hooks, d.fieldNameHook)
+	}
+	hooks = append(hooks,
+		typedSliceHook,
+		d.inlineTagHook,
+		d.dottedTagHook,
+		intRangeHook,
+		unquotedStringHook,
+		d.arrayLengthHook,
+	)
+	if d.boolAliases {
+		hooks = append(hooks, boolAliasHook)
+	}
+	if d.boolFormat == BoolNumeric {
+		hooks = append(hooks, numericBoolHook)
+	}
+	if d.runeStrings {
+		hooks = append(hooks, runeStringHook)
+	}
+	if d.unixUnit > 0 {
+		hooks = append(hooks, d.unixTimeHook)
+	}
+	if d.complexArrays {
+		hooks = append(hooks, d.complexHook)
+	}
+	return hooks
+}
+
+// indexTableHook assembles a table whose keys are all array indices into an
+// array when it decodes into a slice or array; other targets keep the table,
+// so a map read from [ports] with "80" = "http" is left as it is
+func indexTableHook(from, to reflect.Type, data any) (any, error) {
+	if to.Kind() != reflect.Slice && to.Kind() != reflect.Array {
+		return data, nil
+	}
+	table, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+	if elems, ok, err := indexArray(table); err != nil || ok {
+		return elems, err
+	}
+	return data, nil
+}
+
+// typedSliceHook decodes parsed arrays straight into []int64, []float64 and []float32
+// targets, skipping mapstructure's per-element conversion and naming the offending element
+// Integer elements are promoted when the target is a float slice, so arrays mixing
+// whole and fractional numbers (vals = [1, 2.5, 3]) decode without error
+func typedSliceHook(from, to reflect.Type, data any) (any, error) {
+	elems, ok := data.([]any)
+	if !ok {
+		return data, nil
+	}
+
+	switch to {
+	case int64SliceType:
+		result := make([]int64, len(elems))
+		for i, elem := range elems {
+			v, ok := elem.(int64)
+			if !ok {
+				return nil, errorf(fmt.Errorf(errInvalidInteger), fmt.Sprintf("element %d", i), fmt.Sprintf("got %T", elem))
+			}
+			result[i] = v
+		}
+		return result, nil
+	case float64SliceType:
+		result := make([]float64, len(elems))
+		for i, elem := range elems {
+			switch v := elem.(type) {
+			case float64:
+				result[i] = v
+			case int64:
+				result[i] = float64(v)
+			default:
+				return nil, errorf(fmt.Errorf(errInvalidFloat), fmt.Sprintf("element %d", i), fmt.Sprintf("got %T", elem))
+			}
+		}
+		return result, nil
+	case float32SliceType:
+		result := make([]float32, len(elems))
+		for i, elem := range elems {
+			switch v := elem.(type) {
+			case float64:
+				result[i] = float32(v)
+			case int64:
+				result[i] = float32(v)
+			default:
+				return nil, errorf(fmt.Errorf(errInvalidFloat), fmt.Sprintf("element %d", i), fmt.Sprintf("got %T", elem))
+			}
+		}
+		return result, nil
+	default:
+		return data, nil
+	}
+}
+
+// numberHook converts the Number values stored by UseNumber back to int64 and
+// float64 for every target except Number and interfaces, which keep the text
+// Arrays are converted one level deep for typed slice targets; mapstructure
+// decodes nested arrays and tables element by element through the hooks again
+func numberHook(from, to reflect.Type, data any) (any, error) {
+	if to == numberType || to.Kind() == reflect.Interface {
+		return data, nil
+	}
+
+	switch v := data.(type) {
+	case Number:
+		return v.value()
+	case []any:
+		if (to.Kind() == reflect.Slice || to.Kind() == reflect.Array) && to.Elem().Kind() == reflect.Interface {
+			return data, nil
+		}
+		var converted []any
+		for i, elem := range v {
+			n, ok := elem.(Number)
+			if !ok {
+				continue
+			}
+			if converted == nil {
+				converted = slices.Clone(v)
+			}
+			value, err := n.value()
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = value
+		}
+		if converted != nil {
+			return converted, nil
+		}
+	}
+	return data, nil
+}
+
+// inlineTagHook lets struct fields tagged ",inline" decode from the keys of the
+// enclosing table, reversing how marshalStruct flattens them
+// Each inline struct receives the keys its fields claim, which leave the
+// enclosing table unless one of its own fields claims them as well
+func (d *Decoder) inlineTagHook(from, to reflect.Type, data any) (any, error) {
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to == timeType {
+		return data, nil
+	}
+
+	var result map[string]any
+	var own []string // keys claimed by the fields that are not inline
+	taken := map[string]bool{}
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		tag := d.fieldTag(field)
+		if tag.skip {
+			continue
+		}
+		if !tag.has("inline") || !isStructType(field.Type) {
+			own = append(own, fieldKeys(tag)...)
+			continue
+		}
+		inline := field.Type
+		if inline.Kind() == reflect.Pointer {
+			inline = inline.Elem()
+		}
+		claimed := d.claimedKeys(inline)
+		lifted := map[string]any{}
+		for key, value := range m {
+			if key == rawKey || slices.ContainsFunc(claimed, func(name string) bool { return d.matchName(key, name) }) {
+				lifted[key] = value
+				taken[key] = true
+			}
+		}
+		if result == nil {
+			result = maps.Clone(m)
+		}
+		result[tag.key] = lifted
+	}
+	if result == nil {
+		return data, nil
+	}
+
+	for key := range taken {
+		if !slices.ContainsFunc(own, func(name string) bool { return d.matchName(key, name) }) {
+			delete(result, key)
+		}
+	}
+	return result, nil
+}
+
+// rawKey holds the source sections of a parsed table, as rawSections, while
+// decoding into a target with ",raw" fields; documents have no reason to use
+// a key starting with a NUL byte. rawTableHook takes it out of each table
+// before the other hooks run, unless inline structs need it passed on.
+const rawKey = "\x00raw"
+
+// rawSections are the sections of the source that define a table
+type rawSections [][]byte
+
+// rawTableHook fills struct fields tagged ",raw" with the source text of the
+// table being decoded: its header line and the lines up to the next header,
+// or the lines before the first header for the document root. A table defined
+// by several headers gets each of its sections, a table that never had a
+// header of its own (created by a dotted key) gets an empty text, and an
+// inline struct gets the text of the enclosing table.
+// Tables decoded into other targets have the sections removed.
+func (d *Decoder) rawTableHook(from, to reflect.Type, data any) (any, error) {
+	if to.Kind() == reflect.Interface {
+		return stripRaw(data), nil
+	}
+	m, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+	sections, ok := m[rawKey].(rawSections)
+	if ok {
+		m = maps.Clone(m)
+		delete(m, rawKey)
+	}
+	if to.Kind() != reflect.Struct || to == timeType {
+		return m, nil
+	}
+
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		tag := parseTag(field)
+		if tag.skip {
+			continue
+		}
+		if tag.has("inline") && isStructType(field.Type) && ok {
+			m[rawKey] = sections // passed on by inlineTagHook
+			continue
+		}
+		if !tag.has("raw") {
+			continue
+		}
+		text := bytes.Join(sections, []byte("\n"))
+		switch {
+		case field.Type.Kind() == reflect.String:
+			m[tag.key] = string(text)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8:
+			m[tag.key] = text
+		default:
+			return nil, errorf(fmt.Errorf(errUnsupported), "raw field "+field.Name, "type", field.Type.String(), "want string or []byte")
+		}
+	}
+	return m, nil
+}
+
+// addRaw adds a section of the source, without its surrounding blank lines, to
+// the sections a table keeps under rawKey; the section is copied so decoded
+// values hold no reference to the input
+func addRaw(table map[string]any, section []byte) {
+	section = bytes.TrimRight(bytes.TrimLeft(section, "\r\n"), " \t\r\n")
+	if len(section) > 0 {
+		sections, _ := table[rawKey].(rawSections)
+		table[rawKey] = append(sections, bytes.Clone(section))
+	}
+}
+
+// stripRaw returns a copy of a parsed value with the source sections removed
+// from its tables, for interface targets that store the value as it is
+func stripRaw(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		stripped := make(map[string]any, len(v))
+		for key, elem := range v {
+			if key != rawKey {
+				stripped[key] = stripRaw(elem)
+			}
+		}
+		return stripped
+	case []any:
+		stripped := make([]any, len(v))
+		for i, elem := range v {
+			stripped[i] = stripRaw(elem)
+		}
+		return stripped
+	}
+	return v
+}
+
+// remainingHook fills a map field tagged ",remaining" with the keys of the table
+// that no other field of the struct decodes from, the toml equivalent of
+// mapstructure's ",remain"; typed fields keep the keys they know, so known and
+// unknown keys are decoded in one pass
+func (d *Decoder) remainingHook(from, to reflect.Type, data any) (any, error) {
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to == timeType {
+		return data, nil
+	}
+
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		if tag := parseTag(field); tag.skip || !tag.has("remaining") {
+			continue
+		}
+		if field.Type.Kind() != reflect.Map || field.Type.Key().Kind() != reflect.String {
+			return nil, errorf(fmt.Errorf(errUnsupported), "remaining field "+field.Name, "type", field.Type.String(), "want a map with string keys")
+		}
+
+		claimed := d.claimedKeys(to)
+		result := maps.Clone(m)
+		rest := map[string]any{}
+		for key, value := range m {
+			if key != rawKey && !slices.ContainsFunc(claimed, func(name string) bool { return d.matchName(key, name) }) {
+				rest[key] = value
+				delete(result, key)
+			}
+		}
+		if len(rest) > 0 {
+			result[decodeKey(field)] = rest
+		}
+		return result, nil
+	}
+	return data, nil
+}
+
+// claimedKeys lists the keys of a table that the fields of struct type t
+// decode from: their names, the first segment of dotted and sectioned names,
+// and the keys claimed by inline structs
+func (d *Decoder) claimedKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := d.fieldTag(field)
+		if tag.skip || tag.has("remaining") {
+			continue
+		}
+		if tag.has("inline") && isStructType(field.Type) {
+			inline := field.Type
+			if inline.Kind() == reflect.Pointer {
+				inline = inline.Elem()
+			}
+			keys = append(keys, d.claimedKeys(inline)...)
+			continue
+		}
+		keys = append(keys, fieldKeys(tag)...)
+	}
+	return keys
+}
+
+// fieldKeys lists the keys of a table a field decodes from: the first segment
+// of its dotted or sectioned name and the key it is matched by
+func fieldKeys(tag fieldTag) []string {
+	name, _, _ := strings.Cut(tag.name, ".")
+	return []string{name, tag.key}
+}
+
+// fieldNameHook lets struct fields that have no toml name decode from the key
+// chosen by the decoder's naming options: their json tag with AllowJSONTags, or
+// SetKeyFunc applied to the field name. mapstructure only matches such fields
+// by field name, so the value is lifted to that name, and keys that would match
+// the field name alone are dropped so only the resolved name fills the field
+// The resolved name is dropped as well once lifted, unless another field decodes from it
+func (d *Decoder) fieldNameHook(from, to reflect.Type, data any) (any, error) {
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to == timeType {
+		return data, nil
+	}
+
+	var result map[string]any
+	var lifted []string
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		tag := d.fieldTag(field)
+		key := tag.key
+		if tag.skip {
+			if _, ok := field.Tag.Lookup("toml"); ok {
+				continue // toml:"-" is skipped by mapstructure itself
+			}
+		} else if tag.name == key || strings.Contains(tag.name, ".") || tag.has("inline") || tag.has("raw") || tag.has("remaining") {
+			continue // dotted paths, inline, raw and remaining fields have hooks of their own
+		}
+
+		if result == nil {
+			result = maps.Clone(m)
+		}
+		value, found := m[tag.name]
+		for k := range result {
+			if strings.EqualFold(k, key) {
+				delete(result, k)
+			}
+		}
+		if found && !tag.skip {
+			result[key] = value
+			lifted = append(lifted, tag.name)
+		}
+	}
+	if result == nil {
+		return data, nil
+	}
+
+	for _, name := range lifted {
+		claimed := false
+		for i := 0; i < to.NumField() && !claimed; i++ {
+			claimed = d.matchName(name, decodeKey(to.Field(i)))
+		}
+		if !claimed {
+			delete(result, name)
+		}
+	}
+	return result, nil
+}
+
+// fieldTag parses a struct field's tag with the decoder's naming options
+func (d *Decoder) fieldTag(field reflect.StructField) fieldTag {
+	return resolveTag(field, d.jsonTags, d.keyFunc)
+}
+
+// dottedTagHook lets struct fields tagged with a dotted path (toml:"one.value")
+// or a section (toml:"value,section=one") decode from the nested tables the
+// parser builds for that path
+// The matched values are lifted to the flat keys mapstructure matches the fields by
+func (d *Decoder) dottedTagHook(from, to reflect.Type, data any) (any, error) {
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to == timeType {
+		return data, nil
+	}
+
+	var result map[string]any
+	var claimed, groups []string
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		tag := d.fieldTag(field)
+		if tag.skip {
+			continue
+		}
+		name := tag.name
+		key := tag.key
+		claimed = append(claimed, key)
+		if !strings.Contains(name, ".") {
+			continue
+		}
+		groups = append(groups, name)
+
+		value, ok := m[name]
+		if ok && key == name {
+			continue
+		}
+		if !ok {
+			value, ok = getPath(m, strings.Split(name, "."))
+		}
+		if _, stray := m[key]; !ok && !stray {
+			continue
+		}
+		if result == nil {
+			result = maps.Clone(m)
+		}
+		if !ok {
+			// A sectioned field only reads from its section, never from a
+			// same-named key outside it
+			delete(result, key)
+			continue
+		}
+		result[key] = value
+	}
+
+	// Replace the group tables no field claims directly with the values no
+	// dotted name reaches, under their full path, so they are left unused
+	names := make(map[string]bool, len(groups))
+	for _, name := range groups {
+		names[name] = true
+	}
+	for _, name := range groups {
+		segment, _, _ := strings.Cut(name, ".")
+		if _, ok := m[segment]; !ok || slices.ContainsFunc(claimed, func(key string) bool { return d.matchName(segment, key) }) {
+			continue
+		}
+		if result == nil {
+			result = maps.Clone(m)
+		}
+		if table, ok := result[segment].(map[string]any); ok {
+			unclaimedLeaves(result, table, []string{segment}, names)
+		}
+		delete(result, segment)
+	}
+	if result == nil {
+		return data, nil
+	}
+	return result, nil
+}
+
+// unclaimedLeaves stores into result the values of a group table found at path
+// that no dotted field name in names reaches, keyed by their full path
+func unclaimedLeaves(result, table map[string]any, path []string, names map[string]bool) {
+	for key, value := range table {
+		keyPath := append(path[:len(path):len(path)], key)
+		name := strings.Join(keyPath, ".")
+		if names[name] || key == rawKey {
+			continue
+		}
+		if sub, ok := value.(map[string]any); ok && hasKnownPrefix(names, name) {
+			unclaimedLeaves(result, sub, keyPath, names)
+			continue
+		}
+		result[formatPath(keyPath)] = value
+	}
+}
+
+// hasKnownPrefix reports whether a key in known lies inside the table name
+func hasKnownPrefix(known map[string]bool, name string) bool {
+	for key := range known {
+		if strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeKey returns the key mapstructure matches a struct field by: the toml
+// tag name before any options, or the field name when the tag gives none
+func decodeKey(field reflect.StructField) string {
+	return parseTag(field).key
+}
+
+// intRangeHook rejects integers that do not fit a narrower integer target, and
+// floats with a fractional part for any integer target (count = 1.5), instead
+// of letting the conversion silently truncate them
+func intRangeHook(from, to reflect.Type, data any) (any, error) {
+	if f, ok := data.(float64); ok {
+		switch to.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f != math.Trunc(f) {
+				return nil, errorf(fmt.Errorf(errInvalidInteger), "fractional value "+strconv.FormatFloat(f, 'g', -1, 64), "type", to.String())
+			}
+		}
+		return data, nil
+	}
+
+	v, ok := data.(int64)
+	if !ok {
+		return data, nil
+	}
+
+	switch to.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		if reflect.Zero(to).OverflowInt(v) {
+			return nil, &OverflowError{Literal: strconv.FormatInt(v, 10), BitSize: to.Bits()}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v < 0 || reflect.Zero(to).OverflowUint(uint64(v)) {
+			return nil, &OverflowError{Literal: strconv.FormatInt(v, 10), BitSize: to.Bits(), Unsigned: true}
+		}
+	}
+	return data, nil
+}
+
+// boolAliases maps the non-standard boolean spellings accepted by AllowBoolAliases
+var boolAliases = map[string]bool{
+	"yes": true,
+	"on":  true,
+	"no":  false,
+	"off": false,
+}
+
+// boolAliasHook converts boolean aliases ("yes", "off", ...), bare or quoted, for bool targets
+func boolAliasHook(from, to reflect.Type, data any) (any, error) {
+	s, ok := data.(string)
+	if !ok || to.Kind() != reflect.Bool {
+		return data, nil
+	}
+	if v, ok := boolAliases[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	return data, nil
+}
+
+// numericBoolHook decodes the integers 1 and 0 into bool targets
+// Other integers are left for mapstructure to reject
+func numericBoolHook(from, to reflect.Type, data any) (any, error) {
+	n, ok := data.(int64)
+	if !ok || to.Kind() != reflect.Bool || (n != 0 && n != 1) {
+		return data, nil
+	}
+	return n == 1, nil
+}
+
+// runeStringHook decodes a single-character string into an int32 (rune) target
+func runeStringHook(from, to reflect.Type, data any) (any, error) {
+	s, ok := data.(string)
+	if !ok || to.Kind() != reflect.Int32 {
+		return data, nil
+	}
+
+	// A literal U+FFFD decodes with size 3, invalid UTF-8 with size 1
+	r, size := utf8.DecodeRuneInString(s)
+	if s == "" || size != len(s) || (r == utf8.RuneError && size == 1) {
+		return nil, errorf(fmt.Errorf(errInvalidRune), strconv.Quote(s), "want a single character")
+	}
+	return r, nil
+}
+
+// unixTimeHook decodes an integer into a time.Time target as a Unix timestamp
+// counted in the unit set by AllowUnixTimestamps, returned in UTC
+func (d *Decoder) unixTimeHook(from, to reflect.Type, data any) (any, error) {
+	v, ok := data.(int64)
+	if !ok || to != timeType {
+		return data, nil
+	}
+	return unixTime(v, d.unixUnit)
+}
+
+// validUnixUnit reports whether unit divides a second evenly or is a whole number
+// of seconds, the units a timestamp converts in without losing time
+func validUnixUnit(unit time.Duration) bool {
+	return unit > 0 && (time.Second%unit == 0 || unit%time.Second == 0)
+}
+
+// unixTime converts a Unix timestamp counted in unit into a UTC time
+// unit must be valid for validUnixUnit
+func unixTime(v int64, unit time.Duration) (time.Time, error) {
+	if unit >= time.Second {
+		scale := int64(unit / time.Second)
+		if v > math.MaxInt64/scale || v < math.MinInt64/scale {
+			return time.Time{}, errorf(fmt.Errorf(errIntegerOverflow), "timestamp", strconv.FormatInt(v, 10), "unit", unit.String())
+		}
+		return time.Unix(v*scale, 0).UTC(), nil
+	}
+	perSecond := int64(time.Second / unit)
+	return time.Unix(v/perSecond, v%perSecond*int64(unit)).UTC(), nil
+}
+
+// unixTimestamp converts t into a Unix timestamp counted in unit, dropping any
+// precision finer than unit
+func unixTimestamp(t time.Time, unit time.Duration) (int64, error) {
+	if unit >= time.Second {
+		return t.Unix() / int64(unit/time.Second), nil
+	}
+	perSecond := int64(time.Second / unit)
+	secs, frac := t.Unix(), int64(t.Nanosecond())/int64(unit)
+	if secs > (math.MaxInt64-frac)/perSecond || secs < math.MinInt64/perSecond {
+		return 0, errorf(fmt.Errorf(errIntegerOverflow), "time", t.Format(time.RFC3339Nano), "unit", unit.String())
+	}
+	return secs*perSecond + frac, nil
+}
+
+// nullValue stands in for a parsed null while decoding with AllowNull
+type nullValue struct{}
+
+// markNulls returns a copy of a parsed value with every nil in its tables,
+// including arrays of tables, replaced by nullValue
+func markNulls(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nullValue{}
+	case map[string]any:
+		marked := make(map[string]any, len(v))
+		for key, elem := range v {
+			marked[key] = markNulls(elem)
+		}
+		return marked
+	case []any:
+		marked := make([]any, len(v))
+		for i, elem := range v {
+			marked[i] = markNulls(elem)
+		}
+		return marked
+	}
+	return v
+}
+
+// restoreNulls reverses markNulls for values stored into interface targets
+func restoreNulls(v any) any {
+	switch v := v.(type) {
+	case nullValue:
+		return nil
+	case map[string]any:
+		restored := make(map[string]any, len(v))
+		for key, elem := range v {
+			restored[key] = restoreNulls(elem)
+		}
+		return restored
+	case []any:
+		restored := make([]any, len(v))
+		for i, elem := range v {
+			restored[i] = restoreNulls(elem)
+		}
+		return restored
+	}
+	return v
+}
+
+// nullHook decodes a null into a pointer or interface target as nil and into
+// a map target as a nil map, clearing any value the target held, and rejects
+// it for every other target, which has no way to tell null from a zero value
+func nullHook(from, to reflect.Type, data any) (any, error) {
+	if _, ok := data.(nullValue); !ok {
+		if to.Kind() == reflect.Interface {
+			return restoreNulls(data), nil
+		}
+		return data, nil
+	}
+
+	switch to.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return nil, nil
+	case reflect.Map:
+		return reflect.Zero(to).Interface(), nil
+	}
+	return nil, errorf(fmt.Errorf(errInvalidNull), "type", to.String(), "want a pointer, map or interface field")
+}
+
+// nullTableHook clears the pointer, interface and map fields of a struct target
+// whose key holds a null. mapstructure decodes a non-nil pointer to a struct or
+// a non-nil interface through the value it holds, where a null cannot reach the
+// field, so the field is set to nil here and its key dropped from the table
+func (d *Decoder) nullTableHook(from, to reflect.Value) (any, error) {
+	data := from.Interface()
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to.Type() == timeType {
+		return data, nil
+	}
+
+	var result map[string]any
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		switch field.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map:
+		default:
+			continue
+		}
+		if field.IsNil() || !field.CanSet() {
+			continue
+		}
+		name := decodeKey(to.Type().Field(i))
+		for key, value := range m {
+			if _, isNull := value.(nullValue); !isNull || !d.matchName(key, name) {
+				continue
+			}
+			field.Set(reflect.Zero(field.Type()))
+			if result == nil {
+				result = maps.Clone(m)
+			}
+			delete(result, key)
+		}
+	}
+	if result == nil {
+		return data, nil
+	}
+	return result, nil
+}
+
+// matchName reports whether a key matches a field's decode key the way the
+// mapstructure decoder configured by decode does
+func (d *Decoder) matchName(key, name string) bool {
+	if d.normalizedKeys {
+		return matchNormalized(key, name)
+	}
+	return strings.EqualFold(key, name)
+}
+
+// complexHook sets the complex fields of a struct target, and slices and
+// arrays of them, from [real, imag] arrays. mapstructure has no complex kind
+// to decode into, so the fields are set here and their keys dropped from the table
+// It runs after the hooks that lift dotted, sectioned, inline and renamed
+// fields to the keys they decode from, so the fields are found by those keys
+func (d *Decoder) complexHook(from, to reflect.Value) (any, error) {
+	data := from.Interface()
+	m, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || to.Type() == timeType {
+		return data, nil
+	}
+
+	var result map[string]any
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Field(i)
+		if !hasComplex(field.Type()) || !field.CanSet() {
+			continue
+		}
+		name := d.fieldTag(to.Type().Field(i)).key
+		for key, value := range m {
+			if !d.matchName(key, name) {
+				continue
+			}
+			if err := setComplex(field, value); err != nil {
+				return nil, errorf(err, "key", key)
+			}
+			if result == nil {
+				result = maps.Clone(m)
+			}
+			delete(result, key)
+		}
+	}
+	if result == nil {
+		return data, nil
+	}
+	return result, nil
+}
+
+// hasComplex reports whether a type is complex or a slice or array of complex values
+func hasComplex(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Slice, reflect.Array:
+		return hasComplex(t.Elem())
+	}
+	return false
+}
+
+// setComplex stores a parsed [real, imag] array, or an array of them, into dst
+func setComplex(dst reflect.Value, data any) error {
+	elems, ok := data.([]any)
+	if !ok {
+		return errorf(fmt.Errorf(errInvalidValue), "want [real, imag] array", "got "+typeName(data))
+	}
+
+	switch dst.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := setComplex(s.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		if len(elems) != dst.Len() {
+			return errorf(fmt.Errorf(errArrayLength), fmt.Sprintf("got %d elements, want %d", len(elems), dst.Len()))
+		}
+		for i, elem := range elems {
+			if err := setComplex(dst.Index(i), elem); err != nil {
+				return err
+			}
+		}
+	default:
+		re, okRe := complexPart(elems, 0)
+		im, okIm := complexPart(elems, 1)
+		if len(elems) != 2 {
+			return errorf(fmt.Errorf(errInvalidValue), "want [real, imag] array", fmt.Sprintf("got %d elements", len(elems)))
+		}
+		if !okRe || !okIm {
+			return errorf(fmt.Errorf(errInvalidValue), "want [real, imag] array of numbers", fmt.Sprintf("got %v", data))
+		}
+		dst.SetComplex(complex(re, im))
+	}
+	return nil
+}
+
+// complexPart returns element i of a [real, imag] array as a float64
+func complexPart(elems []any, i int) (float64, bool) {
+	if i >= len(elems) {
+		return 0, false
+	}
+	switch v := elems[i].(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// unquotedStringHook rejects numbers decoded into string targets with a hint to
+// quote them: only a quoted value keeps its exact text (zip = "02139"), while an
+// unquoted literal has already lost leading zeros and formatting as a number
+func unquotedStringHook(from, to reflect.Type, data any) (any, error) {
+	if to.Kind() != reflect.String {
+		return data, nil
+	}
+	switch data.(type) {
+	case int64, float64:
+		return nil, errorf(fmt.Errorf(errInvalidString), fmt.Sprintf("unquoted number %v", data), "quote the value to keep it as text")
+	}
+	return data, nil
+}
+
+// arrayLengthHook requires a TOML array to match the length of a fixed-size Go array
+// target, rather than leaving mapstructure to keep or reject the rest ambiguously
+// A shorter array is padded with zero values when AllowArrayZeroFill is set, so every
+// element of the target is written, and a longer one is cut to size when
+// AllowArrayTruncation is set
+func (d *Decoder) arrayLengthHook(from, to reflect.Type, data any) (any, error) {
+	elems, ok := data.([]any)
+	if !ok || to.Kind() != reflect.Array {
+		return data, nil
+	}
+
+	switch {
+	case len(elems) < to.Len() && d.arrayZeroFill:
+		filled := make([]any, to.Len())
+		copy(filled, elems)
+		zero := reflect.Zero(to.Elem()).Interface()
+		for i := len(elems); i < len(filled); i++ {
+			filled[i] = zero
+		}
+		return filled, nil
+	case len(elems) > to.Len() && d.arrayTruncate:
+		return elems[:to.Len()], nil
+	case len(elems) != to.Len():
+		return nil, errorf(fmt.Errorf(errArrayLength), fmt.Sprintf("got %d", len(elems)), fmt.Sprintf("want %d", to.Len()), "type", to.String())
+	}
+	return data, nil
+}
+
+// blankStringHook folds whitespace-only strings to "" when FoldBlankStrings is set
+// Without keys every string is folded as it is decoded; with keys only the values
+// stored under those key names are, which requires rewriting the table holding them
+func (d *Decoder) blankStringHook(from, to reflect.Type, data any) (any, error) {
+	if len(d.blankKeys) == 0 {
+		if s, ok := data.(string); ok && strings.TrimSpace(s) == "" {
+			return "", nil
+		}
+		return data, nil
+	}
+
+	table, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+
+	var folded map[string]any
+	for key := range d.blankKeys {
+		s, ok := table[key].(string)
+		if !ok || s == "" || strings.TrimSpace(s) != "" {
+			continue
+		}
+		if folded == nil {
+			folded = maps.Clone(table)
+		}
+		folded[key] = ""
+	}
+	if folded == nil {
+		return data, nil
+	}
+	return folded, nil
+}
+
+// typeHasOption reports whether a struct reachable from type t, through fields,
+// pointers, slices, arrays and maps, has a field tagged with option
+func typeHasOption(t reflect.Type, option string) bool {
+	return hasOption(t, option, map[reflect.Type]bool{})
+}
+
+// hasOption is typeHasOption, with seen holding the types already walked so
+// recursive types end
+func hasOption(t reflect.Type, option string, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasOption(t.Elem(), option, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if tag := parseTag(field); !tag.skip && (tag.has(option) || hasOption(field.Type, option, seen)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStructType reports whether t is a struct or a pointer to one, the field
+// types mapstructure fills from a table (pointers are allocated as needed)
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
@@ -0,0 +1,637 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal_TypedSlices(t *testing.T) {
+	type Config struct {
+		Ports   []int64   `toml:"ports"`
+		Weights []float64 `toml:"weights"`
+		Ratios  []float32 `toml:"ratios"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name: "integer and float arrays",
+			input: `ports = [80, 443, -1]
+weights = [0.5, 1.25]`,
+			expected: Config{Ports: []int64{80, 443, -1}, Weights: []float64{0.5, 1.25}},
+			wantErr:  false,
+		},
+		{
+			name:     "empty arrays",
+			input:    "ports = []\nweights = []",
+			expected: Config{Ports: []int64{}, Weights: []float64{}},
+			wantErr:  false,
+		},
+		{
+			name:     "integers promoted to float",
+			input:    "weights = [1, 2.5]",
+			expected: Config{Weights: []float64{1, 2.5}},
+			wantErr:  false,
+		},
+		{
+			name:     "mixed integers and floats",
+			input:    "weights = [1, 2.5, 3, -4]",
+			expected: Config{Weights: []float64{1, 2.5, 3, -4}},
+			wantErr:  false,
+		},
+		{
+			name:     "mixed integers and floats into float32",
+			input:    "ratios = [1, 0.5, 0x10]",
+			expected: Config{Ratios: []float32{1, 0.5, 16}},
+			wantErr:  false,
+		},
+		{
+			name:     "string in float32 array",
+			input:    `ratios = [0.5, "1"]`,
+			wantErr:  true,
+			errormsg: errInvalidFloat + " [element 1, got string]",
+		},
+		{
+			name:     "string in integer array",
+			input:    `ports = [80, "443"]`,
+			wantErr:  true,
+			errormsg: errInvalidInteger + " [element 1, got string]",
+		},
+		{
+			name:     "float in integer array",
+			input:    "ports = [80, 1.5]",
+			wantErr:  true,
+			errormsg: errInvalidInteger + " [element 1, got float64]",
+		},
+		{
+			name:     "bool in float array",
+			input:    "weights = [0.5, 1.0, true]",
+			wantErr:  true,
+			errormsg: errInvalidFloat + " [element 2, got bool]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			err := Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_DottedTags(t *testing.T) {
+	type NestedGroups struct {
+		Name  string `toml:"name"`
+		One   string `toml:"one.value"`
+		Two   int64  `toml:"one.two.count"`
+		Three bool   `toml:"three.enabled"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected NestedGroups
+	}{
+		{
+			name: "nested tables",
+			input: `name = "groups"
+[one]
+value = "first"
+[one.two]
+count = 2
+[three]
+enabled = true`,
+			expected: NestedGroups{Name: "groups", One: "first", Two: 2, Three: true},
+		},
+		{
+			name: "dotted keys",
+			input: `name = "groups"
+one.value = "first"
+one.two.count = 2
+three.enabled = true`,
+			expected: NestedGroups{Name: "groups", One: "first", Two: 2, Three: true},
+		},
+		{
+			name:     "missing groups",
+			input:    `name = "groups"`,
+			expected: NestedGroups{Name: "groups"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got NestedGroups
+			if err := Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Errorf("Unmarshal() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+				return
+			}
+
+			// The dotted tags must marshal back into the same layout
+			output, err := Marshal(got)
+			if err != nil {
+				t.Errorf("Marshal() error = %v", err)
+				return
+			}
+			var again NestedGroups
+			if err := Unmarshal(output, &again); err != nil {
+				t.Errorf("Unmarshal() roundtrip error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Errorf("roundtrip = %v, want %v", again, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_SectionTags(t *testing.T) {
+	type Flat struct {
+		Name    string `toml:"name"`
+		Host    string `toml:"host,section=network"`
+		Port    int64  `toml:"port,section=network"`
+		Enabled bool   `toml:"enabled,required,section=network.tls"`
+	}
+
+	input := `name = "app"
+[network]
+host = "localhost"
+port = 8080
+[network.tls]
+enabled = true`
+	expected := Flat{Name: "app", Host: "localhost", Port: 8080, Enabled: true}
+
+	var got Flat
+	if err := Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unmarshal() = %v, want %v", got, expected)
+	}
+
+	// The sections must marshal back into the same tables
+	output, err := Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != input+"\n" {
+		t.Errorf("Marshal() = %q, want %q", output, input+"\n")
+	}
+
+	// A key outside the section does not fill a sectioned field
+	var stray Flat
+	if err := Unmarshal([]byte("name = \"app\"\nhost = \"elsewhere\""), &stray); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if stray.Host != "" {
+		t.Errorf("Unmarshal() Host = %q, want empty", stray.Host)
+	}
+
+	// Other options still apply alongside the section
+	fields, err := Describe(Flat{})
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	if last := fields[len(fields)-1]; last.Key != "network.tls.enabled" || !last.Required {
+		t.Errorf("Describe() = %+v, want required key network.tls.enabled", last)
+	}
+
+	// Tag names are trimmed on decode as on marshal
+	type Padded struct {
+		Name string `toml:" name "`
+		Port int64  `toml:" port , section=network"`
+	}
+	var padded Padded
+	if err := Unmarshal([]byte("name = \"app\"\n[network]\nport = 8080"), &padded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if padded != (Padded{Name: "app", Port: 8080}) {
+		t.Errorf("Unmarshal() = %+v, want name and port set", padded)
+	}
+}
+
+func TestUnmarshal_RawTag(t *testing.T) {
+	type Database struct {
+		Host string `toml:"host"`
+		Port int64  `toml:"port"`
+		Raw  string `toml:",raw"`
+	}
+	type Server struct {
+		Name   string `toml:"name"`
+		Source []byte `toml:"source,raw"`
+	}
+	type Config struct {
+		Name     string   `toml:"name"`
+		Database Database `toml:"database"`
+		Servers  []Server `toml:"servers"`
+		Raw      string   `toml:",raw"`
+	}
+
+	input := `
+name = "app" # root
+
+[database]
+host = "localhost"  # primary
+port = 5432
+
+[[servers]]
+name = "alpha"
+
+[[servers]]
+name = "beta"
+[database.replica]
+host = "standby"
+`
+
+	var got Config
+	if err := Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "root", got: got.Raw, want: `name = "app" # root`},
+		{name: "table", got: got.Database.Raw, want: "[database]\nhost = \"localhost\"  # primary\nport = 5432"},
+		{name: "first table array element", got: string(got.Servers[0].Source), want: "[[servers]]\nname = \"alpha\""},
+		{name: "second table array element", got: string(got.Servers[1].Source), want: "[[servers]]\nname = \"beta\""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s raw = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if got.Database.Host != "localhost" || got.Servers[1].Name != "beta" {
+		t.Errorf("Unmarshal() = %+v, want the values decoded alongside the raw text", got)
+	}
+
+	// Raw fields are never written back
+	output, err := Marshal(got.Database)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(output), "Raw") {
+		t.Errorf("Marshal() = %q, want no raw field", output)
+	}
+
+	// The raw text survives AllowNull, which copies the parsed tables
+	dec := NewDecoder(strings.NewReader("[database]\nhost = null"))
+	dec.AllowNull()
+	var nullable struct {
+		Database struct {
+			Host *string `toml:"host"`
+			Raw  string  `toml:",raw"`
+		} `toml:"database"`
+	}
+	if err := dec.Decode(&nullable); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if nullable.Database.Raw != "[database]\nhost = null" {
+		t.Errorf("Decode() raw = %q, want %q", nullable.Database.Raw, "[database]\nhost = null")
+	}
+
+	var bad struct {
+		Raw int `toml:",raw"`
+	}
+	if err := Unmarshal([]byte("a = 1"), &bad); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("Unmarshal() error = %v, want error containing %v", err, errUnsupported)
+	}
+
+	// Inline structs get the text of the enclosing table, while map and
+	// interface fields hold only the parsed values, even in strict mode
+	type Meta struct {
+		Owner string `toml:"owner"`
+		Raw   string `toml:",raw"`
+	}
+	var mixed struct {
+		Meta   `toml:",inline"`
+		Labels map[string]any `toml:"labels"`
+		Any    any            `toml:"any"`
+	}
+	src := []byte("owner = \"ops\"\n[labels]\nteam = \"a\"\n[any.nested]\nx = 1")
+	if err := UnmarshalStrict(src, &mixed); err != nil {
+		t.Fatalf("UnmarshalStrict() error = %v", err)
+	}
+	if mixed.Raw != "owner = \"ops\"" {
+		t.Errorf("inline raw = %q, want %q", mixed.Raw, "owner = \"ops\"")
+	}
+	wantAny := map[string]any{"nested": map[string]any{"x": int64(1)}}
+	if !reflect.DeepEqual(mixed.Labels, map[string]any{"team": "a"}) || !reflect.DeepEqual(mixed.Any, wantAny) {
+		t.Errorf("Unmarshal() labels = %v, any = %v, want the parsed values only", mixed.Labels, mixed.Any)
+	}
+
+	// The raw text is a copy, not a view of the input
+	var copied Server
+	src = []byte("name = \"x\"")
+	if err := Unmarshal(src, &copied); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	src[0] = '#'
+	if string(copied.Source) != "name = \"x\"" {
+		t.Errorf("raw = %q, changed with the input", copied.Source)
+	}
+}
+
+func TestUnmarshal_RemainingTag(t *testing.T) {
+	type Base struct {
+		Version int64 `toml:"version"`
+	}
+	type Plugin struct {
+		Name    string         `toml:"name"`
+		Options map[string]any `toml:",remaining"`
+	}
+	type Config struct {
+		Base   `toml:",inline"`
+		Name   string         `toml:"name"`
+		Port   int64          `toml:"port,section=server"`
+		Host   string         `toml:"database.host"`
+		Plugin Plugin         `toml:"plugin"`
+		Extra  map[string]any `toml:",remaining"`
+	}
+
+	input := `
+version = 2
+name = "app"
+color = "blue"
+retries = 3
+[server]
+port = 8080
+[database]
+host = "db"
+[plugin]
+name = "cache"
+ttl = 60
+[limits]
+max = 10
+`
+
+	var got Config
+	if err := Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Config{
+		Base:   Base{Version: 2},
+		Name:   "app",
+		Port:   8080,
+		Host:   "db",
+		Plugin: Plugin{Name: "cache", Options: map[string]any{"ttl": int64(60)}},
+		Extra: map[string]any{
+			"color":   "blue",
+			"retries": int64(3),
+			"limits":  map[string]any{"max": int64(10)},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+
+	// Without unknown keys the remaining map stays nil
+	var known Config
+	if err := Unmarshal([]byte("name = \"app\""), &known); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if known.Extra != nil {
+		t.Errorf("Unmarshal() remaining = %v, want nil", known.Extra)
+	}
+
+	// Strict decoding accepts the keys the remaining maps take
+	var strict Config
+	if err := UnmarshalStrict([]byte(input), &strict); err != nil {
+		t.Errorf("UnmarshalStrict() error = %v", err)
+	}
+	if err := ValidateAgainst([]byte(input), &Config{}); err != nil {
+		t.Errorf("ValidateAgainst() error = %v", err)
+	}
+	// but still reports unknown keys inside typed tables
+	if err := UnmarshalStrict([]byte("[database]\nhots = \"db\""), &strict); err == nil || !strings.Contains(err.Error(), "database.hots") {
+		t.Errorf("UnmarshalStrict() error = %v, want unknown key database.hots", err)
+	}
+
+	// Marshal writes the remaining keys back into their table
+	output, err := Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var roundtrip Config
+	if err := Unmarshal(output, &roundtrip); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(roundtrip, want) {
+		t.Errorf("roundtrip = %+v, want %+v\n%s", roundtrip, want, output)
+	}
+
+	// The remaining field must be a map with string keys
+	var invalid struct {
+		Extra []string `toml:",remaining"`
+	}
+	if err := Unmarshal([]byte("a = 1"), &invalid); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("Unmarshal() error = %v, want error containing %v", err, errUnsupported)
+	}
+
+	// Remaining fields are found in recursive types, at every level
+	type Node struct {
+		Name string         `toml:"name"`
+		Next *Node          `toml:"next"`
+		Rest map[string]any `toml:",remaining"`
+	}
+	var node Node
+	if err := Unmarshal([]byte("name = \"a\"\nx = 1\n[next]\nname = \"b\"\ny = 2"), &node); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if node.Next == nil || node.Rest["x"] != int64(1) || node.Next.Rest["y"] != int64(2) {
+		t.Errorf("Unmarshal() = %+v, want remaining keys x and next.y", node)
+	}
+}
+
+func TestUnmarshal_InlineTag(t *testing.T) {
+	type Network struct {
+		Host string `toml:"host"`
+		Port int64  `toml:"port"`
+	}
+	type Config struct {
+		Name    string  `toml:"name"`
+		Network Network `toml:",inline"`
+		Backup  Network `toml:"backup"`
+	}
+
+	input := `name = "app"
+host = "localhost"
+port = 8080
+
+[backup]
+host = "standby"
+port = 8081`
+
+	expected := Config{
+		Name:    "app",
+		Network: Network{Host: "localhost", Port: 8080},
+		Backup:  Network{Host: "standby", Port: 8081},
+	}
+
+	var got Config
+	if err := Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, expected)
+	}
+
+	// Inline fields must marshal back to the parent level
+	output, err := Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(output), "[Network]") {
+		t.Errorf("Marshal() emitted a table for the inline field:\n%s", output)
+	}
+	var again Config
+	if err := Unmarshal(output, &again); err != nil {
+		t.Fatalf("Unmarshal() roundtrip error = %v", err)
+	}
+	if !reflect.DeepEqual(again, got) {
+		t.Errorf("roundtrip = %+v, want %+v", again, got)
+	}
+}
+
+func TestUnmarshal_NumericStrings(t *testing.T) {
+	type Address struct {
+		Zip   string `toml:"zip"`
+		Phone string `toml:"phone"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Address
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "quoted values keep their text",
+			input:    "zip = \"02139\"\nphone = \"+1.555\"",
+			expected: Address{Zip: "02139", Phone: "+1.555"},
+			wantErr:  false,
+		},
+		{
+			name:     "unquoted integer",
+			input:    "zip = 02139",
+			wantErr:  true,
+			errormsg: errInvalidString + " [unquoted number 2139, quote the value to keep it as text]",
+		},
+		{
+			name:     "unquoted float",
+			input:    "phone = 1.555",
+			wantErr:  true,
+			errormsg: "quote the value to keep it as text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Address
+			err := Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if got != tt.expected {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_FixedArrays(t *testing.T) {
+	type Config struct {
+		Point [3]int64   `toml:"point"`
+		Names [2]string  `toml:"names"`
+		Scale [2]float64 `toml:"scale"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "exact lengths",
+			input:    "point = [1, 0, -3]\nnames = [\"a\", \"b\"]\nscale = [1, 0.5]",
+			expected: Config{Point: [3]int64{1, 0, -3}, Names: [2]string{"a", "b"}, Scale: [2]float64{1, 0.5}},
+			wantErr:  false,
+		},
+		{
+			name:     "too short",
+			input:    "point = [1, 2]",
+			wantErr:  true,
+			errormsg: errArrayLength + " [got 2, want 3, type, [3]int64]",
+		},
+		{
+			name:     "too long",
+			input:    `names = ["a", "b", "c"]`,
+			wantErr:  true,
+			errormsg: errArrayLength + " [got 3, want 2, type, [2]string]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			err := Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if got != tt.expected {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+			}
+
+			// Fixed arrays must survive a roundtrip
+			output, err := Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var again Config
+			if err := Unmarshal(output, &again); err != nil || again != got {
+				t.Errorf("roundtrip = %v, %v, want %v", again, err, got)
+			}
+		})
+	}
+}
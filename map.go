@@ -0,0 +1,180 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Clone returns a deep copy of a parsed TOML document.
+// Nested tables (map[string]any) and arrays ([]any) are copied recursively,
+// so the copy can be modified without affecting the original.
+// Scalar leaves (strings, numbers, booleans, datetimes) are copied by value.
+func Clone(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		result[k] = cloneValue(v)
+	}
+	return result
+}
+
+// cloneValue deep-copies a single value of the parsed representation
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return Clone(v)
+	case []any:
+		if v == nil {
+			return v
+		}
+		result := make([]any, len(v))
+		for i, elem := range v {
+			result[i] = cloneValue(elem)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// Keys parses TOML data and returns the dotted path of every key holding a
+// value, sorted, for comparing the keys of two config versions or checking
+// that required keys exist without decoding into a struct. Segments that are
+// not bare keys are quoted as in a table header (site."google.com"), keys in
+// arrays of tables are listed per element (servers[0].name), and tables
+// without keys contribute no entry.
+func Keys(data []byte) ([]string, error) {
+	result, err := (&Decoder{}).parse(data)
+	if err != nil {
+		return nil, errorf(err)
+	}
+
+	keys := []string{}
+	collectKeys(result, "", &keys)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// collectKeys appends the paths of the values in a table found at prefix and
+// in its subtables, including the elements of arrays of tables
+func collectKeys(m map[string]any, prefix string, keys *[]string) {
+	for k, v := range m {
+		name := joinPath(prefix, k)
+		if table, ok := v.(map[string]any); ok {
+			collectKeys(table, name, keys)
+			continue
+		}
+		if elems, ok := tableArray(v); ok {
+			for i, elem := range elems {
+				collectKeys(elem.(map[string]any), fmt.Sprintf("%s[%d]", name, i), keys)
+			}
+			continue
+		}
+		*keys = append(*keys, name)
+	}
+}
+
+// AsStringSlice converts an array from a parsed document ([]any) into []string.
+// It fails if v is not an array or any element is not a string.
+func AsStringSlice(v any) ([]string, error) {
+	return asSlice[string](v, errInvalidString)
+}
+
+// AsIntSlice converts an array from a parsed document ([]any) into []int64.
+// It fails if v is not an array or any element is not an integer.
+func AsIntSlice(v any) ([]int64, error) {
+	return asSlice[int64](v, errInvalidInteger)
+}
+
+// AsFloatSlice converts an array from a parsed document ([]any) into []float64.
+// Integer elements are promoted, so [1, 2.5] converts without error.
+// It fails if v is not an array or any element is not a number.
+func AsFloatSlice(v any) ([]float64, error) {
+	elems, ok := v.([]any)
+	if !ok {
+		return nil, errorf(fmt.Errorf(errNotArray), fmt.Sprintf("%T", v))
+	}
+
+	result := make([]float64, len(elems))
+	for i, elem := range elems {
+		switch n := elem.(type) {
+		case float64:
+			result[i] = n
+		case int64:
+			result[i] = float64(n)
+		default:
+			return nil, errorf(fmt.Errorf(errInvalidFloat), fmt.Sprintf("element %d", i), fmt.Sprintf("got %T", elem))
+		}
+	}
+	return result, nil
+}
+
+// AsBoolSlice converts an array from a parsed document ([]any) into []bool.
+// It fails if v is not an array or any element is not a boolean.
+func AsBoolSlice(v any) ([]bool, error) {
+	return asSlice[bool](v, errInvalidBoolean)
+}
+
+// asSlice converts a parsed array into a slice of T, reporting the first element
+// of another type with errConst
+func asSlice[T any](v any, errConst string) ([]T, error) {
+	elems, ok := v.([]any)
+	if !ok {
+		return nil, errorf(fmt.Errorf(errNotArray), fmt.Sprintf("%T", v))
+	}
+
+	result := make([]T, len(elems))
+	for i, elem := range elems {
+		t, ok := elem.(T)
+		if !ok {
+			return nil, errorf(errors.New(errConst), fmt.Sprintf("element %d", i), fmt.Sprintf("got %T", elem))
+		}
+		result[i] = t
+	}
+	return result, nil
+}
+
+// getPath returns the value stored under a path of nested tables
+func getPath(m map[string]any, path []string) (any, bool) {
+	var current any = m
+	for _, segment := range path {
+		table, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = table[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setPath stores a value under a path of nested tables, creating missing tables
+// It fails if a segment is already taken by a non-table value or the key is set
+func setPath(m map[string]any, path []string, value any) error {
+	current := m
+	for _, segment := range path[:len(path)-1] {
+		next, ok := current[segment]
+		if !ok {
+			table := map[string]any{}
+			current[segment] = table
+			current = table
+			continue
+		}
+		if current, ok = next.(map[string]any); !ok {
+			return errorf(fmt.Errorf(errDuplicateKey), "key", segment)
+		}
+	}
+
+	key := path[len(path)-1]
+	if _, ok := current[key]; ok {
+		return errorf(fmt.Errorf(errDuplicateKey), "key", key)
+	}
+	current[key] = value
+	return nil
+}
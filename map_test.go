@@ -0,0 +1,188 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	input := `name = "app"
+ports = [80, 443]
+matrix = [[1, 2], [3]]
+
+[server]
+host = "localhost"
+
+[server.tls]
+enabled = true`
+
+	var original map[string]any
+	if err := Unmarshal([]byte(input), &original); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	var expected map[string]any
+	if err := Unmarshal([]byte(input), &expected); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	clone := Clone(original)
+	if !reflect.DeepEqual(clone, original) {
+		t.Fatalf("Clone() = %v, want %v", clone, original)
+	}
+
+	// Mutating every level of the clone must leave the original intact
+	clone["name"] = "changed"
+	clone["ports"].([]any)[0] = int64(8080)
+	clone["matrix"].([]any)[0].([]any)[1] = int64(20)
+	clone["server"].(map[string]any)["host"] = "example.com"
+	clone["server"].(map[string]any)["tls"].(map[string]any)["enabled"] = false
+	clone["server"].(map[string]any)["extra"] = "value"
+
+	if !reflect.DeepEqual(original, expected) {
+		t.Errorf("Clone() aliased the original: got %v, want %v", original, expected)
+	}
+
+	if Clone(nil) != nil {
+		t.Errorf("Clone(nil) = %v, want nil", Clone(nil))
+	}
+}
+
+func TestAsSlice(t *testing.T) {
+	var doc map[string]any
+	input := `names = ["a", "b"]
+ports = [80, 443]
+weights = [1, 2.5]
+flags = [true, false]
+mixed = ["a", 1]
+empty = []
+name = "single"`
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		convert  func(any) (any, error)
+		key      string
+		expected any
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "strings",
+			convert:  func(v any) (any, error) { return AsStringSlice(v) },
+			key:      "names",
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "integers",
+			convert:  func(v any) (any, error) { return AsIntSlice(v) },
+			key:      "ports",
+			expected: []int64{80, 443},
+		},
+		{
+			name:     "floats with promoted integers",
+			convert:  func(v any) (any, error) { return AsFloatSlice(v) },
+			key:      "weights",
+			expected: []float64{1, 2.5},
+		},
+		{
+			name:     "booleans",
+			convert:  func(v any) (any, error) { return AsBoolSlice(v) },
+			key:      "flags",
+			expected: []bool{true, false},
+		},
+		{
+			name:     "empty array",
+			convert:  func(v any) (any, error) { return AsIntSlice(v) },
+			key:      "empty",
+			expected: []int64{},
+		},
+		{
+			name:     "heterogeneous elements",
+			convert:  func(v any) (any, error) { return AsStringSlice(v) },
+			key:      "mixed",
+			wantErr:  true,
+			errormsg: errInvalidString + " [element 1, got int64]",
+		},
+		{
+			name:     "heterogeneous numbers",
+			convert:  func(v any) (any, error) { return AsFloatSlice(v) },
+			key:      "mixed",
+			wantErr:  true,
+			errormsg: errInvalidFloat + " [element 0, got string]",
+		},
+		{
+			name:     "not an array",
+			convert:  func(v any) (any, error) { return AsStringSlice(v) },
+			key:      "name",
+			wantErr:  true,
+			errormsg: errNotArray,
+		},
+		{
+			name:     "missing key",
+			convert:  func(v any) (any, error) { return AsBoolSlice(v) },
+			key:      "missing",
+			wantErr:  true,
+			errormsg: errNotArray,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.convert(doc[tt.key])
+			if (err != nil) != tt.wantErr {
+				t.Errorf("convert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("convert() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("convert() = %#v, want %#v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	input := `name = "app"
+site."google.com" = true
+ports = [80, 443]
+
+[server]
+host = "localhost"
+
+[server.tls]
+enabled = true
+
+[empty]
+
+[[mounts]]
+dir = "/a"
+
+[[mounts]]
+dir = "/b"
+mode = "ro"`
+
+	got, err := Keys([]byte(input))
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	want := []string{"mounts[0].dir", "mounts[1].dir", "mounts[1].mode", "name", "ports", "server.host", "server.tls.enabled", `site."google.com"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+
+	if got, err := Keys(nil); err != nil || len(got) != 0 {
+		t.Errorf("Keys(nil) = %v, %v, want no keys", got, err)
+	}
+	if _, err := Keys([]byte("name = ")); err == nil || !strings.Contains(err.Error(), errMissingValue) {
+		t.Errorf("Keys() error = %v, want error containing %v", err, errMissingValue)
+	}
+}
@@ -2,102 +2,381 @@
 package tinytoml
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"math"
 	"reflect"
-	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Marshal converts a Go value into TOML format.
 // It supports basic types (string, int, float, bool), arrays, and nested structures.
 // Maps must have string keys. Struct fields can use 'toml' tags for customization.
+// Within each table, all plain keys are emitted before any nested table header,
+// regardless of struct field order, so the output reparses into the same structure.
+// Slices of maps or structs, including []any holding only tables, are written
+// as arrays of tables: one [[name]] block per element.
+// String values are always double-quoted; there is no bare-string form.
 func Marshal(v any) ([]byte, error) {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
+	if data, ok := marshalFlatMap(v); ok {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(nil).marshal(&buf, v); err != nil {
+		return buf.Bytes(), errorf(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalFlatMap writes the most common config shapes without reflection:
+// map[string]string, map[string]int, and map[string]any holding only strings,
+// ints, int64s and booleans. The output is byte-identical to the generic path.
+// Reports false for anything else, including empty maps, which take the generic path.
+func marshalFlatMap(v any) ([]byte, bool) {
+	var buf bytes.Buffer
+	switch m := v.(type) {
+	case map[string]string:
+		if len(m) == 0 {
+			return nil, false
+		}
+		for _, key := range sortedKeys(m) {
+			writeFlatKey(&buf, key)
+			buf.WriteString(quoteString(m[key]))
+			buf.WriteByte('\n')
+		}
+	case map[string]int:
+		if len(m) == 0 {
+			return nil, false
+		}
+		for _, key := range sortedKeys(m) {
+			writeFlatKey(&buf, key)
+			buf.Write(strconv.AppendInt(buf.AvailableBuffer(), int64(m[key]), 10))
+			buf.WriteByte('\n')
+		}
+	case map[string]any:
+		if len(m) == 0 {
+			return nil, false
+		}
+		for _, value := range m {
+			switch value.(type) {
+			case string, int, int64, bool:
+			default:
+				return nil, false // tables, arrays, nil and other scalars need the generic path
+			}
+		}
+		for _, key := range sortedKeys(m) {
+			writeFlatKey(&buf, key)
+			switch value := m[key].(type) {
+			case string:
+				buf.WriteString(quoteString(value))
+			case int:
+				buf.Write(strconv.AppendInt(buf.AvailableBuffer(), int64(value), 10))
+			case int64:
+				buf.Write(strconv.AppendInt(buf.AvailableBuffer(), value, 10))
+			case bool:
+				buf.WriteString(strconv.FormatBool(value))
+			}
+			buf.WriteByte('\n')
+		}
+	default:
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// sortedKeys returns the keys of a flat map in the order marshalMap writes them
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeFlatKey writes "key = " the way writeKey does for root keys
+func writeFlatKey(buf *bytes.Buffer, key string) {
+	buf.WriteString(formatKey(key))
+	buf.WriteString(" = ")
+}
 
+// marshal writes a Go value as a TOML document to out using the encoder's options
+// The value is checked before anything is written
+func (e *Encoder) marshal(out writer, v any) error {
 	if v == nil {
-		return nil, errorf(fn, fmt.Errorf(errNilValue))
+		return errorf(fmt.Errorf(errNilValue))
 	}
 
-	input := reflect.ValueOf(v)
+	input := getBareValue(reflect.ValueOf(v))
 	if !input.IsValid() {
-		return nil, errorf(fn, fmt.Errorf(errNilValue))
+		return errorf(fmt.Errorf(errNilValue))
 	}
 
-	if isUnsupportedType(input.Kind()) {
-		return nil, errorf(fn, fmt.Errorf(errUnsupported))
+	if isUnsupportedType(input.Kind()) && input.Type() != orderedMapPtrType {
+		return errorf(fmt.Errorf(errUnsupported))
 	}
 
-	input = getBareValue(input)
+	if input.Kind() != reflect.Struct && input.Kind() != reflect.Map && input.Type() != orderedMapPtrType {
+		return errorf(fmt.Errorf(errUnsupported), "type", reflect.TypeOf(input).String(), "value", reflect.ValueOf(input).String())
+	}
 
-	if input.Kind() != reflect.Struct && input.Kind() != reflect.Map {
-		return nil, errorf(fn, fmt.Errorf(errUnsupported), "type", reflect.TypeOf(input).String(), "value", reflect.ValueOf(input).String())
+	m := e.newMarshaller(out)
+	if err := m.marshalValue(input); err != nil {
+		return errorf(err, "type", reflect.TypeOf(input).String(), "value", reflect.ValueOf(input).String())
 	}
+	return nil
+}
 
-	m := &marshaller{
-		buffer: &bytes.Buffer{},
-		path:   []string{},
-		depth:  0,
+// MarshalValue converts a single Go value into its TOML representation.
+// Scalars and arrays are emitted bare (e.g. "text" or [1, 2]) without a key,
+// for composing TOML fragments. Structs and maps produce the same document as Marshal.
+func MarshalValue(v any) ([]byte, error) {
+	if v == nil {
+		return nil, errorf(fmt.Errorf(errNilValue))
 	}
 
+	input := getBareValue(reflect.ValueOf(v))
+	if isTable(input) {
+		return Marshal(v)
+	}
+
+	var buf bytes.Buffer
+	m := NewEncoder(nil).newMarshaller(&buf)
 	if err := m.marshalValue(input); err != nil {
-		return m.buffer.Bytes(), errorf(fn, err, "type", reflect.TypeOf(input).String(), "value", reflect.ValueOf(input).String())
+		return nil, errorf(err, "type", reflect.TypeOf(input).String(), "value", reflect.ValueOf(input).String())
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalArrayOfTables writes a slice or array of structs or maps as an array
+// of tables named key: one [[key]] block per element, as Marshal writes such a
+// slice held by a field. It is meant for lists of records that have no
+// enclosing struct. key is a single key, quoted in the headers when needed.
+// An empty slice produces an empty document.
+func MarshalArrayOfTables(key string, v any) ([]byte, error) {
+	if key == "" {
+		return nil, errorf(fmt.Errorf(errMissingKey))
+	}
+	if v == nil {
+		return nil, errorf(fmt.Errorf(errNilValue))
+	}
+
+	input := getBareValue(reflect.ValueOf(v))
+	if input.Kind() != reflect.Slice && input.Kind() != reflect.Array {
+		return nil, errorf(fmt.Errorf(errUnsupported), "type", input.Type().String())
 	}
-	return m.buffer.Bytes(), nil
+	if input.Len() == 0 {
+		return []byte{}, nil
+	}
+	if !isTableArray(input) {
+		return nil, errorf(fmt.Errorf(errUnsupported), "type", input.Type().String(), "elements must be structs or maps")
+	}
+
+	data, err := Marshal(map[string]any{key: v})
+	if err != nil {
+		return nil, errorf(err)
+	}
+	return data, nil
+}
+
+// MarshalWriteTo writes the TOML encoding of v to w as it is produced and returns
+// the number of bytes written. The value follows the same rules as Marshal, but the
+// document is never built in memory: output reaches w in small buffered chunks, so
+// a value that fails part way through may leave a partial document in w.
+func MarshalWriteTo(w io.Writer, v any) (int64, error) {
+	cw := &countingWriter{w: w}
+	out := bufio.NewWriter(cw)
+	if err := NewEncoder(nil).marshal(out, v); err != nil {
+		return cw.n, errorf(err)
+	}
+	if err := out.Flush(); err != nil {
+		return cw.n, errorf(err)
+	}
+	return cw.n, nil
+}
+
+// countingWriter passes writes through to w and counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writer is the output of a marshaller: a bytes.Buffer when the document is
+// returned, or a bufio.Writer when it is streamed
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
 }
 
 // marshaller handles the TOML encoding process by maintaining the current state
 // including output buffer, current table path and nesting depth
 type marshaller struct {
-	buffer *bytes.Buffer
-	path   []string
-	depth  int
+	buffer  writer
+	path    []string
+	depth   int
+	table   []string // path of the last emitted table header
+	key     string   // last key written, named in value errors
+	options marshalOptions
+
+	visiting map[visit]bool // tables and arrays being written, to catch reference cycles
+}
+
+// visit identifies a struct, map or slice by its type and address
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// reference returns the identity of a value that can be reached again from
+// inside itself through pointers, maps or slices
+func reference(v reflect.Value) (visit, bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.CanAddr() {
+			return visit{typ: v.Type(), ptr: v.Addr().Pointer()}, true
+		}
+	case reflect.Map, reflect.Pointer:
+		if !v.IsNil() {
+			return visit{typ: v.Type(), ptr: v.Pointer()}, true
+		}
+	case reflect.Slice:
+		if v.Len() > 0 {
+			return visit{typ: v.Type(), ptr: v.Pointer(), len: v.Len()}, true
+		}
+	}
+	return visit{}, false
+}
+
+// marshalOptions holds the encoder settings that change how values are written
+type marshalOptions struct {
+	floatPrecision  int                 // digits after the decimal point, -1 for the shortest round-trip form
+	runeStrings     bool                // int32 values are written as single-character strings
+	skipUnsupported bool                // struct fields and map entries of unsupported kinds are omitted
+	unixUnit        time.Duration       // time.Time values are written as Unix timestamps in this unit, 0 for RFC 3339
+	jsonTags        bool                // struct fields without a toml tag are named by their json tag
+	keyFunc         func(string) string // names struct fields that have no explicit key, nil for the field name
+	stringers       bool                // integer types implementing fmt.Stringer are written as their String() text
+	complexArrays   bool                // complex numbers are written as [real, imag] float arrays
+	skipNilElements bool                // nil pointers in slices are left out instead of failing
+	boolFormat      BoolFormat          // spelling of booleans, true/false by default
+}
+
+// newMarshaller returns a marshaller writing to out with the encoder's options
+func (e *Encoder) newMarshaller(out writer) *marshaller {
+	return &marshaller{
+		buffer:   out,
+		path:     []string{},
+		depth:    0,
+		options:  e.options,
+		visiting: make(map[visit]bool),
+	}
 }
 
 // marshalValue encodes a reflect.Value into TOML format based on its kind.
 // It handles basic types, arrays, maps and structs recursively.
 func (m *marshaller) marshalValue(v reflect.Value) error {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
+	v = getBareValue(v)
+	if !v.IsValid() {
+		return errorf(fmt.Errorf(errNilValue))
+	}
+	// A value reached again while it is being written would be written forever
+	if ref, ok := reference(v); ok {
+		if m.visiting[ref] {
+			return errorf(fmt.Errorf(errCycle), "type", v.Type().String())
+		}
+		m.visiting[ref] = true
+		defer delete(m.visiting, ref)
+	}
+	if v.Type() == orderedMapPtrType {
+		return m.marshalOrderedMap(v.Interface().(*OrderedMap))
+	}
+	if m.unsupported(v.Kind()) {
+		return errorf(fmt.Errorf(errUnsupported), "type", reflect.TypeOf(v).String())
+	}
 
-	if isUnsupportedType(getBareValue(v).Kind()) {
-		return errorf(fn, fmt.Errorf(errUnsupported), "type", reflect.TypeOf(v).String())
+	if v.Type() == timeType {
+		if err := m.marshalTime(v); err != nil {
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+		}
+		return nil
+	}
+
+	if v.Type() == numberType {
+		// Written bare as it was read, after checking it is still a number
+		if _, err := v.Interface().(Number).value(); err != nil {
+			return errorf(fmt.Errorf(errInvalidValue), "number", v.String())
+		}
+		m.buffer.WriteString(v.String())
+		return nil
+	}
+
+	if m.options.stringers && isIntegerKind(v.Kind()) {
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			m.buffer.WriteString(quoteString(s.String()))
+			return nil
+		}
 	}
 
 	switch v.Kind() {
 	case reflect.Struct:
 		if err := m.marshalStruct(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	case reflect.Map:
 		if err := m.marshalMap(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	case reflect.Slice, reflect.Array:
 		if err := m.marshalSlice(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	case reflect.String:
 		if err := m.marshalString(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+		}
+	case reflect.Int32:
+		if m.options.runeStrings {
+			if err := m.marshalRune(v); err != nil {
+				return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			}
+			return nil
 		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		if err := m.marshalInt(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if err := m.marshalInt(v); err != nil {
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	case reflect.Float32, reflect.Float64:
 		if err := m.marshalFloat(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	case reflect.Bool:
 		if err := m.marshalBool(v); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if err := m.marshalComplex(v); err != nil {
+			return errorf(err, "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 		}
 	default:
-		return errorf(fn, fmt.Errorf(errUnsupported), "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
+		return errorf(fmt.Errorf(errUnsupported), "type", reflect.TypeOf(v).String(), "value", reflect.ValueOf(v).String())
 	}
 	return nil
 }
@@ -105,39 +384,114 @@ func (m *marshaller) marshalValue(v reflect.Value) error {
 // marshalStruct encodes a struct into TOML format.
 // Fields are sorted alphabetically and nested structures create new tables.
 // It respects toml tags for field names and skip directives.
+// Fields holding a nil interface are omitted, as are fields of unsupported
+// kinds when the encoder skips them.
+// Fields tagged with a dotted path (toml:"one.value") are grouped under
+// nested tables for that path, the same layout Unmarshal reads them from.
+// Struct fields tagged ",inline" have their fields emitted at this level,
+// as are the entries of a map field tagged ",remaining".
 func (m *marshaller) marshalStruct(v reflect.Value) error {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
-
-	t := v.Type()
 	type fieldInfo struct {
-		tomlName  string
-		fieldName string
+		tomlName string
+		value    reflect.Value
 	}
 	sortedFields := []fieldInfo{}
 	sortedNestedFields := []fieldInfo{}
-
-	// Collect and sort field names
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if !field.IsExported() {
-			continue
+	groups := map[string]any{}
+	names := map[string]bool{}
+
+	// Collect field names, descending into inline fields
+	var collect func(v reflect.Value) error
+	collect = func(v reflect.Value) error {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			tag := m.fieldTag(field)
+			if tag.skip || tag.has("raw") {
+				continue // raw fields echo the decoded source and are never written
+			}
+			tomlName := tag.name
+
+			fieldValue := getBareValue(v.Field(i))
+			if !fieldValue.IsValid() {
+				continue // nil interface or pointer, TOML has no null
+			}
+			if m.options.skipUnsupported && !isTable(fieldValue) && m.unsupported(fieldValue.Kind()) {
+				continue
+			}
+
+			if tag.has("inline") {
+				if fieldValue.Kind() != reflect.Struct || fieldValue.Type() == timeType {
+					return errorf(fmt.Errorf(errUnsupported), "inline", field.Name)
+				}
+				if err := collect(fieldValue); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if tag.has("remaining") {
+				if fieldValue.Kind() != reflect.Map || fieldValue.Type().Key().Kind() != reflect.String {
+					return errorf(fmt.Errorf(errUnsupported), "remaining", field.Name)
+				}
+				// The overflow keys go back into the table they were decoded from
+				iter := fieldValue.MapRange()
+				for iter.Next() {
+					key, value := iter.Key().String(), getBareValue(iter.Value())
+					if !value.IsValid() || (m.options.skipUnsupported && !isTable(value) && m.unsupported(value.Kind())) {
+						continue
+					}
+					if names[key] {
+						return errorf(fmt.Errorf(errDuplicateKey), "key", key)
+					}
+					names[key] = true
+					info := fieldInfo{tomlName: key, value: value}
+					if isTable(value) || isTableArray(value) {
+						sortedNestedFields = append(sortedNestedFields, info)
+					} else {
+						sortedFields = append(sortedFields, info)
+					}
+				}
+				continue
+			}
+
+			if strings.Contains(tomlName, ".") {
+				if err := setPath(groups, strings.Split(tomlName, "."), fieldValue.Interface()); err != nil {
+					return errorf(err, "field", field.Name)
+				}
+				continue
+			}
+
+			if names[tomlName] {
+				return errorf(fmt.Errorf(errDuplicateKey), "key", tomlName)
+			}
+			names[tomlName] = true
+
+			info := fieldInfo{tomlName: tomlName, value: fieldValue}
+			if isTable(fieldValue) || isTableArray(fieldValue) {
+				sortedNestedFields = append(sortedNestedFields, info)
+			} else {
+				sortedFields = append(sortedFields, info)
+			}
 		}
+		return nil
+	}
+	if err := collect(v); err != nil {
+		return errorf(err)
+	}
 
-		tomlName, include := getFieldName(field)
-		if !include {
-			continue
-		}
-
-		fieldValue := getBareValue(v.Field(i))
-		info := fieldInfo{tomlName: tomlName, fieldName: field.Name}
-
-		if fieldValue.Kind() == reflect.Map || fieldValue.Kind() == reflect.Struct {
-			sortedNestedFields = append(sortedNestedFields, info)
-		} else {
-			sortedFields = append(sortedFields, info)
+	// Dotted-tag groups become nested tables alongside the nested fields
+	for name, group := range groups {
+		if names[name] {
+			return errorf(fmt.Errorf(errDuplicateKey), "key", name)
 		}
+		sortedNestedFields = append(sortedNestedFields, fieldInfo{tomlName: name, value: reflect.ValueOf(group)})
 	}
+
 	sort.Slice(sortedFields, func(i, j int) bool {
 		return strings.ToLower(sortedFields[i].tomlName) < strings.ToLower(sortedFields[j].tomlName)
 	})
@@ -147,27 +501,30 @@ func (m *marshaller) marshalStruct(v reflect.Value) error {
 
 	// Marshal non-nested fields
 	for _, info := range sortedFields {
-		value := getBareValue(v.FieldByName(info.fieldName))
-
-		m.buffer.WriteString(info.tomlName)
-		m.buffer.WriteString(" = ")
-		if err := m.marshalValue(value); err != nil {
-			return errorf(fn, err)
+		if err := m.writeKey(info.tomlName); err != nil {
+			return errorf(err)
+		}
+		if err := m.marshalValue(info.value); err != nil {
+			return errorf(err)
 		}
 		m.buffer.WriteString("\n")
 	}
 
 	// Marshal nested fields
 	for _, info := range sortedNestedFields {
+		if isTableArray(info.value) {
+			if err := m.marshalTableArray(info.tomlName, info.value); err != nil {
+				return errorf(err)
+			}
+			continue
+		}
+
 		m.pushLevel(info.tomlName)
 
-		m.buffer.WriteString("[")
-		m.buffer.WriteString(strings.Join(m.path, "."))
-		m.buffer.WriteString("]\n")
+		m.writeHeader()
 
-		value := getBareValue(v.FieldByName(info.fieldName))
-		if err := m.marshalValue(value); err != nil {
-			return errorf(fn, err)
+		if err := m.marshalValue(info.value); err != nil {
+			return errorf(err)
 		}
 
 		m.popLevel()
@@ -179,34 +536,29 @@ func (m *marshaller) marshalStruct(v reflect.Value) error {
 // marshalMap processes and encodes a map value into TOML format.
 // Keys must be strings and are sorted alphabetically.
 // Nested maps and structs create new tables with dotted notation.
+// Keys holding a nil value, or an unsupported kind the encoder skips, are omitted.
 func (m *marshaller) marshalMap(v reflect.Value) error {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
-
 	if v.Len() == 0 || v.IsNil() {
 		return nil
 	}
 
-	hasNestedValue := func(v reflect.Value) bool {
-		if v.Kind() == reflect.Map || v.Kind() == reflect.Struct {
-			return true
-		}
-		return false
-	}
-
 	sortedKeys := []string{}
 	sortedNestedKeys := []string{}
 
 	keys := v.MapKeys()
 	for _, k := range keys {
 		if k.Kind() != reflect.String {
-			return errorf(fn, fmt.Errorf(errInvalidKey), errInvalidString, "type", reflect.TypeOf(k).String(), "value", reflect.ValueOf(k).String())
+			return errorf(fmt.Errorf(errInvalidKey), errInvalidString, "type", reflect.TypeOf(k).String(), "value", reflect.ValueOf(k).String())
 		}
 		key := k.String()
-		if !isValidKey(key) {
-			return errorf(fn, fmt.Errorf(errInvalidKey), "key", key)
+		value := getBareValue(v.MapIndex(k))
+		if !value.IsValid() {
+			continue // nil interface or pointer, TOML has no null
+		}
+		if m.options.skipUnsupported && !isTable(value) && m.unsupported(value.Kind()) {
+			continue
 		}
-		if hasNestedValue(getBareValue(v.MapIndex(k))) {
+		if isTable(value) || isTableArray(value) {
 			sortedNestedKeys = append(sortedNestedKeys, key)
 		} else {
 			sortedKeys = append(sortedKeys, key)
@@ -218,37 +570,63 @@ func (m *marshaller) marshalMap(v reflect.Value) error {
 	for _, key := range sortedKeys {
 		value := getBareValue(v.MapIndex(reflect.ValueOf(key)))
 
-		m.buffer.WriteString(key)
-		m.buffer.WriteString(" = ")
+		if err := m.writeKey(key); err != nil {
+			return errorf(err)
+		}
 		if err := m.marshalValue(value); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(value).String(), "value", reflect.ValueOf(value).String())
+			return errorf(err, "type", reflect.TypeOf(value).String(), "value", reflect.ValueOf(value).String())
 		}
 		m.buffer.WriteString("\n")
 	}
 
 	for _, key := range sortedNestedKeys {
-		m.pushLevel(key)
+		value := getBareValue(v.MapIndex(reflect.ValueOf(key)))
+		if isTableArray(value) {
+			if err := m.marshalTableArray(key, value); err != nil {
+				return errorf(err, "key", key)
+			}
+			continue
+		}
 
-		m.buffer.WriteString("[")
-		m.buffer.WriteString(strings.Join(m.path, "."))
-		m.buffer.WriteString("]\n")
+		m.pushLevel(key)
 
-		value := getBareValue(v.MapIndex(reflect.ValueOf(key)))
+		m.writeHeader()
 
 		if err := m.marshalValue(value); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(value).String(), "value", reflect.ValueOf(value).String())
+			return errorf(err, "type", reflect.TypeOf(value).String(), "value", reflect.ValueOf(value).String())
 		}
 		m.popLevel()
 	}
 	return nil
 }
 
+// marshalTableArray encodes a slice of tables as one [[key]] block per element,
+// each followed by the element's keys and its own nested tables
+func (m *marshaller) marshalTableArray(key string, v reflect.Value) error {
+	m.pushLevel(key)
+	defer m.popLevel()
+
+	for i := 0; i < v.Len(); i++ {
+		elem := getBareValue(v.Index(i))
+		if !elem.IsValid() {
+			if m.options.skipNilElements {
+				continue
+			}
+			return errorf(fmt.Errorf(errNilValue), "index", strconv.Itoa(i))
+		}
+		m.writeArrayHeader()
+		if err := m.marshalValue(elem); err != nil {
+			return errorf(err, "index", strconv.Itoa(i))
+		}
+	}
+	return nil
+}
+
 // marshalSlice converts a slice or array into TOML array format.
 // Empty slices are encoded as []. Elements are comma-separated.
+// Pointer elements are written as the values they point to; a nil element is
+// an error unless the encoder skips nil elements.
 func (m *marshaller) marshalSlice(v reflect.Value) error {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
-
 	if v.Len() == 0 {
 		m.buffer.WriteString("[]")
 		return nil
@@ -256,21 +634,28 @@ func (m *marshaller) marshalSlice(v reflect.Value) error {
 
 	m.buffer.WriteString("[")
 
+	written := 0
 	for i := 0; i < v.Len(); i++ {
-		if i > 0 {
+		elem := getBareValue(v.Index(i))
+		if !elem.IsValid() {
+			if m.options.skipNilElements {
+				continue
+			}
+			return errorf(fmt.Errorf(errNilValue), "index", strconv.Itoa(i))
+		}
+		if written > 0 {
 			m.buffer.WriteString(", ")
 		}
-
-		elem := getBareValue(v.Index(i))
-		if isUnsupportedType(elem.Kind()) {
-			return errorf(fn, fmt.Errorf(errUnsupported), "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
+		written++
+		if m.unsupported(elem.Kind()) {
+			return errorf(fmt.Errorf(errUnsupported), "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
 		}
-		if elem.Kind() == reflect.Map || elem.Kind() == reflect.Struct {
-			return errorf(fn, fmt.Errorf(errUnsupported), "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
+		if isTable(elem) {
+			return errorf(fmt.Errorf(errUnsupported), "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
 		}
 
 		if err := m.marshalValue(elem); err != nil {
-			return errorf(fn, err, "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
+			return errorf(err, "type", reflect.TypeOf(elem).String(), "value", reflect.ValueOf(elem).String())
 		}
 	}
 
@@ -278,45 +663,55 @@ func (m *marshaller) marshalSlice(v reflect.Value) error {
 	return nil
 }
 
-// marshalString encodes a string value with proper escaping.
-// Handles special characters: tab, newline, carriage return, quote, backslash
+// marshalString encodes a string value as a quoted TOML string via quoteString
 func (m *marshaller) marshalString(v reflect.Value) error {
-	m.buffer.WriteByte('"')
-	for _, c := range v.String() {
-		switch c {
-		case '\t':
-			m.buffer.WriteByte('\\')
-			m.buffer.WriteByte('t')
-		case '\n':
-			m.buffer.WriteByte('\\')
-			m.buffer.WriteByte('n')
-		case '\r':
-			m.buffer.WriteByte('\\')
-			m.buffer.WriteByte('r')
-		case '"':
-			m.buffer.WriteByte('\\')
-			m.buffer.WriteByte('"')
-		case '\\':
-			m.buffer.WriteByte('\\')
-			m.buffer.WriteByte('\\')
-		default:
-			m.buffer.WriteRune(c)
-		}
-	}
-	m.buffer.WriteByte('"')
+	m.buffer.WriteString(quoteString(v.String()))
 	return nil
 }
 
 // marshalInt formats an integer value (signed or unsigned) in base 10
+// TOML integers are 64-bit signed, so unsigned values above math.MaxInt64 are rejected
 func (m *marshaller) marshalInt(v reflect.Value) error {
-	m.buffer.WriteString(strconv.FormatInt(v.Int(), 10))
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return errorf(&OverflowError{Literal: strconv.FormatUint(v.Uint(), 10), BitSize: 64})
+		}
+		m.buffer.WriteString(strconv.FormatUint(v.Uint(), 10))
+	default:
+		m.buffer.WriteString(strconv.FormatInt(v.Int(), 10))
+	}
+	return nil
+}
+
+// marshalRune encodes an int32 as a single-character string when runes are
+// written as strings (sep = ","). Values that are not valid runes are rejected.
+func (m *marshaller) marshalRune(v reflect.Value) error {
+	r := rune(v.Int())
+	if !utf8.ValidRune(r) {
+		return errorf(fmt.Errorf(errInvalidRune), strconv.FormatInt(v.Int(), 10))
+	}
+	m.buffer.WriteString(quoteString(string(r)))
 	return nil
 }
 
 // marshalFloat formats a floating-point number with decimal point
 // Ensures at least one decimal place is always present (e.g. 1.0 not 1)
+// A fixed precision set on the encoder replaces the shortest round-trip form
+// NaN and infinities are rejected with the key they belong to, since the
+// parser has no literal for them and the output would not read back
 func (m *marshaller) marshalFloat(v reflect.Value) error {
-	s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	f := v.Float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		// FormatFloat would write NaN or +Inf, which does not parse back
+		context := []string{"value " + strconv.FormatFloat(f, 'g', -1, 64)}
+		if m.key != "" {
+			context = append(context, "key", formatPath(append(m.path[:len(m.path):len(m.path)], m.key)))
+		}
+		return errorf(fmt.Errorf(errUnsupported), context...)
+	}
+
+	s := strconv.FormatFloat(f, 'f', m.options.floatPrecision, 64)
 	if !strings.Contains(s, ".") {
 		s += ".0"
 	}
@@ -324,13 +719,87 @@ func (m *marshaller) marshalFloat(v reflect.Value) error {
 	return nil
 }
 
-// marshalBool converts boolean value to "true" or "false" string
+// marshalTime formats a time.Time as an RFC 3339 offset date-time
+// Fractional seconds are kept only when present and the offset is preserved
+// With SetUnixTimestamps, it writes an integer Unix timestamp instead
+func (m *marshaller) marshalTime(v reflect.Value) error {
+	t := v.Interface().(time.Time)
+	if m.options.unixUnit > 0 {
+		ts, err := unixTimestamp(t, m.options.unixUnit)
+		if err != nil {
+			return errorf(err)
+		}
+		m.buffer.WriteString(strconv.FormatInt(ts, 10))
+		return nil
+	}
+	m.buffer.WriteString(t.Format(time.RFC3339Nano))
+	return nil
+}
+
+// marshalComplex writes a complex number as the two-element float array
+// [real, imag], formatted like any other float
+func (m *marshaller) marshalComplex(v reflect.Value) error {
+	c := v.Complex()
+	m.buffer.WriteString("[")
+	for i, part := range []float64{real(c), imag(c)} {
+		if i > 0 {
+			m.buffer.WriteString(", ")
+		}
+		value := reflect.ValueOf(part)
+		if v.Kind() == reflect.Complex64 {
+			value = reflect.ValueOf(float32(part))
+		}
+		if err := m.marshalFloat(value); err != nil {
+			return err
+		}
+	}
+	m.buffer.WriteString("]")
+	return nil
+}
+
+// unsupported reports whether values of a kind cannot be written, which
+// includes complex numbers unless the encoder writes them as arrays
+func (m *marshaller) unsupported(kind reflect.Kind) bool {
+	if m.options.complexArrays && (kind == reflect.Complex64 || kind == reflect.Complex128) {
+		return false
+	}
+	return isUnsupportedType(kind)
+}
+
+// marshalBool converts boolean value to "true" or "false" string,
+// or to the spelling of the encoder's bool format
 func (m *marshaller) marshalBool(v reflect.Value) error {
-	if v.Bool() {
-		m.buffer.WriteString("true")
-	} else {
-		m.buffer.WriteString("false")
+	m.buffer.WriteString(m.options.boolFormat.text(v.Bool()))
+	return nil
+}
+
+// writeHeader emits the table header for the current path and records it as the open table
+func (m *marshaller) writeHeader() {
+	m.buffer.WriteString("[")
+	m.buffer.WriteString(formatPath(m.path))
+	m.buffer.WriteString("]\n")
+	m.table = append(m.table[:0], m.path...)
+}
+
+// writeArrayHeader emits the [[path]] header starting a new element of an
+// array of tables; the keys that follow belong to that element
+func (m *marshaller) writeArrayHeader() {
+	m.buffer.WriteString("[[")
+	m.buffer.WriteString(formatPath(m.path))
+	m.buffer.WriteString("]]\n")
+	m.table = append(m.table[:0], m.path...)
+}
+
+// writeKey emits the "key = " prefix of a key-value pair in the current table
+// Keys are only valid directly after their own table's header: once a nested
+// header is written, a later key would be reparsed into the nested table
+func (m *marshaller) writeKey(key string) error {
+	if !slices.Equal(m.table, m.path) {
+		return errorf(fmt.Errorf(errKeyAfterTable), "key", key, "table", formatPath(m.table))
 	}
+	m.key = key
+	m.buffer.WriteString(formatKey(key))
+	m.buffer.WriteString(" = ")
 	return nil
 }
 
@@ -348,18 +817,87 @@ func (m *marshaller) popLevel() {
 	return
 }
 
-// getFieldName extracts the TOML key name from struct field tags
-// Returns the tag value if present, field name otherwise
-// Second return value indicates if field should be included
-func getFieldName(field reflect.StructField) (string, bool) {
-	if tag, ok := field.Tag.Lookup("toml"); ok {
-		if tag == "-" {
-			return "", false // Skip this field
+// fieldTag is the parsed form of a struct field's toml tag
+type fieldTag struct {
+	name    string          // TOML key, the field name when the tag gives none
+	key     string          // key decode matches the field by: the tag name before any section, or the field name
+	skip    bool            // toml:"-", the field is never encoded or decoded
+	options map[string]bool // options listed after the name, e.g. inline
+}
+
+// has reports whether the tag lists option after the name
+func (t fieldTag) has(option string) bool {
+	return t.options[option]
+}
+
+// parseTag is the single parser for toml struct tags: toml:"name,opt1,opt2"
+// An empty name keeps the field name (toml:",inline"), toml:"-" skips the field
+// and toml:"-," names the key "-". Options are trimmed and empty ones ignored.
+// The section=path option places the key in that table (toml:"port,section=network"
+// is the same as toml:"network.port"), so flat fields share a table on both
+// marshal and decode. A ",raw" field receives the source text of its table on
+// decode and is never marshaled.
+func parseTag(field reflect.StructField) fieldTag {
+	return parseTagDefault(field, field.Name)
+}
+
+// parseTagDefault is parseTag with the key used when the tag gives no name
+func parseTagDefault(field reflect.StructField, defaultName string) fieldTag {
+	tag, ok := field.Tag.Lookup("toml")
+	if !ok {
+		return fieldTag{name: defaultName, key: field.Name}
+	}
+	if tag == "-" {
+		return fieldTag{skip: true}
+	}
+
+	name, rest, _ := strings.Cut(tag, ",")
+	result := fieldTag{name: strings.TrimSpace(name), key: strings.TrimSpace(name)}
+	if result.name == "" {
+		result.name, result.key = defaultName, field.Name
+	}
+	for _, option := range strings.Split(rest, ",") {
+		if section, ok := strings.CutPrefix(strings.TrimSpace(option), "section="); ok {
+			if section = strings.TrimSpace(section); section != "" {
+				result.name = section + "." + result.name
+			}
+			continue
 		}
-		parts := strings.Split(tag, ",")
-		if parts[0] != "" {
-			return parts[0], true
+		if option = strings.TrimSpace(option); option != "" {
+			if result.options == nil {
+				result.options = make(map[string]bool)
+			}
+			result.options[option] = true
 		}
 	}
-	return field.Name, true
+	return result
+}
+
+// resolveTag is parseTag with the naming options of an Encoder or Decoder for
+// fields whose toml tag gives no name. With jsonTags, a field without a toml tag
+// takes its name from its json tag and json:"-" skips it (json options such as
+// omitempty are ignored). Any other unnamed field is named keyFunc(field name)
+// when keyFunc is set, and by its field name otherwise.
+func resolveTag(field reflect.StructField, jsonTags bool, keyFunc func(string) string) fieldTag {
+	defaultName := field.Name
+	if keyFunc != nil {
+		defaultName = keyFunc(field.Name)
+	}
+
+	if _, ok := field.Tag.Lookup("toml"); !ok && jsonTags {
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				return fieldTag{skip: true, key: field.Name}
+			}
+			if name, _, _ := strings.Cut(tag, ","); strings.TrimSpace(name) != "" {
+				return fieldTag{name: strings.TrimSpace(name), key: field.Name}
+			}
+		}
+	}
+	return parseTagDefault(field, defaultName)
+}
+
+// fieldTag parses a struct field's tag with the encoder's naming options
+func (m *marshaller) fieldTag(field reflect.StructField) fieldTag {
+	return resolveTag(field, m.options.jsonTags, m.options.keyFunc)
 }
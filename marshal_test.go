@@ -2,10 +2,14 @@ package tinytoml
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMarshal(t *testing.T) {
@@ -244,6 +248,25 @@ tag_list = ["a", "b", "c"]
 			wantErr:  false,
 			errormsg: "",
 		},
+		{
+			name: "marshal interleaved scalar and table fields",
+			input: struct {
+				Name   string
+				Server struct{ Port int }
+				Debug  bool
+				Limits map[string]int
+				Tags   []string
+			}{
+				Name:   "app",
+				Server: struct{ Port int }{Port: 80},
+				Debug:  true,
+				Limits: map[string]int{"max": 5},
+				Tags:   []string{"a"},
+			},
+			expected: "Debug = true\nName = \"app\"\nTags = [\"a\"]\n[Limits]\nmax = 5\n[Server]\nPort = 80\n",
+			wantErr:  false,
+			errormsg: "",
+		},
 		{
 			name: "marshal mixed array",
 			input: map[string]any{
@@ -253,6 +276,171 @@ tag_list = ["a", "b", "c"]
 			wantErr:  false,
 			errormsg: "",
 		},
+		{
+			name: "marshal datetime",
+			input: map[string]any{
+				"Start": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			expected: "Start = 2023-01-01T00:00:00Z\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal datetime array",
+			input: map[string]any{
+				"Events": []time.Time{
+					time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					time.Date(2023, 6, 1, 12, 30, 0, 500000000, time.FixedZone("", 2*60*60)),
+				},
+			},
+			expected: "Events = [2023-01-01T00:00:00Z, 2023-06-01T12:30:00.5+02:00]\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal dotted tags as nested tables",
+			input: struct {
+				Name  string `toml:"name"`
+				One   string `toml:"one.value"`
+				Two   int    `toml:"one.two.count"`
+				Three bool   `toml:"three.enabled"`
+			}{Name: "groups", One: "first", Two: 2, Three: true},
+			expected: `name = "groups"
+[one]
+value = "first"
+[one.two]
+count = 2
+[three]
+enabled = true
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal section tags as tables",
+			input: struct {
+				Name    string `toml:"name"`
+				Host    string `toml:"host,section=network"`
+				Port    int    `toml:"port,section=network"`
+				Enabled bool   `toml:"enabled,section=network.tls"`
+				Level   string `toml:",section=log"`
+			}{Name: "app", Host: "localhost", Port: 8080, Enabled: true, Level: "info"},
+			expected: `name = "app"
+[log]
+Level = "info"
+[network]
+host = "localhost"
+port = 8080
+[network.tls]
+enabled = true
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal dotted tag colliding with field",
+			input: struct {
+				One    string `toml:"one"`
+				Nested string `toml:"one.value"`
+			}{One: "scalar", Nested: "nested"},
+			expected: "",
+			wantErr:  true,
+			errormsg: errDuplicateKey,
+		},
+		{
+			name:     "marshal bare-looking strings quoted",
+			input:    map[string]any{"bare_str": "simple", "flag": "false", "num": "-1.5"},
+			expected: "bare_str = \"simple\"\nflag = \"false\"\nnum = \"-1.5\"\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal whole float distinct from integer",
+			input:    map[string]any{"f": float64(8080), "i": int64(8080)},
+			expected: "f = 8080.0\ni = 8080\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal inline struct field",
+			input: struct {
+				Name    string `toml:"name"`
+				Network struct {
+					Host string `toml:"host"`
+					Port int    `toml:"port"`
+					TLS  struct {
+						Enabled bool `toml:"enabled"`
+					} `toml:"tls"`
+				} `toml:",inline"`
+			}{Name: "app"},
+			expected: `host = ""
+name = "app"
+port = 0
+[tls]
+enabled = false
+`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal inline field colliding with parent",
+			input: struct {
+				Name  string `toml:"name"`
+				Inner struct {
+					Name string `toml:"name"`
+				} `toml:",inline"`
+			}{},
+			expected: "",
+			wantErr:  true,
+			errormsg: errDuplicateKey,
+		},
+		{
+			name: "marshal inline non-struct",
+			input: struct {
+				Names []string `toml:",inline"`
+			}{},
+			expected: "",
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+		{
+			name: "marshal fixed-size arrays",
+			input: struct {
+				Point [3]int     `toml:"point"`
+				Grid  [2][2]int  `toml:"grid"`
+				Names [2]string  `toml:"names"`
+				Empty [0]float64 `toml:"empty"`
+			}{Point: [3]int{1, 0, 0}, Grid: [2][2]int{{1, 2}, {0, 0}}, Names: [2]string{"a"}},
+			expected: "empty = []\ngrid = [[1, 2], [0, 0]]\nnames = [\"a\", \"\"]\npoint = [1, 0, 0]\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal map with nil value",
+			input:    map[string]any{"x": nil, "y": 1},
+			expected: "y = 1\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal struct with nil interface field",
+			input: struct {
+				Name  string
+				Extra any
+			}{Name: "app"},
+			expected: "Name = \"app\"\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "marshal array with nil element",
+			input: map[string]any{
+				"Values": []any{1, nil},
+			},
+			expected: "",
+			wantErr:  true,
+			errormsg: errNilValue,
+		},
 		{
 			name: "marshal array with unsupported type",
 			input: map[string]any{
@@ -268,6 +456,103 @@ tag_list = ["a", "b", "c"]
 		t.Run(test.name, func(t *testing.T) {
 			result, err := Marshal(test.input)
 
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("-- %s failed: want error but got none.\n- input: %v\n- want: %s\n- got : %s\n\n", fn, test.input, test.expected, result)
+					return
+				}
+
+				if !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: got wrong error.\n- input: %v\n- want: %s\n- got: %s\n- error: %s\n\n", fn, test.input, test.expected, result, err.Error())
+					return
+				}
+
+				// Errors are prefixed by the public entry point
+				if !strings.HasPrefix(err.Error(), "github.com/LixenWraith/tinytoml.Marshal: ") {
+					t.Errorf("-- %s failed: error not prefixed by Marshal.\n- input: %v\n- error: %s\n\n", fn, test.input, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- input: %v\n- want: %s\n- got : %s\n- error: %s\n\n", fn, test.input, test.expected, result, err.Error())
+				return
+			}
+
+			if string(result) != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- input: %v\n- want: %s\n- got: %s\n\n", fn, test.input, test.expected, result)
+				return
+			}
+		})
+	}
+}
+
+func TestMarshalValue(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "marshal bare string",
+			input:    "hello \"world\"",
+			expected: `"hello \"world\""`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal bare integer",
+			input:    -42,
+			expected: "-42",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal bare float",
+			input:    2.0,
+			expected: "2.0",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal bare array",
+			input:    []any{1, "two", [][]int{{3}}},
+			expected: `[1, "two", [[3]]]`,
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal map as document",
+			input:    map[string]int{"port": 8080},
+			expected: "port = 8080\n",
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "marshal nil",
+			input:    nil,
+			expected: "",
+			wantErr:  true,
+			errormsg: errNilValue,
+		},
+		{
+			name:     "marshal unsupported type",
+			input:    make(chan int),
+			expected: "",
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := MarshalValue(test.input)
+
 			if test.wantErr {
 				if err == nil {
 					t.Errorf("-- %s failed: want error but got none.\n- input: %v\n- want: %s\n- got : %s\n\n", fn, test.input, test.expected, result)
@@ -294,6 +579,626 @@ tag_list = ["a", "b", "c"]
 	}
 }
 
+func TestMarshalArrayOfTables(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	type Server struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		input    any
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "slice of structs",
+			key:      "servers",
+			input:    []Server{{Name: "alpha", Port: 80}, {Name: "beta", Port: 443}},
+			expected: "[[servers]]\nname = \"alpha\"\nport = 80\n[[servers]]\nname = \"beta\"\nport = 443\n",
+		},
+		{
+			name:     "slice of maps with quoted key",
+			key:      "my servers",
+			input:    []map[string]any{{"name": "alpha"}},
+			expected: "[[\"my servers\"]]\nname = \"alpha\"\n",
+		},
+		{
+			name:     "empty slice",
+			key:      "servers",
+			input:    []Server{},
+			expected: "",
+		},
+		{
+			name:     "slice of values",
+			key:      "ports",
+			input:    []int{80, 443},
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+		{
+			name:     "not a slice",
+			key:      "server",
+			input:    Server{Name: "alpha"},
+			wantErr:  true,
+			errormsg: errUnsupported,
+		},
+		{
+			name:     "missing key",
+			key:      "",
+			input:    []Server{{Name: "alpha"}},
+			wantErr:  true,
+			errormsg: errMissingKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := MarshalArrayOfTables(test.key, test.input)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.errormsg) {
+					t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, test.errormsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+				return
+			}
+			if string(got) != test.expected {
+				t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, test.expected, got)
+			}
+		})
+	}
+
+	// The output reads back into the same records
+	servers := []Server{{Name: "alpha", Port: 80}, {Name: "beta", Port: 443}}
+	data, err := MarshalArrayOfTables("servers", servers)
+	if err != nil {
+		t.Fatalf("-- %s failed: %v", fn, err)
+	}
+	var doc struct {
+		Servers []Server `toml:"servers"`
+	}
+	if err := Unmarshal(data, &doc); err != nil || !reflect.DeepEqual(doc.Servers, servers) {
+		t.Errorf("-- %s failed: wrong roundtrip.\n- want: %v\n- got: %v (%v)\n\n", fn, servers, doc.Servers, err)
+	}
+}
+
+func TestMarshalWriteTo(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	var buf bytes.Buffer
+	n, err := MarshalWriteTo(&buf, map[string]any{"name": "app", "port": 8080})
+	if err != nil {
+		t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+
+	expected := "name = \"app\"\nport = 8080\n"
+	if buf.String() != expected {
+		t.Errorf("-- %s failed: wrong result.\n- want: %s\n- got: %s\n\n", fn, expected, buf.String())
+	}
+	if n != int64(len(expected)) {
+		t.Errorf("-- %s failed: wrong byte count.\n- want: %d\n- got: %d\n\n", fn, len(expected), n)
+	}
+
+	buf.Reset()
+	n, err = MarshalWriteTo(&buf, make(chan int))
+	if err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("-- %s failed: want error containing %s but got %v\n\n", fn, errUnsupported, err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Errorf("-- %s failed: want nothing written on error but got %d bytes\n\n", fn, n)
+	}
+
+	// Large documents reach the writer in chunks as they are encoded
+	large := make(map[string]string)
+	for i := range 1000 {
+		large[fmt.Sprintf("key%04d", i)] = strings.Repeat("x", 20)
+	}
+	expectedLarge, err := Marshal(large)
+	if err != nil {
+		t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+	cw := &chunkWriter{}
+	n, err = MarshalWriteTo(cw, large)
+	if err != nil {
+		t.Fatalf("-- %s failed: want no error but got one.\n- error: %s\n\n", fn, err.Error())
+	}
+	if cw.buf.String() != string(expectedLarge) || n != int64(len(expectedLarge)) {
+		t.Errorf("-- %s failed: streamed output differs from Marshal (%d bytes, want %d)\n\n", fn, n, len(expectedLarge))
+	}
+	if cw.writes < 2 {
+		t.Errorf("-- %s failed: want output written in chunks but got %d write\n\n", fn, cw.writes)
+	}
+
+	// Writer errors are returned with the bytes written before them
+	n, err = MarshalWriteTo(failingWriter{}, large)
+	if err == nil || n != 0 {
+		t.Errorf("-- %s failed: want writer error but got %v (%d bytes)\n\n", fn, err, n)
+	}
+}
+
+// chunkWriter records the output and how many Write calls produced it
+type chunkWriter struct {
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.buf.Write(p)
+}
+
+// failingWriter rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestMarshal_NumberIdentity(t *testing.T) {
+	input := map[string]any{
+		"float": float64(8080),
+		"int":   int64(8080),
+		"list":  []any{int64(1), float64(2)},
+	}
+
+	output, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got map[string]any
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("roundtrip = %#v, want %#v", got, input)
+	}
+}
+
+func TestMarshal_NumberKindsRoundtrip(t *testing.T) {
+	// Every number keeps its kind through Unmarshal and Marshal on the schemaless path
+	input := `big = 9223372036854775807
+huge = 100000000000000000000.0
+ints = [1, -2, 0x10]
+neg = -0.5
+whole = 8080.0
+zero = 0
+[[servers]]
+port = 80
+weight = 1.0
+[nested]
+floats = [1.0, 2.5]
+mixed = [1, 1.0]
+`
+	want := map[string]any{
+		"big":   int64(math.MaxInt64),
+		"huge":  1e20,
+		"ints":  []any{int64(1), int64(-2), int64(16)},
+		"neg":   -0.5,
+		"whole": 8080.0,
+		"zero":  int64(0),
+		"servers": []any{
+			map[string]any{"port": int64(80), "weight": 1.0},
+		},
+		"nested": map[string]any{
+			"floats": []any{1.0, 2.5},
+			"mixed":  []any{int64(1), 1.0},
+		},
+	}
+
+	var doc map[string]any
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("Unmarshal() = %#v, want %#v", doc, want)
+	}
+
+	output, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var again map[string]any
+	if err := Unmarshal(output, &again); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("roundtrip = %#v, want %#v", again, want)
+	}
+
+	// Interface fields of a struct hold the same kinds
+	type Config struct {
+		Whole any `toml:"whole"`
+		Zero  any `toml:"zero"`
+		Ints  any `toml:"ints"`
+	}
+	var cfg Config
+	if err := Unmarshal(output, &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	wantCfg := Config{Whole: 8080.0, Zero: int64(0), Ints: []any{int64(1), int64(-2), int64(16)}}
+	if !reflect.DeepEqual(cfg, wantCfg) {
+		t.Errorf("Unmarshal() = %#v, want %#v", cfg, wantCfg)
+	}
+	output, err = Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != "ints = [1, -2, 16]\nwhole = 8080.0\nzero = 0\n" {
+		t.Errorf("Marshal() = %q", output)
+	}
+}
+
+func TestMarshal_NamedTypes(t *testing.T) {
+	type Port int
+	type Name string
+	type Flag bool
+	type Ratio float64
+	type Mask uint16
+	type Size uint64
+	type Tags []string
+	type Ports []Port
+
+	type Config struct {
+		Port  Port  `toml:"port"`
+		Name  Name  `toml:"name"`
+		Debug Flag  `toml:"debug"`
+		Ratio Ratio `toml:"ratio"`
+		Mask  Mask  `toml:"mask"`
+		Size  Size  `toml:"size"`
+		Tags  Tags  `toml:"tags"`
+		Ports Ports `toml:"ports"`
+	}
+
+	input := Config{
+		Port:  8080,
+		Name:  "app",
+		Debug: true,
+		Ratio: 0.5,
+		Mask:  0xFFFF,
+		Size:  1 << 40,
+		Tags:  Tags{"a", "b"},
+		Ports: Ports{80, 443},
+	}
+	expected := "debug = true\nmask = 65535\nname = \"app\"\nport = 8080\nports = [80, 443]\nratio = 0.5\nsize = 1099511627776\ntags = [\"a\", \"b\"]\n"
+
+	output, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	var got Config
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("roundtrip = %+v, want %+v", got, input)
+	}
+
+	// TOML integers are 64-bit signed, larger unsigned values cannot be written
+	if _, err := Marshal(map[string]any{"size": Size(1 << 63)}); err == nil || !strings.Contains(err.Error(), errIntegerOverflow) {
+		t.Errorf("Marshal() error = %v, want error containing %v", err, errIntegerOverflow)
+	}
+}
+
+func TestMarshal_InterfaceFields(t *testing.T) {
+	type Inner struct {
+		Port int `toml:"port"`
+	}
+	type Config struct {
+		Name    any          `toml:"name"`
+		Server  any          `toml:"server"`
+		Labels  any          `toml:"labels"`
+		Ports   any          `toml:"ports"`
+		Ratio   any          `toml:"ratio"`
+		Label   fmt.Stringer `toml:"label"`
+		Missing any          `toml:"missing"`
+	}
+
+	input := Config{
+		Name:   "app",
+		Server: Inner{Port: 8080},
+		Labels: map[string]any{"env": "prod"},
+		Ports:  []int{80, 443},
+		Ratio:  0.5,
+		Label:  90 * time.Second,
+	}
+	expected := `label = 90000000000
+name = "app"
+ports = [80, 443]
+ratio = 0.5
+[labels]
+env = "prod"
+[server]
+port = 8080
+`
+
+	output, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	// Empty interface fields decode back into the generic representation,
+	// the non-empty one into the concrete type it already holds
+	got := Config{Label: time.Duration(0)}
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Config{
+		Name:   "app",
+		Server: map[string]any{"port": int64(8080)},
+		Labels: map[string]any{"env": "prod"},
+		Ports:  []any{int64(80), int64(443)},
+		Ratio:  0.5,
+		Label:  90 * time.Second,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("roundtrip = %#v, want %#v", got, want)
+	}
+}
+
+func TestMarshal_QuotedKeys(t *testing.T) {
+	input := map[string]any{
+		"123":    "numeric",
+		"my key": int64(1),
+		"a.b":    true,
+		"":       "empty",
+		"plain":  "bare",
+		"ports": map[string]any{
+			"8080":       "http",
+			"say \"hi\"": map[string]any{"x": int64(1)},
+		},
+	}
+	expected := `"" = "empty"
+"123" = "numeric"
+"a.b" = true
+"my key" = 1
+plain = "bare"
+[ports]
+"8080" = "http"
+[ports."say \"hi\""]
+x = 1
+`
+
+	output, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	var got map[string]any
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("roundtrip = %v, want %v", got, input)
+	}
+
+	// Indented output keeps the quoted header depth
+	indented, err := MarshalIndent(input, "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent() error = %v", err)
+	}
+	if !strings.Contains(string(indented), "\n  [ports.\"say \\\"hi\\\"\"]\n    x = 1\n") {
+		t.Errorf("MarshalIndent() = %s, want nested quoted header", indented)
+	}
+}
+
+func TestMarshal_QuotedHeaderSegments(t *testing.T) {
+	type Config struct {
+		Hosts map[string]map[string]any `toml:"hosts"`
+	}
+	input := Config{Hosts: map[string]map[string]any{
+		"example.com": {"port": int64(443)},
+		"plain":       {"sites": []any{map[string]any{"path": "/"}}},
+		"a.b":         {"sites": []any{map[string]any{"path": "/x"}, map[string]any{"path": "/y"}}},
+	}}
+	expected := `[hosts]
+[hosts."a.b"]
+[[hosts."a.b".sites]]
+path = "/x"
+[[hosts."a.b".sites]]
+path = "/y"
+[hosts."example.com"]
+port = 443
+[hosts.plain]
+[[hosts.plain.sites]]
+path = "/"
+`
+
+	output, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	var got Config
+	if err := Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("roundtrip = %v, want %v", got, input)
+	}
+
+	// Errors name the path the same way, so a dotted segment stays one key
+	err = Unmarshal([]byte("[hosts.\"a.b\"]\nport = 1\n[hosts.\"a.b\".port]"), &map[string]any{})
+	if err == nil || !strings.Contains(err.Error(), `[key, hosts."a.b".port]`) {
+		t.Errorf("Unmarshal() error = %v, want quoted path", err)
+	}
+}
+
+func TestMarshal_FlatMaps(t *testing.T) {
+	inputs := []struct {
+		name  string
+		input any
+	}{
+		{"strings", map[string]string{"host": "localhost", "my key": "a \"quoted\"\nvalue", "": "empty", "123": "x"}},
+		{"ints", map[string]int{"port": 8080, "retries": -3, "a.b": 0}},
+		{"scalars", map[string]any{"name": "app", "port": 8080, "limit": int64(-1), "debug": true}},
+		{"empty strings", map[string]string{}},
+		{"empty ints", map[string]int{}},
+		{"nested falls back", map[string]any{"name": "app", "db": map[string]any{"port": 5432}}},
+		{"floats fall back", map[string]any{"ratio": 0.5, "name": "app"}},
+		{"nil falls back", map[string]any{"name": "app", "unset": nil}},
+	}
+
+	for _, tt := range inputs {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			// The encoder always takes the generic path
+			var want bytes.Buffer
+			if err := NewEncoder(&want).Encode(tt.input); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("Marshal() = %q, want %q", got, want.Bytes())
+			}
+		})
+	}
+}
+
+func TestMarshal_NonFiniteFloats(t *testing.T) {
+	type Limits struct {
+		Ratio float64 `toml:"ratio"`
+	}
+
+	tests := []struct {
+		name    string
+		input   any
+		wantKey string
+	}{
+		{name: "NaN field", input: Limits{Ratio: math.NaN()}, wantKey: "key, ratio"},
+		{name: "infinite nested value", input: map[string]any{"db": map[string]any{"max": math.Inf(1)}}, wantKey: "key, db.max"},
+		{name: "array element", input: map[string]any{"vals": []float64{1, math.Inf(-1)}}, wantKey: "key, vals"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.input)
+			if err == nil || !strings.Contains(err.Error(), errUnsupported) || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("Marshal() error = %v, want error containing %v and %v", err, errUnsupported, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestMarshal_TableArrays(t *testing.T) {
+	type TLS struct {
+		Enabled bool `toml:"enabled"`
+	}
+	type Server struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+		TLS  TLS    `toml:"tls"`
+	}
+	type Fleet struct {
+		Title   string   `toml:"title"`
+		Servers []Server `toml:"servers"`
+		Spare   []Server `toml:"spare"`
+	}
+
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{
+			name: "slice of maps inside a map",
+			input: map[string]any{
+				"title": "fleet",
+				"servers": []map[string]any{
+					{"name": "alpha", "ports": []any{80, 443}},
+					{"name": "beta", "tls": map[string]any{"enabled": true}},
+				},
+			},
+			expected: "title = \"fleet\"\n[[servers]]\nname = \"alpha\"\nports = [80, 443]\n[[servers]]\nname = \"beta\"\n[servers.tls]\nenabled = true\n",
+		},
+		{
+			name: "interface values holding tables",
+			input: map[string]any{
+				"db": map[string]any{
+					"replicas": []any{
+						map[string]any{"host": "a"},
+						map[string]any{"host": "b", "nested": []any{map[string]any{"x": 1}}},
+					},
+				},
+			},
+			expected: "[db]\n[[db.replicas]]\nhost = \"a\"\n[[db.replicas]]\nhost = \"b\"\n[[db.replicas.nested]]\nx = 1\n",
+		},
+		{
+			name: "slice of structs",
+			input: Fleet{
+				Title:   "fleet",
+				Servers: []Server{{Name: "alpha", Port: 80}, {Name: "beta", Port: 443, TLS: TLS{Enabled: true}}},
+			},
+			expected: "spare = []\ntitle = \"fleet\"\n[[servers]]\nname = \"alpha\"\nport = 80\n[servers.tls]\nenabled = false\n[[servers]]\nname = \"beta\"\nport = 443\n[servers.tls]\nenabled = true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(output) != tt.expected {
+				t.Errorf("Marshal() = %q, want %q", output, tt.expected)
+			}
+
+			// The output reparses into the same shape
+			var got map[string]any
+			if err := Unmarshal(output, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			again, err := Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(again) != tt.expected {
+				t.Errorf("Marshal(Unmarshal()) = %q, want %q", again, tt.expected)
+			}
+		})
+	}
+
+	// Structs roundtrip through the [[servers]] blocks; spare = [] reads back as an empty slice
+	fleet := Fleet{Title: "fleet", Servers: []Server{{Name: "alpha", Port: 80}, {Name: "beta", TLS: TLS{Enabled: true}}}, Spare: []Server{}}
+	output, err := Marshal(fleet)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded Fleet
+	if err := Unmarshal(output, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, fleet) {
+		t.Errorf("roundtrip = %+v, want %+v", decoded, fleet)
+	}
+}
+
 func Test_isUnsupportedTypeError(t *testing.T) {
 	pc, _, _, _ := runtime.Caller(0)
 	fn := runtime.FuncForPC(pc).Name()
@@ -351,6 +1256,76 @@ func Test_isUnsupportedTypeError(t *testing.T) {
 	}
 }
 
+func Test_parseTag(t *testing.T) {
+	type Fields struct {
+		Plain    string
+		Named    string `toml:"name"`
+		Inline   string `toml:",inline"`
+		Options  string `toml:"opts, inline ,omitempty,"`
+		Skipped  string `toml:"-"`
+		Dash     string `toml:"-,"`
+		Dotted   string `toml:"server.host,required"`
+		EmptyTag string `toml:""`
+		Other    string `json:"other"`
+	}
+
+	tests := []struct {
+		field    string
+		expected fieldTag
+	}{
+		{field: "Plain", expected: fieldTag{name: "Plain", key: "Plain"}},
+		{field: "Named", expected: fieldTag{name: "name", key: "name"}},
+		{field: "Inline", expected: fieldTag{name: "Inline", key: "Inline", options: map[string]bool{"inline": true}}},
+		{field: "Options", expected: fieldTag{name: "opts", key: "opts", options: map[string]bool{"inline": true, "omitempty": true}}},
+		{field: "Skipped", expected: fieldTag{skip: true}},
+		{field: "Dash", expected: fieldTag{name: "-", key: "-"}},
+		{field: "Dotted", expected: fieldTag{name: "server.host", key: "server.host", options: map[string]bool{"required": true}}},
+		{field: "EmptyTag", expected: fieldTag{name: "EmptyTag", key: "EmptyTag"}},
+		{field: "Other", expected: fieldTag{name: "Other", key: "Other"}},
+	}
+
+	typ := reflect.TypeOf(Fields{})
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			field, _ := typ.FieldByName(tt.field)
+			got := parseTag(field)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseTag() = %+v, want %+v", got, tt.expected)
+			}
+			if got.has("inline") != tt.expected.options["inline"] {
+				t.Errorf("has(inline) = %v, want %v", got.has("inline"), tt.expected.options["inline"])
+			}
+		})
+	}
+}
+
+func Test_marshaller_writeKey(t *testing.T) {
+	m := &marshaller{
+		buffer: &bytes.Buffer{},
+		path:   []string{},
+		depth:  0,
+	}
+
+	if err := m.writeKey("root"); err != nil {
+		t.Fatalf("writeKey() at root error = %v", err)
+	}
+
+	m.pushLevel("server")
+	m.writeHeader()
+	if err := m.writeKey("port"); err != nil {
+		t.Fatalf("writeKey() in own table error = %v", err)
+	}
+	m.pushLevel("tls")
+	m.writeHeader()
+	m.popLevel()
+
+	// A key for [server] after [server.tls] would be reparsed into the wrong table
+	err := m.writeKey("host")
+	if err == nil || !strings.Contains(err.Error(), errKeyAfterTable) {
+		t.Errorf("writeKey() after nested header error = %v, want error containing %v", err, errKeyAfterTable)
+	}
+}
+
 func Test_marshaller_marshalString(t *testing.T) {
 	pc, _, _, _ := runtime.Caller(0)
 	fn := runtime.FuncForPC(pc).Name()
@@ -409,7 +1384,7 @@ func Test_marshaller_marshalString(t *testing.T) {
 
 			err := m.marshalString(reflect.ValueOf(test.input))
 
-			result := m.buffer.String()
+			result := m.buffer.(*bytes.Buffer).String()
 
 			if test.wantErr {
 				if err == nil {
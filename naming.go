@@ -0,0 +1,57 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a Go field name to snake_case for SetKeyFunc:
+// MaxConns becomes max_conns, and acronyms stay together, so HTTPServer
+// becomes http_server and UserID becomes user_id.
+func SnakeCase(fieldName string) string {
+	return splitWords(fieldName, '_')
+}
+
+// KebabCase converts a Go field name to kebab-case for SetKeyFunc:
+// MaxConns becomes max-conns and HTTPServer becomes http-server.
+func KebabCase(fieldName string) string {
+	return splitWords(fieldName, '-')
+}
+
+// LowerCase converts a Go field name to lower case for SetKeyFunc:
+// MaxConns becomes maxconns.
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// splitWords lowercases name and joins its words with sep
+// A word starts at an upper case letter that follows a lower case letter or
+// digit, or that ends a run of upper case letters followed by a lower case one
+func splitWords(name string, sep rune) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune(sep)
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// matchNormalized is the mapstructure MatchName used by AllowNormalizedKeys:
+// a key matches a field name when both are equal ignoring case, underscores
+// and hyphens, so max_open, max-open and maxOpen all match MaxOpen
+func matchNormalized(mapKey, fieldName string) bool {
+	return normalizeName(mapKey) == normalizeName(fieldName)
+}
+
+// normalizeName lowercases s and drops the word separators _ and -
+func normalizeName(s string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+}
@@ -0,0 +1,34 @@
+package tinytoml
+
+import "testing"
+
+func TestKeyFuncs(t *testing.T) {
+	tests := []struct {
+		input string
+		snake string
+		kebab string
+		lower string
+	}{
+		{input: "Name", snake: "name", kebab: "name", lower: "name"},
+		{input: "MaxConns", snake: "max_conns", kebab: "max-conns", lower: "maxconns"},
+		{input: "HTTPServer", snake: "http_server", kebab: "http-server", lower: "httpserver"},
+		{input: "UserID", snake: "user_id", kebab: "user-id", lower: "userid"},
+		{input: "Port2Name", snake: "port2_name", kebab: "port2-name", lower: "port2name"},
+		{input: "TLS", snake: "tls", kebab: "tls", lower: "tls"},
+		{input: "already_snake", snake: "already_snake", kebab: "already_snake", lower: "already_snake"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SnakeCase(tt.input); got != tt.snake {
+				t.Errorf("SnakeCase(%q) = %q, want %q", tt.input, got, tt.snake)
+			}
+			if got := KebabCase(tt.input); got != tt.kebab {
+				t.Errorf("KebabCase(%q) = %q, want %q", tt.input, got, tt.kebab)
+			}
+			if got := LowerCase(tt.input); got != tt.lower {
+				t.Errorf("LowerCase(%q) = %q, want %q", tt.input, got, tt.lower)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Number is a TOML integer or float kept as the literal text it was written
+// with (3.140, +5, 0x1F), so re-marshaling an untouched value reproduces it
+// exactly instead of its normalized form (3.14, 5, 31).
+// A Decoder with UseNumber stores numbers as Number in map[string]any and any
+// targets; Marshal writes a Number back bare, as it is.
+type Number string
+
+// numberType is the reflect.Type of Number, written bare rather than quoted
+var numberType = reflect.TypeOf(Number(""))
+
+// String returns the literal text of the number
+func (n Number) String() string {
+	return string(n)
+}
+
+// IsFloat reports whether the number is a float literal
+func (n Number) IsFloat() bool {
+	return isFloatLiteral(string(n))
+}
+
+// Int64 returns the number as an int64, failing for floats and out of range integers
+func (n Number) Int64() (int64, error) {
+	if !isIntegerLiteral(string(n)) {
+		return 0, errorf(fmt.Errorf(errInvalidInteger), string(n))
+	}
+	return parseInteger(string(n))
+}
+
+// Float64 returns the number as a float64; integers are converted
+func (n Number) Float64() (float64, error) {
+	if !n.IsFloat() {
+		v, err := n.Int64()
+		if err != nil {
+			return 0, errorf(fmt.Errorf(errInvalidFloat), string(n))
+		}
+		return float64(v), nil
+	}
+	v, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return 0, errorf(fmt.Errorf(errInvalidFloat), string(n))
+	}
+	return v, nil
+}
+
+// value returns the number as the int64 or float64 the parser produces without UseNumber
+func (n Number) value() (any, error) {
+	if n.IsFloat() {
+		return n.Float64()
+	}
+	return n.Int64()
+}
@@ -0,0 +1,37 @@
+package tinytoml
+
+import "testing"
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		input   Number
+		isFloat bool
+		int64   int64
+		float64 float64
+		intErr  bool
+		wantErr bool
+	}{
+		{input: "42", int64: 42, float64: 42},
+		{input: "+5", int64: 5, float64: 5},
+		{input: "0x1F", int64: 31, float64: 31},
+		{input: "3.140", isFloat: true, float64: 3.14, intErr: true},
+		{input: "-1e+3", isFloat: true, float64: -1000, intErr: true},
+		{input: "abc", intErr: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			if got := tt.input.IsFloat(); got != tt.isFloat {
+				t.Errorf("IsFloat() = %v, want %v", got, tt.isFloat)
+			}
+			i, err := tt.input.Int64()
+			if (err != nil) != tt.intErr || (!tt.intErr && i != tt.int64) {
+				t.Errorf("Int64() = %v, %v, want %v", i, err, tt.int64)
+			}
+			f, err := tt.input.Float64()
+			if (err != nil) != tt.wantErr || (!tt.wantErr && f != tt.float64) {
+				t.Errorf("Float64() = %v, %v, want %v", f, err, tt.float64)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// OrderedMap is a TOML table that remembers the order of its keys.
+// Unmarshal and Decode into an *OrderedMap record the order in which keys and
+// tables first appeared in the input, and Marshal writes them back in that order
+// (plain keys before tables, as in every table), so tools can edit a config file
+// without reshuffling it. Nested tables are stored as *OrderedMap, and arrays
+// of tables as []any holding *OrderedMap elements.
+// The zero value is an empty map ready to use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// orderedMapPtrType is the reflect.Type of *OrderedMap, marshaled as a table
+var orderedMapPtrType = reflect.TypeOf((*OrderedMap)(nil))
+
+// NewOrderedMap returns an empty OrderedMap
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+// Len returns the number of keys in the table
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of the table in order
+func (m *OrderedMap) Keys() []string {
+	return slices.Clone(m.keys)
+}
+
+// Get returns the value stored under key and whether it exists
+func (m *OrderedMap) Get(key string) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set stores value under key. A new key is appended after the existing ones,
+// while replacing the value of an existing key keeps its position.
+func (m *OrderedMap) Set(key string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key from the table, if present
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	m.keys = slices.DeleteFunc(m.keys, func(k string) bool { return k == key })
+}
+
+// ToMap returns the table as a plain map[string]any, converting nested
+// tables as well, in the same form Unmarshal produces for a map target
+func (m *OrderedMap) ToMap() map[string]any {
+	result := make(map[string]any, len(m.keys))
+	for key, value := range m.values {
+		switch v := value.(type) {
+		case *OrderedMap:
+			value = v.ToMap()
+		case []any:
+			elems := make([]any, len(v))
+			for i, elem := range v {
+				if sub, ok := elem.(*OrderedMap); ok {
+					elem = sub.ToMap()
+				}
+				elems[i] = elem
+			}
+			value = elems
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// newOrderedMap converts a parsed document into an OrderedMap, ordering keys
+// by the paths recorded while parsing (headers and full key paths, in input order)
+// Keys missing from the record are appended in sorted order
+// Arrays of tables are replayed like the parser builds them: a path ending at
+// one is a [[name]] header moving to its next element, and other paths pass
+// through the element of the latest header
+func newOrderedMap(table map[string]any, order [][]string) *OrderedMap {
+	type arrayKey struct {
+		table *OrderedMap
+		key   string
+	}
+	headers := make(map[arrayKey]int)
+
+	root := toOrderedMap(table)
+	for _, path := range order {
+		current := root
+		for i, segment := range path {
+			if !slices.Contains(current.keys, segment) {
+				current.keys = append(current.keys, segment)
+			}
+
+			var sub *OrderedMap
+			switch value := current.values[segment].(type) {
+			case *OrderedMap:
+				sub = value
+			case []any:
+				if len(value) == 0 {
+					break
+				}
+				if _, ok := value[0].(*OrderedMap); !ok {
+					break // a plain array
+				}
+				key := arrayKey{current, segment}
+				if i == len(path)-1 {
+					headers[key]++
+				}
+				if n := headers[key]; n > 0 && n <= len(value) {
+					sub = value[n-1].(*OrderedMap)
+				}
+			}
+			if sub == nil {
+				break
+			}
+			current = sub
+		}
+	}
+	root.appendUnordered()
+	return root
+}
+
+// toOrderedMap copies a parsed table into an OrderedMap with no keys ordered yet
+func toOrderedMap(table map[string]any) *OrderedMap {
+	m := &OrderedMap{values: make(map[string]any, len(table))}
+	for key, value := range table {
+		if sub, ok := value.(map[string]any); ok {
+			value = toOrderedMap(sub)
+		} else if elems, ok := tableArray(value); ok {
+			tables := make([]any, len(elems))
+			for i, elem := range elems {
+				tables[i] = toOrderedMap(elem.(map[string]any))
+			}
+			value = tables
+		}
+		m.values[key] = value
+	}
+	return m
+}
+
+// appendUnordered adds the keys of m and its subtables that have no recorded position
+func (m *OrderedMap) appendUnordered() {
+	var missing []string
+	for key, value := range m.values {
+		if !slices.Contains(m.keys, key) {
+			missing = append(missing, key)
+		}
+		switch value := value.(type) {
+		case *OrderedMap:
+			value.appendUnordered()
+		case []any:
+			for _, elem := range value {
+				if sub, ok := elem.(*OrderedMap); ok {
+					sub.appendUnordered()
+				}
+			}
+		}
+	}
+	sort.Strings(missing)
+	m.keys = append(m.keys, missing...)
+}
+
+// marshalOrderedMap encodes an OrderedMap like marshalMap, keeping key order
+// Plain keys still precede tables so the output reparses into the same structure
+func (m *marshaller) marshalOrderedMap(om *OrderedMap) error {
+	if om == nil {
+		return nil
+	}
+
+	var plainKeys, tableKeys []string
+	for _, key := range om.keys {
+		value := getBareValue(reflect.ValueOf(om.values[key]))
+		if !value.IsValid() {
+			continue // nil value, TOML has no null
+		}
+		if m.options.skipUnsupported && !isTable(value) && m.unsupported(value.Kind()) {
+			continue
+		}
+		if isTable(value) || isTableArray(value) {
+			tableKeys = append(tableKeys, key)
+		} else {
+			plainKeys = append(plainKeys, key)
+		}
+	}
+
+	for _, key := range plainKeys {
+		if err := m.writeKey(key); err != nil {
+			return errorf(err)
+		}
+		if err := m.marshalValue(reflect.ValueOf(om.values[key])); err != nil {
+			return errorf(err, "key", key)
+		}
+		m.buffer.WriteString("\n")
+	}
+
+	for _, key := range tableKeys {
+		if value := getBareValue(reflect.ValueOf(om.values[key])); isTableArray(value) {
+			if err := m.marshalTableArray(key, value); err != nil {
+				return errorf(err, "key", key)
+			}
+			continue
+		}
+
+		m.pushLevel(key)
+		m.writeHeader()
+		if err := m.marshalValue(reflect.ValueOf(om.values[key])); err != nil {
+			return errorf(err, "key", key)
+		}
+		m.popLevel()
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrderedMap_Roundtrip(t *testing.T) {
+	input := `zeta = 1
+alpha = "a"
+mid.inner = true
+
+[server]
+port = 8080
+host = "localhost"
+
+[database.pool]
+max_open = 10
+
+[database]
+name = "app"
+`
+	// Plain keys move above tables, otherwise the input order is kept
+	expected := `zeta = 1
+alpha = "a"
+[mid]
+inner = true
+[server]
+port = 8080
+host = "localhost"
+[database]
+name = "app"
+[database.pool]
+max_open = 10
+`
+
+	var om OrderedMap
+	if err := Unmarshal([]byte(input), &om); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got, want := om.Keys(), []string{"zeta", "alpha", "mid", "server", "database"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+
+	output, err := Marshal(&om)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	// The plain map view matches what Unmarshal produces for a map target
+	var plain map[string]any
+	if err := Unmarshal([]byte(input), &plain); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(om.ToMap(), plain) {
+		t.Errorf("ToMap() = %v, want %v", om.ToMap(), plain)
+	}
+}
+
+func TestOrderedMap_Edit(t *testing.T) {
+	var om OrderedMap
+	if err := NewDecoder(strings.NewReader("b = 1\na = 2\nc = 3\n")).Decode(&om); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	om.Set("a", int64(20)) // keeps its position
+	om.Set("d", "new")     // appended
+	om.Delete("b")
+	om.Delete("missing")
+
+	sub := NewOrderedMap()
+	sub.Set("y", true)
+	sub.Set("x", false)
+	om.Set("table", sub)
+
+	expected := "a = 20\nc = 3\nd = \"new\"\n[table]\ny = true\nx = false\n"
+	output, err := Marshal(&om)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+	if om.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", om.Len())
+	}
+	if v, ok := om.Get("c"); !ok || v != int64(3) {
+		t.Errorf("Get(c) = %v, %v, want 3, true", v, ok)
+	}
+
+	// An OrderedMap nested in a plain map is still written as a table in order
+	output, err = Marshal(map[string]any{"cfg": sub})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != "[cfg]\ny = true\nx = false\n" {
+		t.Errorf("Marshal() = %q", output)
+	}
+}
+
+func TestOrderedMap_TableArrays(t *testing.T) {
+	input := `[[servers]]
+port = 80
+name = "alpha"
+
+[[servers]]
+name = "beta"
+port = 443
+
+[servers.tls]
+enabled = true
+`
+	expected := `[[servers]]
+port = 80
+name = "alpha"
+[[servers]]
+name = "beta"
+port = 443
+[servers.tls]
+enabled = true
+`
+
+	var om OrderedMap
+	if err := Unmarshal([]byte(input), &om); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	output, err := Marshal(&om)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Marshal() = %q, want %q", output, expected)
+	}
+
+	var plain map[string]any
+	if err := Unmarshal([]byte(input), &plain); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(om.ToMap(), plain) {
+		t.Errorf("ToMap() = %v, want %v", om.ToMap(), plain)
+	}
+}
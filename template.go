@@ -0,0 +1,349 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalTemplate converts a struct into an annotated TOML document meant as a
+// ready-to-edit config file. Every field is emitted, including zero values,
+// in field declaration order. A zero field with a `default` tag takes the
+// tag's value instead; the tag holds the TOML value, except that strings are
+// written without quotes (default:"localhost", default:"10", default:"[80, 443]").
+// A `comment` tag is written as an inline comment after the key, or as a
+// comment line above the header for fields that become tables. A nil pointer
+// to a struct is written as the table of the struct's zero value, and slices
+// of structs as [[name]] blocks, one per element.
+func MarshalTemplate(v any) ([]byte, error) {
+	if v == nil {
+		return nil, errorf(fmt.Errorf(errNilValue))
+	}
+
+	input := getBareValue(reflect.ValueOf(v))
+	if input.Kind() != reflect.Struct || input.Type() == timeType {
+		return nil, errorf(fmt.Errorf(errUnsupported), "type", input.Type().String())
+	}
+
+	root := newTemplateTable("")
+	if err := root.collectTable(input, map[visit]bool{}); err != nil {
+		return nil, errorf(err, "type", input.Type().String())
+	}
+
+	var buf bytes.Buffer
+	root.write(&buf, nil)
+	return buf.Bytes(), nil
+}
+
+// templateTable is one table of the document built by MarshalTemplate
+// Keys and subtables keep the order in which fields were declared
+type templateTable struct {
+	comment string
+	keys    []templateKey
+	tables  []string
+	sub     map[string]*templateTable
+	array   []*templateTable // elements of an array of tables, written as [[name]] blocks
+}
+
+// templateKey is a key-value line of a template table with its encoded value
+type templateKey struct {
+	name    string
+	value   string
+	comment string
+}
+
+// newTemplateTable returns an empty template table documented by comment
+func newTemplateTable(comment string) *templateTable {
+	return &templateTable{comment: comment, sub: map[string]*templateTable{}}
+}
+
+// has reports whether name is already used by a key or subtable
+func (t *templateTable) has(name string) bool {
+	if _, ok := t.sub[name]; ok {
+		return true
+	}
+	for _, key := range t.keys {
+		if key.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// table returns the subtable called name, creating it if needed
+// Dotted tags sharing a prefix (server.host, server.port) reuse the same subtable
+func (t *templateTable) table(name string) (*templateTable, error) {
+	if sub, ok := t.sub[name]; ok {
+		return sub, nil
+	}
+	if t.has(name) {
+		return nil, errorf(fmt.Errorf(errDuplicateKey), "key", name)
+	}
+	sub := newTemplateTable("")
+	t.sub[name] = sub
+	t.tables = append(t.tables, name)
+	return sub, nil
+}
+
+// collectTable adds the fields of a struct or the entries of a map to the table
+// Values reached again from inside themselves are reported as cycles
+func (t *templateTable) collectTable(v reflect.Value, seen map[visit]bool) error {
+	if ref, ok := reference(v); ok {
+		if seen[ref] {
+			return errorf(fmt.Errorf(errCycle), "type", v.Type().String())
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return t.collectStruct(v, seen)
+	case reflect.Map:
+		return t.collectMap(v, seen)
+	default:
+		return errorf(fmt.Errorf(errUnsupported), "type", v.Type().String())
+	}
+}
+
+// collectArray adds the table elements of a slice or array as the blocks of
+// an array of tables; nil elements are skipped, TOML has no null
+func (t *templateTable) collectArray(v reflect.Value, seen map[visit]bool) error {
+	for i := 0; i < v.Len(); i++ {
+		elem := getBareValue(v.Index(i))
+		if !elem.IsValid() {
+			continue
+		}
+		table := newTemplateTable("")
+		if err := table.collectTable(elem, seen); err != nil {
+			return errorf(err, "index", strconv.Itoa(i))
+		}
+		t.array = append(t.array, table)
+	}
+	return nil
+}
+
+// collectStruct adds the fields of a struct value to the table
+// Inline fields are flattened and dotted tags are split into nested tables
+// A nil pointer to a struct is laid out from the struct's zero value, once
+// per type along a path, so recursive types (Next *Node) end
+func (t *templateTable) collectStruct(v reflect.Value, seen map[visit]bool) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := parseTag(field)
+		if tag.skip || tag.has("raw") || tag.has("remaining") {
+			continue
+		}
+
+		fieldValue := getBareValue(v.Field(i))
+		if !fieldValue.IsValid() && isStructType(field.Type) {
+			zero := visit{typ: field.Type.Elem()}
+			if !seen[zero] {
+				seen[zero] = true
+				err := t.collectField(field, tag, reflect.Zero(zero.typ), seen)
+				delete(seen, zero)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := t.collectField(field, tag, fieldValue, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectField adds one struct field to the table, as a key, a table or an
+// array of tables
+func (t *templateTable) collectField(field reflect.StructField, tag fieldTag, fieldValue reflect.Value, seen map[visit]bool) error {
+	tomlName := tag.name
+	if tag.has("inline") {
+		if fieldValue.Kind() != reflect.Struct || fieldValue.Type() == timeType {
+			return errorf(fmt.Errorf(errUnsupported), "inline", field.Name)
+		}
+		return t.collectTable(fieldValue, seen)
+	}
+
+	path := strings.Split(tomlName, ".")
+	parent := t
+	for _, segment := range path[:len(path)-1] {
+		var err error
+		if parent, err = parent.table(segment); err != nil {
+			return errorf(err, "field", field.Name)
+		}
+	}
+	name := path[len(path)-1]
+	comment := field.Tag.Get("comment")
+
+	if fieldValue.IsValid() && (isTable(fieldValue) || isTableArray(fieldValue)) {
+		sub, err := parent.table(name)
+		if err != nil {
+			return errorf(err, "field", field.Name)
+		}
+		sub.comment = comment
+		if isTable(fieldValue) {
+			err = sub.collectTable(fieldValue, seen)
+		} else {
+			err = sub.collectArray(fieldValue, seen)
+		}
+		if err != nil {
+			return errorf(err, "field", field.Name)
+		}
+		return nil
+	}
+
+	value, err := templateValue(field, fieldValue)
+	if err != nil {
+		return errorf(err, "field", field.Name)
+	}
+	if value == "" {
+		return nil // nil interface or pointer without a default, TOML has no null
+	}
+	if parent.has(name) {
+		return errorf(fmt.Errorf(errDuplicateKey), "key", tomlName)
+	}
+	parent.keys = append(parent.keys, templateKey{name: name, value: value, comment: comment})
+	return nil
+}
+
+// collectMap adds the entries of a map value to the table in sorted key order
+// Map entries carry no tags, so they are written without comments
+func (t *templateTable) collectMap(v reflect.Value, seen map[visit]bool) error {
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		if k.Kind() != reflect.String {
+			return errorf(fmt.Errorf(errInvalidKey), "key", fmt.Sprint(k.Interface()))
+		}
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := getBareValue(v.MapIndex(reflect.ValueOf(key)))
+		if !value.IsValid() {
+			continue // nil interface or pointer, TOML has no null
+		}
+
+		if isTable(value) || isTableArray(value) {
+			sub, err := t.table(key)
+			if err != nil {
+				return err
+			}
+			if isTable(value) {
+				err = sub.collectTable(value, seen)
+			} else {
+				err = sub.collectArray(value, seen)
+			}
+			if err != nil {
+				return errorf(err, "key", key)
+			}
+			continue
+		}
+
+		encoded, err := encodeTemplateValue(value)
+		if err != nil {
+			return errorf(err, "key", key)
+		}
+		t.keys = append(t.keys, templateKey{name: key, value: encoded})
+	}
+	return nil
+}
+
+// write emits the table's keys followed by its subtables, each subtable
+// preceded by a blank line and its comment
+func (t *templateTable) write(buf *bytes.Buffer, path []string) {
+	for _, key := range t.keys {
+		buf.WriteString(formatKey(key.name))
+		buf.WriteString(" = ")
+		buf.WriteString(key.value)
+		if key.comment != "" {
+			buf.WriteString(" # ")
+			buf.WriteString(strings.ReplaceAll(key.comment, "\n", " "))
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, name := range t.tables {
+		sub := t.sub[name]
+		subPath := append(path[:len(path):len(path)], name)
+
+		if sub.array == nil {
+			writeTemplateHeader(buf, sub.comment, "["+formatPath(subPath)+"]")
+			sub.write(buf, subPath)
+			continue
+		}
+		// The comment documents the whole array, above its first block
+		comment := sub.comment
+		for _, elem := range sub.array {
+			writeTemplateHeader(buf, comment, "[["+formatPath(subPath)+"]]")
+			elem.write(buf, subPath)
+			comment = ""
+		}
+	}
+}
+
+// writeTemplateHeader writes a table header preceded by a blank line and its
+// comment lines
+func writeTemplateHeader(buf *bytes.Buffer, comment, header string) {
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			buf.WriteString("# ")
+			buf.WriteString(line)
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString(header)
+	buf.WriteString("\n")
+}
+
+// templateValue encodes the value of a field for the template
+// A zero or nil field with a `default` tag is replaced by the tag's value,
+// decoded into the field's type so a default that does not fit is an error
+// Returns an empty string for a nil interface or pointer without a default
+func templateValue(field reflect.StructField, v reflect.Value) (string, error) {
+	def, ok := field.Tag.Lookup("default")
+	if ok && (!v.IsValid() || v.IsZero()) {
+		typ := field.Type
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.String {
+			def = quoteString(def)
+		}
+		holder := reflect.New(reflect.StructOf([]reflect.StructField{
+			{Name: "Value", Type: field.Type, Tag: `toml:"value"`},
+		}))
+		if err := Unmarshal([]byte("value = "+def), holder.Interface()); err != nil {
+			return "", errorf(err, "default", def)
+		}
+		v = getBareValue(holder.Elem().Field(0))
+	}
+
+	if !v.IsValid() {
+		return "", nil
+	}
+	return encodeTemplateValue(v)
+}
+
+// encodeTemplateValue writes a scalar or array value the way Marshal would
+func encodeTemplateValue(v reflect.Value) (string, error) {
+	var buf strings.Builder
+	m := NewEncoder(nil).newMarshaller(&buf)
+	if err := m.marshalValue(v); err != nil {
+		return "", errorf(err)
+	}
+	return buf.String(), nil
+}
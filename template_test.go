@@ -0,0 +1,220 @@
+package tinytoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalTemplate(t *testing.T) {
+	type Pool struct {
+		MaxOpen int64 `toml:"max_open" comment:"Maximum open connections" default:"10"`
+		MaxIdle int64 `toml:"max_idle" default:"5"`
+	}
+	type Config struct {
+		Name    string   `toml:"name" comment:"Instance name"`
+		Host    string   `toml:"server.host" comment:"Server host" default:"localhost"`
+		Port    int64    `toml:"server.port" default:"8080"`
+		Origins []string `toml:"origins" default:"[\"http://localhost:3000\"]"`
+		Debug   bool     `toml:"debug"`
+		Extra   any      `toml:"extra"`
+		Pool    Pool     `toml:"pool" comment:"Connection pool"`
+	}
+
+	tests := []struct {
+		name     string
+		input    Config
+		expected string
+	}{
+		{
+			name:  "zero value uses defaults",
+			input: Config{},
+			expected: `name = "" # Instance name
+origins = ["http://localhost:3000"]
+debug = false
+
+[server]
+host = "localhost" # Server host
+port = 8080
+
+# Connection pool
+[pool]
+max_open = 10 # Maximum open connections
+max_idle = 5
+`,
+		},
+		{
+			name:  "set values win over defaults",
+			input: Config{Name: "app", Port: 9090, Debug: true, Extra: "x", Pool: Pool{MaxIdle: 2}},
+			expected: `name = "app" # Instance name
+origins = ["http://localhost:3000"]
+debug = true
+extra = "x"
+
+[server]
+host = "localhost" # Server host
+port = 9090
+
+# Connection pool
+[pool]
+max_open = 10 # Maximum open connections
+max_idle = 2
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalTemplate(tt.input)
+			if err != nil {
+				t.Fatalf("MarshalTemplate() error = %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("MarshalTemplate() = \n%s\nwant:\n%s", got, tt.expected)
+			}
+
+			// The template is a valid document that decodes back into the struct
+			var decoded Config
+			if err := Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			want := tt.input
+			want.Host, want.Origins = "localhost", []string{"http://localhost:3000"}
+			want.Pool.MaxOpen = 10
+			if want.Port == 0 {
+				want.Port = 8080
+			}
+			if want.Pool.MaxIdle == 0 {
+				want.Pool.MaxIdle = 5
+			}
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", decoded, want)
+			}
+		})
+	}
+}
+
+func TestMarshalTemplate_Tables(t *testing.T) {
+	type Server struct {
+		Host string `toml:"host" default:"localhost"`
+		Port int64  `toml:"port"`
+	}
+	type Node struct {
+		Name string `toml:"name"`
+		Next *Node  `toml:"next"`
+	}
+	type Config struct {
+		Primary *Server  `toml:"primary" comment:"Primary server"`
+		Servers []Server `toml:"servers" comment:"Backends"`
+		Chain   *Node    `toml:"chain"`
+	}
+
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{
+			name:  "nil struct pointers from the zero value",
+			input: Config{},
+			expected: `servers = [] # Backends
+
+# Primary server
+[primary]
+host = "localhost"
+port = 0
+
+[chain]
+name = ""
+`,
+		},
+		{
+			name:  "slices of structs as arrays of tables",
+			input: Config{Servers: []Server{{Host: "a", Port: 1}, {Port: 2}}, Chain: &Node{Name: "a", Next: &Node{Name: "b"}}},
+			expected: `# Primary server
+[primary]
+host = "localhost"
+port = 0
+
+# Backends
+[[servers]]
+host = "a"
+port = 1
+
+[[servers]]
+host = "localhost"
+port = 2
+
+[chain]
+name = "a"
+
+[chain.next]
+name = "b"
+
+[chain.next.next]
+name = ""
+`,
+		},
+		{
+			name:  "map holding a slice of structs",
+			input: struct{ Groups map[string][]*Server }{Groups: map[string][]*Server{"web": {nil, {Host: "w", Port: 80}}}},
+			expected: `[Groups]
+
+[[Groups.web]]
+host = "w"
+port = 80
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalTemplate(tt.input)
+			if err != nil {
+				t.Fatalf("MarshalTemplate() error = %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("MarshalTemplate() = \n%s\nwant:\n%s", got, tt.expected)
+			}
+			if err := Unmarshal(got, &map[string]any{}); err != nil {
+				t.Errorf("Unmarshal() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMarshalTemplate_Errors(t *testing.T) {
+	type BadDefault struct {
+		Port int64 `toml:"port" default:"eighty"`
+	}
+	type Duplicate struct {
+		Host   string `toml:"server.host"`
+		Server string `toml:"server"`
+	}
+	type Node struct {
+		Next *Node `toml:"next"`
+	}
+	cycle := &Node{}
+	cycle.Next = cycle
+
+	tests := []struct {
+		name    string
+		input   any
+		wantErr string
+	}{
+		{name: "default does not fit field", input: BadDefault{}, wantErr: "default"},
+		{name: "key collides with table", input: Duplicate{}, wantErr: errDuplicateKey},
+		{name: "not a struct", input: map[string]any{"a": 1}, wantErr: errUnsupported},
+		{name: "nil", input: nil, wantErr: errNilValue},
+		{name: "reference cycle", input: cycle, wantErr: errCycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MarshalTemplate(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MarshalTemplate() error = %v, want error containing %v", err, tt.wantErr)
+			}
+		})
+	}
+}
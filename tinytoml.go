@@ -3,25 +3,37 @@
 //
 // Features:
 //   - Basic value types: strings, integers, floats, booleans
+//   - Signed integers and floats (+19.99, -42), for map and struct targets alike
+//   - Float exponents with an optional sign (e.g. 1e+10, 2.5E-3), in values and arrays
+//   - Hexadecimal (0x), octal (0o) and binary (0b) integers, with sign support
+//   - Offset date-times (RFC 3339) mapped to time.Time
 //   - Arrays of basic types, nested arrays, and mixed-type arrays
+//   - Arrays spanning multiple lines
 //   - Nested tables using dotted notation
+//   - Quoted table name segments (e.g. [server."my.key"])
+//   - Arrays of tables (e.g. [[servers]]), from and to slices of maps or structs
+//   - Quoted keys, including the empty key (e.g. "" = 1, site."google.com" = true)
 //   - Dotted keys within tables (e.g. server.network.ip = "1.1.1.1")
 //   - Struct tags for custom field names (e.g. `toml:"name"`)
+//   - Dotted struct tags mapped to nested tables (e.g. `toml:"server.host"`)
+//   - Flat struct fields grouped into a table by section (e.g. `toml:"port,section=network"`)
+//   - Inline struct fields flattened into the parent table (`toml:",inline"`)
+//   - Raw struct fields capturing the source text of their table (`toml:",raw"`)
+//   - Catch-all map fields for the keys no other field takes (`toml:",remaining"`)
 //   - Comment handling (inline and single-line)
 //   - Whitespace tolerance
 //   - Table merging (last value wins)
-//   - Basic string escape sequences (\n, \t, \r, \\)
+//   - Array append with += (non-standard extension)
+//   - Basic string escape sequences (\n, \t, \r, \", \\)
+//   - Multi-line basic strings ("""), with first-newline and line-ending backslash trimming
 //
 // Limitations:
-//   - No support for table arrays
-//   - No support for hex, octal, binary, or exponential number formats
-//   - No multi-line keys or strings
+//   - No multi-line keys or multi-line literal strings
 //   - No inline table declarations
 //   - No inline array declarations within tables
 //   - No empty table declarations
-//   - No datetime types
+//   - No local date, local time or local date-time types
 //   - No unicode escape sequences
-//   - No key character escaping
 //   - No literal strings (single quotes)
 //   - Comments are discarded during parsing
 //
@@ -33,7 +45,10 @@ package tinytoml
 import (
 	"fmt"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Error constants used throughout the package for consistent error messaging.
@@ -45,16 +60,44 @@ const (
 	errInvalidKey         = "invalid key format"
 	errInvalidValue       = "invalid value format"
 	errInvalidFormat      = "invalid TOML format"
+	errUnexpectedToken    = "unexpected token after value"
 	errInvalidTarget      = "unmarshal target invalid"
 	errInvalidString      = "invalid string format"
 	errInvalidInteger     = "invalid integer format"
+	errIntegerOverflow    = "integer overflow"
 	errInvalidFloat       = "invalid float format"
 	errInvalidBoolean     = "invalid boolean format"
+	errInvalidDatetime    = "invalid datetime format"
 	errUnterminatedString = "unterminated string"
 	errUnterminatedArray  = "unterminated array"
 	errUnterminatedEscape = "unterminated escape sequence"
 	errInvalidEscape      = "invalid escape sequence"
 	errInvalidTableName   = "invalid table name"
+	errInvalidTableHeader = "invalid table header"
+	errKeyAfterTable      = "key emitted after nested table header"
+	errDuplicateKey       = "duplicate key"
+	errDuplicateTable     = "duplicate table"
+	errRedefineTable      = "cannot redefine key as a table"
+	errRedefineValue      = "cannot redefine table as a value"
+	errRedefineTableArray = "cannot redefine key as an array of tables"
+	errExpectedTable      = "expected table"
+	errInvalidAppend      = "append requires an array value and an existing array"
+	errNotArray           = "value is not an array"
+	errMixedArray         = "mixed-type array"
+	errInvalidIndex       = "invalid array index"
+	errInvalidRune        = "invalid rune"
+	errInvalidNull        = "invalid null"
+	errTabIndent          = "tab used for indentation"
+	errMixedIndent        = "mixed indentation"
+	errArrayLength        = "array length mismatch"
+	errNestingDepth       = "nesting depth limit exceeded"
+	errInvalidUnit        = "invalid timestamp unit"
+	errInputTooLarge      = "input size limit exceeded"
+	errLineTooLong        = "line length limit exceeded"
+	errTooManyKeys        = "key count limit exceeded"
+	errUnknownKey         = "unknown key"
+	errMissingRequired    = "missing required key"
+	errCycle              = "value contains a reference cycle"
 )
 
 // SupportedTypes lists all Go types that can be marshaled/unmarshaled
@@ -72,13 +115,91 @@ var SupportedTypes = []reflect.Kind{
 	reflect.Interface,
 }
 
+// timeType is the reflect.Type of time.Time, encoded as a TOML offset date-time
+// rather than as a table
+var timeType = reflect.TypeOf(time.Time{})
+
+// utf8BOM is the byte-order mark some editors write at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// BoolFormat selects how an Encoder writes booleans, and which spellings a
+// Decoder accepts besides true and false, for consumers that are not TOML-native
+type BoolFormat int
+
+const (
+	BoolLower   BoolFormat = iota // true / false, the TOML spelling and the default
+	BoolTitle                     // True / False
+	BoolNumeric                   // 1 / 0
+)
+
+// text returns the spelling of v in the format
+func (f BoolFormat) text(v bool) string {
+	switch {
+	case f == BoolTitle && v:
+		return "True"
+	case f == BoolTitle:
+		return "False"
+	case f == BoolNumeric && v:
+		return "1"
+	case f == BoolNumeric:
+		return "0"
+	}
+	return strconv.FormatBool(v)
+}
+
 // errorf formats an error with optional context information
-// Prefixes the error with the calling function's name for tracing
-func errorf(fn string, err error, context ...string) error {
+// Prefixes the error with the calling function's name for tracing; the name is
+// resolved here, so callers only pay for the lookup when an error occurs
+// The original error stays reachable through errors.Is and errors.As
+func errorf(err error, context ...string) error {
+	fn := "unknown"
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		fn = runtime.FuncForPC(pc).Name()
+		// Closures (parse.func2) are reported as their enclosing function
+		if i := strings.Index(fn, ".func"); i >= 0 && i+5 < len(fn) && isNumeric(rune(fn[i+5])) {
+			fn = fn[:i]
+		}
+	}
+
 	if len(context) > 0 {
-		return fmt.Errorf("%s: %v [%s]", fn, err, strings.Join(context, ", "))
+		return fmt.Errorf("%s: %w [%s]", fn, err, strings.Join(context, ", "))
+	}
+	return fmt.Errorf("%s: %w", fn, err)
+}
+
+// quoteString is the single quoting rule for string values on every marshal path
+// (Marshal, MarshalValue, MarshalIndent, Encoder): the value is always wrapped in
+// double quotes, with its content escaped by EscapeString
+func quoteString(s string) string {
+	return `"` + EscapeString(s) + `"`
+}
+
+// formatKey writes a key or table name segment for output: bare when the
+// parser reads it back as the same single key, quoted otherwise, so map keys
+// such as "123", "my key" or "a.b" survive a roundtrip
+func formatKey(key string) string {
+	if isValidKey(key) && !strings.Contains(key, ".") {
+		return key
+	}
+	return quoteString(key)
+}
+
+// formatPath joins table name segments with dots, quoting segments as needed
+func formatPath(path []string) string {
+	segments := make([]string, len(path))
+	for i, segment := range path {
+		segments[i] = formatKey(segment)
+	}
+	return strings.Join(segments, ".")
+}
+
+// joinPath appends key to a path formatted by formatPath, or to the path of an
+// element of an array of tables (servers[0]); an empty prefix is the root
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return formatKey(key)
 	}
-	return fmt.Errorf("%s: %v", fn, err)
+	return prefix + "." + formatKey(key)
 }
 
 // isUnsupportedType checks if a reflect.Kind is not in SupportedTypes
@@ -91,6 +212,16 @@ func isUnsupportedType(t reflect.Kind) bool {
 	return true
 }
 
+// isIntegerKind reports whether a reflect.Kind is a signed or unsigned integer
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
 // isAlpha checks if a character is a letter (A-Z, a-z)
 func isAlpha(c rune) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
@@ -121,11 +252,79 @@ func isValidKey(s string) bool {
 	return true
 }
 
-// getBareValue unwraps interface values to their underlying type
+// isTable reports whether a value is encoded as a TOML table
+// Maps, structs and *OrderedMap are tables, except time.Time which is a scalar
+func isTable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		return v.Type() != timeType
+	case reflect.Pointer:
+		return v.Type() == orderedMapPtrType
+	default:
+		return false
+	}
+}
+
+// isTableArray reports whether a value is encoded as an array of tables ([[name]]):
+// a slice or array whose elements are all tables, ignoring nil pointers, with
+// at least one table. Empty slices stay plain arrays (name = []), and slices
+// mixing tables and values are rejected by marshalSlice
+func isTableArray(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false
+	}
+	tables := 0
+	for i := 0; i < v.Len(); i++ {
+		elem := getBareValue(v.Index(i))
+		if !elem.IsValid() {
+			continue
+		}
+		if !isTable(elem) {
+			return false
+		}
+		tables++
+	}
+	return tables > 0
+}
+
+// splitArrayElements splits the contents of an array (without the outer brackets)
+// into its top-level elements, ignoring commas inside strings and nested arrays
+func splitArrayElements(s string) []string {
+	var elements []string
+	inString := false
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			elements = append(elements, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		elements = append(elements, last)
+	}
+	return elements
+}
+
+// getBareValue unwraps interface values and dereferences pointers to their
+// underlying value; a nil interface or pointer gives the invalid Value
+// *OrderedMap is kept, as it is encoded as a table itself
 func getBareValue(v reflect.Value) reflect.Value {
-	if v.Kind() == reflect.Interface {
-		return v.Elem()
-	} else {
-		return v
+	for (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.Type() != orderedMapPtrType {
+		v = v.Elem()
 	}
+	return v
 }
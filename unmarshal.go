@@ -3,41 +3,210 @@ package tinytoml
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
-	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/mitchellh/mapstructure"
 )
 
 // Unmarshal parses TOML data into a Go value.
-// The target must be a pointer to a struct or map.
+// The target must be a pointer to a struct, a map with string keys or an
+// interface (which receives a map[string]any), or an *OrderedMap to keep
+// the order of keys. Other targets, such as *int, are rejected.
 // It supports basic types, arrays, and nested structures through tables.
 func Unmarshal(data []byte, v any) error {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
+	return (&Decoder{}).unmarshal(data, v)
+}
+
+// UnmarshalStrict is Unmarshal with the checks of standard TOML that are relaxed
+// by default: duplicate keys and tables, keys matching no struct field, and
+// arrays mixing element types are all rejected. It is the same as decoding with
+// DisallowDuplicateKeys, DisallowUnknownKeys and DisallowMixedArrays.
+func UnmarshalStrict(data []byte, v any) error {
+	d := &Decoder{}
+	d.DisallowDuplicateKeys()
+	d.DisallowUnknownKeys()
+	d.DisallowMixedArrays()
+	return d.unmarshal(data, v)
+}
 
+// UnmarshalPath parses TOML data and decodes only the value found at path into v,
+// so one section ([database]) can be read without mirroring the whole document.
+// The path uses table header syntax (database.pool, server."my.key") and usually
+// names a table, though any value can be decoded into a matching target.
+func UnmarshalPath(data []byte, path string, v any) error {
+	segments, err := getTableSegments(path, false)
+	if err != nil {
+		return errorf(err, "path", path)
+	}
+
+	if err := checkTarget(v); err != nil {
+		return errorf(err)
+	}
+
+	d := &Decoder{}
+	result, err := d.parse(data)
+	if err != nil {
+		return errorf(err)
+	}
+
+	value, ok := getPath(result, segments)
+	if !ok {
+		return errorf(fmt.Errorf(errMissingKey), "path", path)
+	}
+	return d.decode(value, v)
+}
+
+// unmarshal parses TOML data and decodes it into v using the decoder's hooks
+func (d *Decoder) unmarshal(data []byte, v any) error {
 	if len(data) == 0 {
 		return nil
 	}
+	if err := checkTarget(v); err != nil {
+		return errorf(err)
+	}
+	if err := checkDocumentTarget(reflect.TypeOf(v).Elem()); err != nil {
+		return errorf(err, "type", fmt.Sprintf("%T", v))
+	}
+
+	if ordered, ok := v.(*OrderedMap); ok {
+		return d.unmarshalOrdered(data, ordered)
+	}
+
+	// Source sections are only recorded for targets that have somewhere to keep them
+	if typeHasOption(reflect.TypeOf(v), "raw") {
+		d.rawText = true
+		defer func() { d.rawText = false }()
+	}
+
+	result, err := d.parse(data)
+	if err == nil && len(d.pathHooks) > 0 {
+		err = d.applyPathHooks(result, "")
+	}
+	if err != nil {
+		if d.partial {
+			// Best effort: the target receives the lines that parsed, the error still reports the failure
+			if decodeErr := d.decode(result, v); decodeErr != nil {
+				return errors.Join(err, decodeErr)
+			}
+		}
+		return err
+	}
+
+	if err := d.decode(result, v); err != nil {
+		if mismatch := d.checkTableShapes(data, result, v); mismatch != nil {
+			return mismatch
+		}
+		return err
+	}
+	return nil
+}
+
+// unmarshalOrdered parses TOML data into an OrderedMap, recording the order in
+// which keys appear while parsing
+func (d *Decoder) unmarshalOrdered(data []byte, v *OrderedMap) error {
+	d.order = [][]string{}
+	defer func() { d.order = nil }()
+
+	result, err := d.parse(data)
+	if err == nil && len(d.pathHooks) > 0 {
+		err = d.applyPathHooks(result, "")
+	}
+	if err != nil && !d.partial {
+		return err
+	}
+	*v = *newOrderedMap(result, d.order)
+	return err
+}
+
+// checkTarget rejects decode targets that cannot be written through
+// A nil pointer (var cfg *Config passed as cfg) has nowhere to store the result,
+// while a pointer to it (&cfg) is allocated during decode like nested pointer fields
+func checkTarget(v any) error {
 	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return errorf(fn, fmt.Errorf(errInvalidTarget), "type", reflect.TypeOf(rv).String(), "value", reflect.ValueOf(rv).String())
+	if rv.Kind() != reflect.Ptr {
+		return errorf(fmt.Errorf(errInvalidTarget), "type", fmt.Sprintf("%T", v), "want a pointer")
+	}
+	if rv.IsNil() {
+		return errorf(fmt.Errorf(errInvalidTarget), "type", fmt.Sprintf("%T", v), "nil pointer, pass the address of the variable (&v)")
+	}
+	return nil
+}
+
+// checkDocumentTarget rejects target types a whole document cannot decode into
+// A document is a table, so it fills a struct, a map with string keys or an
+// interface (which receives map[string]any), possibly behind further pointers
+func checkDocumentTarget(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == timeType {
+			return errorf(fmt.Errorf(errInvalidTarget), "got time.Time", "want a pointer to a struct, map or interface")
+		}
+		return nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return errorf(fmt.Errorf(errInvalidTarget), "map key "+t.Key().String(), "want string keys")
+		}
+		return nil
+	case reflect.Interface:
+		return nil
+	default:
+		return errorf(fmt.Errorf(errInvalidTarget), "got "+t.String(), "want a pointer to a struct, map or interface")
+	}
+}
+
+// parse builds the generic map representation of a TOML document
+// Tables become nested maps, arrays become []any
+// On error the returned map holds everything parsed before the failing line
+func (d *Decoder) parse(data []byte) (map[string]any, error) {
+	if d.maxInputSize > 0 && len(data) > d.maxInputSize {
+		return nil, errorf(fmt.Errorf(errInputTooLarge), fmt.Sprintf("max %d bytes", d.maxInputSize))
 	}
 
 	result := make(map[string]any)
 	currentTable := result
-	var currentTablePath []string // Track current table context
+	keys := 0
+	headers := make(map[string]bool) // table headers seen, for duplicate warnings
+	d.warnings = nil
+	var currentTablePath []string          // Track current table context
+	data = bytes.TrimPrefix(data, utf8BOM) // Some Windows editors prefix files with a BOM
 	lines := bytes.Split(data, []byte("\n"))
+	maxDepth := d.depthLimit()
+
+	// For targets with ",raw" fields, each header starts a new section of the
+	// source, which ends at the next header; the lines before the first header
+	// belong to the root table
+	var lineStart []int
+	if d.rawText {
+		lineStart = make([]int, len(lines)+1)
+		for i, line := range lines {
+			lineStart[i+1] = lineStart[i] + len(line) + 1
+		}
+	}
+	sectionTable, sectionLine := result, 0
+	// startSection ends the current section before line and starts one for table
+	startSection := func(table map[string]any, line int) {
+		if lineStart != nil {
+			addRaw(sectionTable, data[lineStart[sectionLine]:min(lineStart[line], len(data))])
+		}
+		sectionTable, sectionLine = table, line
+	}
 
 	// getOrCreateTable ensures a table path exists, creating missing tables
 	// Returns the innermost table for the given path
 	getOrCreateTable := func(path []string) (map[string]any, error) {
 		current := result
-		for _, segment := range path {
+		for i, segment := range path {
 			next, ok := current[segment]
 			if !ok {
 				// Create intermediate table
@@ -49,17 +218,73 @@ func Unmarshal(data []byte, v any) error {
 
 			if m, ok := next.(map[string]any); ok {
 				current = m
+			} else if elems, ok := tableArray(next); ok {
+				// Paths through an array of tables continue in its last element
+				current = elems[len(elems)-1].(map[string]any)
 			} else {
-				return nil, errorf(fn, fmt.Errorf(errInvalidFormat), "type", reflect.TypeOf(m).String(), "value", reflect.ValueOf(m).String())
+				return nil, errorf(fmt.Errorf(errRedefineTable), "key", formatPath(path[:i+1]))
 			}
 		}
 		return current, nil // Return the current map instead of error
 	}
 
-	for lineNum, l := range lines {
-		tokens, err := tokenizeLine(string(l))
+	// parseLine parses the logical line starting at lineNum, which it leaves on
+	// the last physical line it consumed; fatal marks errors that end parsing
+	// even when errors are collected
+	lineNum, fatal := 0, false
+	parseLine := func() error {
+		startLine := lineNum + 1
+		if err := d.checkLineLength(len(lines[lineNum]), lineNum+1); err != nil {
+			return err
+		}
+		if d.noTabIndent && hasTabIndent(lines[lineNum]) {
+			return errorf(fmt.Errorf(errTabIndent), fmt.Sprintf("line %d", lineNum+1))
+		}
+		raw, err := d.foldMultilineStrings(lines, &lineNum, false)
+		if err != nil {
+			return errorf(err, fmt.Sprintf("line %d", startLine))
+		}
+		line := cleanLine(raw, d.commentPrefixes...)
+
+		// Join the continuation lines of a multi-line array into one logical line
+		// Each line's comment is stripped before joining, so elements can be
+		// annotated (8080, # http) without the comment swallowing the rest
+		var arrayIndent byte // indentation character of the first indented continuation line
+		for openArrayDepth(line) > 0 && lineNum+1 < len(lines) {
+			lineNum++
+			if err := d.checkLineLength(len(lines[lineNum]), lineNum+1); err != nil {
+				return err
+			}
+			if d.noTabIndent && hasTabIndent(lines[lineNum]) {
+				return errorf(fmt.Errorf(errTabIndent), fmt.Sprintf("line %d", lineNum+1))
+			}
+			if d.noMixedIndent {
+				if err := checkIndent(lines[lineNum], &arrayIndent); err != nil {
+					return errorf(err, fmt.Sprintf("line %d", lineNum+1))
+				}
+			}
+			raw, err := d.foldMultilineStrings(lines, &lineNum, true)
+			if err != nil {
+				return errorf(err, fmt.Sprintf("line %d", startLine))
+			}
+			line += " " + cleanLine(raw, d.commentPrefixes...)
+		}
+		// The joined logical line is held to the same limit as its physical lines
+		if err := d.checkLineLength(len(line), startLine); err != nil {
+			return err
+		}
+
+		if d.unknownEscapes {
+			escaped := escapeUnknown(line)
+			if escaped != line {
+				d.warn(startLine, WarnUnknownEscape, "unknown escape sequence kept as a literal backslash")
+			}
+			line = escaped
+		}
+
+		tokens, err := tokenizeLine(line, d.indexTables)
 		if err != nil {
-			return errorf(fn, err, append([]string{fmt.Sprintf("line %d", lineNum+1), "tokens"}, func(t []token) []string {
+			return errorf(err, append([]string{fmt.Sprintf("line %d", startLine), "tokens"}, func(t []token) []string {
 				v := make([]string, len(t))
 				for i, tt := range t {
 					v[i] = tt.value
@@ -70,134 +295,372 @@ func Unmarshal(data []byte, v any) error {
 
 		// Skip empty lines
 		if len(tokens) == 0 {
-			continue
+			return nil
+		}
+
+		keys++
+		if d.maxKeys > 0 && keys > d.maxKeys {
+			fatal = true
+			return errorf(fmt.Errorf(errTooManyKeys), fmt.Sprintf("max %d", d.maxKeys), fmt.Sprintf("line %d", startLine))
+		}
+
+		if tokens[0].typ == tokenTableArray {
+			segments := tokens[0].path
+			if len(segments) > maxDepth {
+				return errorf(fmt.Errorf(errNestingDepth), fmt.Sprintf("line %d", startLine))
+			}
+			parent, err := getOrCreateTable(segments[:len(segments)-1])
+			if err != nil {
+				return errorf(err, fmt.Sprintf("line %d", startLine))
+			}
+
+			// Each header appends a new element; a key already holding a table or value cannot become one
+			name := segments[len(segments)-1]
+			table := make(map[string]any)
+			if existing, ok := parent[name]; !ok {
+				parent[name] = []any{table}
+			} else if elems, ok := tableArray(existing); ok {
+				parent[name] = append(elems, table)
+			} else {
+				return errorf(fmt.Errorf(errRedefineTableArray), "key", formatPath(segments), fmt.Sprintf("line %d", startLine))
+			}
+			startSection(table, startLine-1)
+			currentTable = table
+			currentTablePath = segments
+			if d.order != nil {
+				d.order = append(d.order, segments)
+			}
+			return nil
 		}
 
 		if tokens[0].typ == tokenTable {
-			segments := strings.Split(tokens[0].value, ".")
+			segments := tokens[0].path
+			if len(segments) > maxDepth {
+				return errorf(fmt.Errorf(errNestingDepth), fmt.Sprintf("line %d", startLine))
+			}
+			// [name] after [[name]] would otherwise merge into the last element
+			parent, err := getOrCreateTable(segments[:len(segments)-1])
+			if err != nil {
+				return errorf(err, fmt.Sprintf("line %d", startLine))
+			}
+			if _, ok := tableArray(parent[segments[len(segments)-1]]); ok {
+				return errorf(fmt.Errorf(errRedefineTable), "key", formatPath(segments), fmt.Sprintf("line %d", startLine))
+			}
 			table, err := getOrCreateTable(segments)
 			if err != nil {
-				return err
+				return errorf(err, fmt.Sprintf("line %d", startLine))
 			}
+			if name := formatPath(segments); headers[name] {
+				if d.noDuplicateKeys {
+					return errorf(fmt.Errorf(errDuplicateTable), "table", name, fmt.Sprintf("line %d", startLine))
+				}
+				d.warn(startLine, WarnDuplicateTable, "table [%s] is defined again and merged with the earlier one", name)
+			} else {
+				headers[name] = true
+			}
+			startSection(table, startLine-1)
 			currentTable = table
 			currentTablePath = segments
-			continue
+			if d.order != nil {
+				d.order = append(d.order, segments)
+			}
+			return nil
 		}
 
 		// Validate basic key-value structure
-		if len(tokens) < 3 || tokens[0].typ != tokenKey || tokens[1].typ != tokenEquals {
+		isAssign := len(tokens) > 1 && (tokens[1].typ == tokenEquals || tokens[1].typ == tokenAppend)
+		if len(tokens) < 3 || tokens[0].typ != tokenKey || !isAssign {
 			if len(tokens) > 0 && tokens[0].typ != tokenKey {
-				return errorf(fn, fmt.Errorf(errMissingKey))
+				return errorf(fmt.Errorf(errMissingKey), fmt.Sprintf("line %d", startLine))
+			}
+			if isAssign && len(tokens) < 3 {
+				return errorf(fmt.Errorf(errMissingValue), fmt.Sprintf("line %d", startLine))
 			}
-			if len(tokens) > 1 && tokens[1].typ == tokenEquals && len(tokens) < 3 {
-				return errorf(fn, fmt.Errorf(errMissingValue))
+			return errorf(fmt.Errorf(errInvalidFormat), fmt.Sprintf("line %d", startLine))
+		}
+
+		// Bare keys are validated here, quoted keys were split by the tokenizer
+		key, segments := tokens[0].value, tokens[0].path
+		if segments == nil {
+			if !isValidKey(key) {
+				return errorf(fmt.Errorf(errInvalidKey), fmt.Sprintf("line %d", startLine))
+			}
+			if segments, err = getTableSegments(key, d.indexTables); err != nil {
+				return errorf(fmt.Errorf(errInvalidKey), fmt.Sprintf("line %d", startLine), err.Error())
 			}
-			return errorf(fn, fmt.Errorf(errInvalidFormat))
 		}
 
-		key := tokens[0].value
-		if !isValidKey(key) {
-			return errorf(fn, fmt.Errorf(errInvalidKey))
+		// Bare boolean aliases tokenize as words; keep them as the word when allowed
+		// so boolAliasHook converts them for bool targets only
+		if d.boolAliases && tokens[2].typ == tokenKey {
+			if _, ok := boolAliases[strings.ToLower(tokens[2].value)]; ok {
+				tokens[2] = token{typ: tokenString, value: tokens[2].value}
+			}
+		}
+		if d.boolFormat == BoolTitle && tokens[2].typ == tokenKey && (tokens[2].value == "True" || tokens[2].value == "False") {
+			tokens[2] = token{typ: tokenBoolean, value: strings.ToLower(tokens[2].value)}
 		}
 
-		// Parse value based on token type
-		value, err := parseValue(tokens[2])
+		// Parse value based on token type; a bare null is stored as nil when allowed
+		warnings := len(d.warnings)
+		var value any
+		if !d.allowNull || tokens[2].typ != tokenKey || tokens[2].value != "null" {
+			value, err = d.parseValue(tokens[2], maxDepth)
+		}
+		for i := warnings; i < len(d.warnings); i++ {
+			d.warnings[i].Line = startLine
+		}
 		if err != nil {
-			return errorf(fn, err)
+			var overflow *OverflowError
+			if errors.As(err, &overflow) {
+				overflow.Line = startLine
+			}
+			return errorf(err, fmt.Sprintf("line %d", startLine))
 		}
 
-		// Check for unexpected tokens after value
+		// Check for unexpected tokens after value, such as a second string
+		// (key = "a" "b") left by a missing comma or array bracket
 		if len(tokens) > 3 {
-			return errorf(fn, fmt.Errorf(errInvalidFormat), tokens[0].value, tokens[1].value, tokens[2].value)
+			return errorf(fmt.Errorf(errUnexpectedToken), "key", tokens[0].value, "value "+tokens[2].source(), "unexpected "+tokens[3].source(), fmt.Sprintf("line %d", startLine))
 		}
 
-		if strings.Contains(key, ".") {
-			segments, err := getTableSegments(key)
+		targetTable, finalKey := currentTable, segments[len(segments)-1]
+		if len(segments) > 1 {
+			// Create full path by combining current table path with parent path
+			// Concat copies, so the current table path is never shared
+			fullPath := slices.Concat(currentTablePath, segments[:len(segments)-1])
+			if len(fullPath) > maxDepth {
+				return errorf(fmt.Errorf(errNestingDepth), fmt.Sprintf("line %d", startLine))
+			}
+			targetTable, err = getOrCreateTable(fullPath)
 			if err != nil {
-				return errorf(fn, err)
+				return errorf(err, fmt.Sprintf("line %d", startLine))
 			}
+		}
 
-			parentPath := segments[:len(segments)-1]
-			finalKey := segments[len(segments)-1]
+		// A table, whether from a header or dotted keys, is never replaced by a value
+		_, isTable := targetTable[finalKey].(map[string]any)
+		if _, isArray := tableArray(targetTable[finalKey]); isTable || isArray {
+			fullKey := formatPath(slices.Concat(currentTablePath, segments))
+			return errorf(fmt.Errorf(errRedefineValue), "key", fullKey, fmt.Sprintf("line %d", startLine))
+		}
 
-			var targetTable map[string]any
-			if len(parentPath) > 0 {
-				// Create full path by combining current table path with parent path
-				fullPath := append(currentTablePath, parentPath...)
-				targetTable, err = getOrCreateTable(fullPath)
-				if err != nil {
-					return err
+		// key += [...] extends the array already stored under the key
+		if tokens[1].typ == tokenAppend {
+			existing, ok := targetTable[finalKey].([]any)
+			if !ok {
+				return errorf(fmt.Errorf(errInvalidAppend), "key", key, fmt.Sprintf("line %d", startLine))
+			}
+			extra, ok := value.([]any)
+			if !ok {
+				return errorf(fmt.Errorf(errInvalidAppend), "key", key, fmt.Sprintf("line %d", startLine))
+			}
+			value = slices.Concat(existing, extra)
+			// Both parts may be uniform while the joined array is not
+			if d.noMixedArrays {
+				if detail, mixed := mixedArrayElement(value.([]any)); mixed {
+					return errorf(fmt.Errorf(errMixedArray), detail, "key", key, fmt.Sprintf("line %d", startLine))
 				}
-			} else {
-				targetTable = currentTable
 			}
+		} else if _, exists := targetTable[finalKey]; exists {
+			if d.noDuplicateKeys {
+				return errorf(fmt.Errorf(errDuplicateKey), "key", formatPath(slices.Concat(currentTablePath, segments)), fmt.Sprintf("line %d", startLine))
+			}
+			d.warn(startLine, WarnDuplicateKey, "key '%s' is defined again, the last value wins", formatPath(slices.Concat(currentTablePath, segments)))
+		}
 
-			targetTable[finalKey] = value
-		} else {
-			currentTable[key] = value
+		targetTable[finalKey] = value
+		if d.keyLines != nil {
+			d.keyLines[formatPath(slices.Concat(currentTablePath, segments))] = startLine
+		}
+		if d.order != nil {
+			d.order = append(d.order, slices.Concat(currentTablePath, segments))
+		}
+		return nil
+	}
+
+	var errs []error
+	for ; lineNum < len(lines); lineNum++ {
+		err := parseLine()
+		if err == nil {
+			continue
+		}
+		if !d.collectErrors {
+			return result, err
+		}
+		errs = append(errs, err)
+		if fatal {
+			break
 		}
 	}
+	if len(errs) > 0 {
+		return result, &ValidationError{Errors: errs}
+	}
 
-	// Use mapstructure to decode the map into the target variable
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Result:  v,
-		TagName: "toml",
-	})
+	startSection(nil, len(lines))
+	return result, nil
+}
+
+// checkLineLength enforces the decoder's line length limit on a line of the given length
+func (d *Decoder) checkLineLength(length, lineNum int) error {
+	if d.maxLineLength > 0 && length > d.maxLineLength {
+		return errorf(fmt.Errorf(errLineTooLong), fmt.Sprintf("max %d bytes", d.maxLineLength), fmt.Sprintf("line %d", lineNum))
+	}
+	return nil
+}
+
+// decode stores the parsed map, or a value taken from it, into the target using mapstructure
+// Registered hooks are composed in order, followed by the built-in hooks
+// With DisallowUnknownKeys, the keys of struct tables no field decodes from are rejected
+func (d *Decoder) decode(result any, v any) error {
+	var md *mapstructure.Metadata
+	if d.noUnknownKeys {
+		md = &mapstructure.Metadata{}
+	}
+	if err := d.decodeMetadata(result, v, md); err != nil {
+		return err
+	}
+	if md != nil && len(md.Unused) > 0 {
+		return errorf(errors.Join(unknownKeyErrors(md.Unused)...))
+	}
+	return nil
+}
+
+// decodeMetadata is decode recording into md, when not nil, the keys that were
+// decoded and the keys of struct tables that no field decodes from
+func (d *Decoder) decodeMetadata(result any, v any, md *mapstructure.Metadata) error {
+	var hooks []mapstructure.DecodeHookFunc
+	if d.rawText {
+		// First, so no other hook sees the source sections parse stored
+		hooks = append(hooks, d.rawTableHook)
+	}
+	hooks = append(hooks, d.hooks...)
+	hooks = append(hooks, d.builtinHooks(reflect.TypeOf(v))...)
+	if d.allowNull {
+		// mapstructure never passes nil to hooks, so nulls travel as nullValue
+		// up to nullHook, which runs last as its nil result ends the chain
+		result = markNulls(result)
+		hooks = append(hooks, d.nullTableHook, nullHook)
+	}
+	if d.lenient {
+		hooks = lenientHooks(hooks)
+	}
+	config := &mapstructure.DecoderConfig{
+		Result:     v,
+		TagName:    "toml",
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(hooks...),
+		// Field names are the raw tag names, matched trimmed as parseTag reads them
+		MatchName: func(key, name string) bool { return d.matchName(key, strings.TrimSpace(name)) },
+		Metadata:  md,
+	}
+
+	decoder, err := mapstructure.NewDecoder(config)
 	if err != nil {
-		return errorf(fn, err)
+		return errorf(err)
 	}
 
 	err = decoder.Decode(result)
 	if err != nil {
-		return errorf(fn, err)
+		return errorf(err)
 	}
 
 	return nil
 }
 
-// parseValue converts a token into its corresponding Go value
-// based on the token type (string, integer, float, boolean, array)
-func parseValue(t token) (any, error) {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
+// indexArray returns the values of a table keyed by indices as an array, or
+// false when some key is not an index
+func indexArray(table map[string]any) ([]any, bool, error) {
+	count := len(table)
+	if count == 0 {
+		return nil, false, nil
+	}
+	for key := range table {
+		if !isDigits(key) {
+			return nil, false, nil
+		}
+	}
 
+	elems := make([]any, count)
+	for i := range elems {
+		elem, ok := table[strconv.Itoa(i)]
+		if !ok {
+			return nil, false, errorf(fmt.Errorf(errInvalidIndex), fmt.Sprintf("missing index %d of %d", i, count))
+		}
+		elems[i] = elem
+	}
+	return elems, true, nil
+}
+
+// tableArray returns the elements of an array of tables built by [[name]] headers
+// Parsed arrays never hold tables, so an []any whose elements are tables is one
+func tableArray(v any) ([]any, bool) {
+	elems, ok := v.([]any)
+	if !ok || len(elems) == 0 {
+		return nil, false
+	}
+	for _, elem := range elems {
+		if _, ok := elem.(map[string]any); !ok {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+// parseValue converts a token into its corresponding Go value
+// based on the token type (string, integer, float, boolean, datetime, array)
+func (d *Decoder) parseValue(t token, maxDepth int) (any, error) {
 	switch t.typ {
 	case tokenString:
 		return t.value, nil
 	case tokenFloat:
-		if strings.Count(t.value, ".") == 1 {
-			if v, err := strconv.ParseFloat(t.value, 64); err == nil {
-				return v, nil
+		if !isFloatLiteral(t.value) {
+			return nil, errorf(fmt.Errorf(errInvalidFloat), t.value)
+		}
+		if v, err := strconv.ParseFloat(t.value, 64); err == nil {
+			if d.useNumber {
+				return Number(t.value), nil
 			}
-		} else {
-			return nil, errorf(fn, fmt.Errorf(errInvalidFloat), t.value)
+			return v, nil
 		}
 	case tokenInteger:
 		if strings.Count(t.value, ".") == 0 {
-			if v, err := strconv.ParseInt(t.value, 10, 64); err == nil {
-				return v, nil
+			if err := d.checkLeadingZeros(t.value); err != nil {
+				return nil, errorf(err)
+			}
+			v, err := parseInteger(t.value)
+			if err != nil {
+				return nil, errorf(err)
 			}
+			if d.useNumber {
+				return Number(t.value), nil
+			}
+			return v, nil
 		} else {
-			return nil, errorf(fn, fmt.Errorf(errInvalidInteger), t.value)
+			return nil, errorf(fmt.Errorf(errInvalidInteger), t.value)
 		}
 	case tokenBoolean:
 		return t.value == "true", nil
+	case tokenDatetime:
+		return parseDatetime(t.value)
 	case tokenArray:
-		return parseArray(t.value)
+		return d.parseArray(t.value, maxDepth)
 	default:
-		return nil, errorf(fn, fmt.Errorf(errInvalidValue), "default", t.value)
+		return nil, errorf(fmt.Errorf(errInvalidValue), "default", t.value)
 	}
-	return nil, errorf(fn, fmt.Errorf(errInvalidValue), "outside", t.value)
+	return nil, errorf(fmt.Errorf(errInvalidValue), "outside", t.value)
 }
 
 // parseArray processes array contents into a slice of interface values
-// Handles strings, booleans, integers and floats as element types
-func parseArray(s string) ([]any, error) {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
+// Handles strings, booleans, datetimes, integers and floats as element types
+// An empty array gives an empty, non-nil slice, so it decodes into an empty
+// slice of the target's element type rather than a nil one
+func (d *Decoder) parseArray(s string, maxDepth int) ([]any, error) {
+	if maxDepth < 1 {
+		return nil, errorf(fmt.Errorf(errNestingDepth))
+	}
 
-	elements := strings.Split(s, ",")
-	var result []any
+	elements := splitArrayElements(s)
+	result := make([]any, 0, len(elements))
 
 	for _, elem := range elements {
 		elem = strings.TrimSpace(elem)
@@ -206,36 +669,103 @@ func parseArray(s string) ([]any, error) {
 		}
 
 		var value any
-		if strings.HasPrefix(elem, "\"") && strings.HasSuffix(elem, "\"") {
-			value = elem[1 : len(elem)-1]
-			if _, ok := value.(string); !ok {
-				return nil, errorf(fn, fmt.Errorf(errInvalidString))
+		if strings.HasPrefix(elem, "[") && strings.HasSuffix(elem, "]") {
+			v, err := d.parseArray(elem[1:len(elem)-1], maxDepth-1)
+			if err != nil {
+				return nil, errorf(err)
 			}
+			value = v
+		} else if len(elem) >= 2 && strings.HasPrefix(elem, "\"") && strings.HasSuffix(elem, "\"") {
+			v, err := UnescapeString(elem[1 : len(elem)-1])
+			if err != nil {
+				return nil, errorf(err, "array", elem)
+			}
+			value = v
 		} else if elem == "true" || elem == "false" {
 			value = elem == "true"
 			if _, ok := value.(bool); !ok {
-				return nil, errorf(fn, fmt.Errorf(errInvalidBoolean))
+				return nil, errorf(fmt.Errorf(errInvalidBoolean))
+			}
+		} else if d.boolFormat == BoolTitle && (elem == "True" || elem == "False") {
+			value = elem == "True"
+		} else if isDatetime(elem) {
+			v, err := parseDatetime(elem)
+			if err != nil {
+				return nil, errorf(err, "array", elem)
 			}
-		} else if v, err := strconv.ParseInt(elem, 10, 64); err == nil {
 			value = v
-			if _, ok := value.(int64); !ok {
-				return nil, errorf(fn, fmt.Errorf(errInvalidInteger))
+		} else if isIntegerLiteral(elem) {
+			if err := d.checkLeadingZeros(elem); err != nil {
+				return nil, errorf(err, "array", elem)
+			}
+			v, err := parseInteger(elem)
+			if err != nil {
+				return nil, errorf(err, "array", elem)
 			}
-		} else if v, err := strconv.ParseFloat(elem, 64); err == nil {
 			value = v
-			if _, ok := value.(float64); !ok {
-				return nil, errorf(fn, fmt.Errorf(errInvalidFloat))
+			if d.useNumber {
+				value = Number(elem)
+			}
+		} else if isFloatLiteral(elem) {
+			v, err := strconv.ParseFloat(elem, 64)
+			if err != nil {
+				return nil, errorf(fmt.Errorf(errInvalidFloat), "array", elem)
+			}
+			value = v
+			if d.useNumber {
+				value = Number(elem)
 			}
 		} else {
-			return nil, errorf(fn, fmt.Errorf(errInvalidValue), "array", elem)
+			return nil, errorf(fmt.Errorf(errInvalidValue), "array", elem)
 		}
 
 		result = append(result, value)
 	}
 
+	if d.noMixedArrays {
+		if detail, mixed := mixedArrayElement(result); mixed {
+			return nil, errorf(fmt.Errorf(errMixedArray), detail)
+		}
+	}
+
 	return result, nil
 }
 
+// mixedArrayElement describes the first element whose type differs from the
+// first element's, reporting false when all elements share one type
+func mixedArrayElement(elems []any) (string, bool) {
+	for i, elem := range elems {
+		if typeName(elem) != typeName(elems[0]) {
+			return fmt.Sprintf("element %d is %s, element 0 is %s", i, typeName(elem), typeName(elems[0])), true
+		}
+	}
+	return "", false
+}
+
+// typeName names the TOML type of a parsed value for error messages
+func typeName(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "datetime"
+	case []any:
+		return "array"
+	case Number:
+		if v.IsFloat() {
+			return "float"
+		}
+		return "integer"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
 // tokenType represents different kinds of TOML syntax elements
 type tokenType int
 
@@ -247,22 +777,43 @@ const (
 	tokenFloat
 	tokenInteger
 	tokenBoolean
+	tokenDatetime
 	tokenArray
 	tokenTable
+	tokenTableArray // [[name]] header adding an element to an array of tables
+	tokenAppend     // += extending an existing array (non-standard)
 )
 
 // token represents a parsed TOML syntax element with its type and value
 type token struct {
 	typ   tokenType
 	value string
+	path  []string // unquoted segments of a table name or quoted key
+}
+
+// source writes a token back in the form it had in the input, for error messages
+func (t token) source() string {
+	switch t.typ {
+	case tokenString:
+		return quoteString(t.value)
+	case tokenArray:
+		return "[" + t.value + "]"
+	case tokenEquals:
+		return "="
+	case tokenAppend:
+		return "+="
+	case tokenTable:
+		return "[" + formatPath(t.path) + "]"
+	case tokenTableArray:
+		return "[[" + formatPath(t.path) + "]]"
+	}
+	return t.value
 }
 
 // tokenizeLine breaks a TOML line into tokens for parsing
 // It handles key-value pairs, table headers, and different value types
-func tokenizeLine(line string) ([]token, error) {
-	pc, _, _, _ := runtime.Caller(0)
-	fn := runtime.FuncForPC(pc).Name()
-
+// With indices set, keys and table names may hold index segments (items.0)
+func tokenizeLine(line string, indices bool) ([]token, error) {
 	var tokens []token
 	var buf strings.Builder
 	inString := false
@@ -276,15 +827,34 @@ func tokenizeLine(line string) ([]token, error) {
 		return nil, nil
 	}
 
-	// Check for table header
+	// Check for array of tables header, parsed as a table header inside the outer brackets
 	line = strings.TrimSpace(line)
-	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-		tableName := strings.TrimSpace(line[1 : len(line)-1])
-		segments, err := getTableSegments(tableName)
+	if strings.HasPrefix(line, "[[") {
+		if !strings.HasSuffix(line, "]]") {
+			return nil, errorf(fmt.Errorf(errInvalidTableHeader), "unclosed array of tables header", "table header", line)
+		}
+		tableName, err := parseTableHeader(line[1 : len(line)-1])
+		if err != nil {
+			return nil, errorf(err, "table header", line)
+		}
+		segments, err := getTableSegments(tableName, indices)
 		if err != nil {
-			return nil, errorf(fn, err, "table name", tableName)
+			return nil, errorf(err, "table name", tableName)
 		}
-		return []token{{typ: tokenTable, value: strings.Join(segments, ".")}}, nil
+		return []token{{typ: tokenTableArray, value: tableName, path: segments}}, nil
+	}
+
+	// Check for table header
+	if strings.HasPrefix(line, "[") {
+		tableName, err := parseTableHeader(line)
+		if err != nil {
+			return nil, errorf(err, "table header", line)
+		}
+		segments, err := getTableSegments(tableName, indices)
+		if err != nil {
+			return nil, errorf(err, "table name", tableName)
+		}
+		return []token{{typ: tokenTable, value: tableName, path: segments}}, nil
 	}
 
 	for i := 0; i < len(line); {
@@ -296,8 +866,20 @@ func tokenizeLine(line string) ([]token, error) {
 			continue
 		}
 
+		// Handle append operator
+		if r == '+' && !inValue && !inString && i+1 < len(line) && line[i+1] == '=' {
+			if buf.Len() > 0 {
+				tokens = append(tokens, token{typ: tokenKey, value: buf.String()})
+				buf.Reset()
+			}
+			tokens = append(tokens, token{typ: tokenAppend})
+			inValue = true
+			i += 2
+			continue
+		}
+
 		// Handle equals sign
-		if r == '=' {
+		if r == '=' && !inString {
 			if buf.Len() > 0 {
 				tokens = append(tokens, token{typ: tokenKey, value: buf.String()})
 				buf.Reset()
@@ -313,8 +895,18 @@ func tokenizeLine(line string) ([]token, error) {
 			inArray = true
 			arrayStart = i
 			bracketCount := 1
+			quoted := false
 			for i++; i < len(line); i++ {
-				if line[i] == '[' {
+				if quoted {
+					// Brackets inside strings do not count
+					if line[i] == '\\' {
+						i++
+					} else if line[i] == '"' {
+						quoted = false
+					}
+				} else if line[i] == '"' {
+					quoted = true
+				} else if line[i] == '[' {
 					bracketCount++
 				} else if line[i] == ']' {
 					bracketCount--
@@ -329,12 +921,27 @@ func tokenizeLine(line string) ([]token, error) {
 				}
 			}
 			if bracketCount != 0 {
-				return nil, errorf(fn, fmt.Errorf(errUnterminatedArray))
+				return nil, errorf(fmt.Errorf(errUnterminatedArray))
+			}
+			continue
+		}
+
+		// A quote before the assignment starts a quoted key ("" = 1, site."a.b" = 2)
+		// The whole key, including bare segments before it, is split like a table name
+		if r == '"' && !inString && !inValue {
+			end := keyEnd(line, i)
+			rawKey := buf.String() + strings.TrimRightFunc(line[i:end], unicode.IsSpace)
+			segments, err := getTableSegments(rawKey, indices)
+			if err != nil {
+				return nil, errorf(fmt.Errorf(errInvalidKey), "key", rawKey, err.Error())
 			}
+			tokens = append(tokens, token{typ: tokenKey, value: rawKey, path: segments})
+			buf.Reset()
+			i = end
 			continue
 		}
 
-		// String handling
+		// String handling, escaped quotes are consumed by the escape handling below
 		if r == '"' {
 			if !inString {
 				inString = true
@@ -343,13 +950,6 @@ func tokenizeLine(line string) ([]token, error) {
 				continue
 			}
 
-			// Check if this quote is escaped
-			if i > 0 && line[i-1] == '\\' {
-				buf.WriteRune(r)
-				i++
-				continue
-			}
-
 			// End of string
 			tokens = append(tokens, token{typ: tokenString, value: buf.String()})
 			buf.Reset()
@@ -360,27 +960,19 @@ func tokenizeLine(line string) ([]token, error) {
 
 		if inString {
 			// Handle escape sequences
-			if r == '\\' && i+1 < len(line) {
+			if r == '\\' {
 				if i+1 >= len(line) {
-					return nil, fmt.Errorf(errUnterminatedEscape)
+					return nil, errorf(fmt.Errorf(errUnterminatedEscape))
 				}
-				next := rune(line[i+1])
-				switch next {
-				case 't':
-					buf.WriteRune('\t')
-				case 'n':
-					buf.WriteRune('\n')
-				case 'r':
-					buf.WriteRune('\r')
-				case '\\':
-					buf.WriteRune('\\')
-				default:
-					return nil, errorf(fn, fmt.Errorf(errInvalidEscape))
+				unescaped, ok := escapeSequences[line[i+1]]
+				if !ok {
+					return nil, errorf(invalidEscapeError(line[i+1]))
 				}
+				buf.WriteByte(unescaped)
 				i += 2
 				continue
 			}
-			buf.WriteRune(r)
+			buf.WriteByte(line[i]) // bytes, so multi-byte characters pass through intact
 			i++
 			continue
 		}
@@ -399,40 +991,68 @@ func tokenizeLine(line string) ([]token, error) {
 				continue
 			}
 
+			// Datetime (will be parsed later)
+			if isDatetime(line[i:]) {
+				start := i
+				for i < len(line) && isDatetimeChar(line[i]) {
+					i++
+				}
+				tokens = append(tokens, token{typ: tokenDatetime, value: line[start:i]})
+				continue
+			}
+
 			// Number (will be parsed later)
 			if unicode.IsDigit(r) || r == '-' || r == '+' {
 				start := i
 				dotCount := 0
 				hasDigit := false
+				hasExponent := false
 
 				// Handle leading sign
 				if r == '-' || r == '+' {
 					i++
 				}
 
+				// Prefixed integers (0x, 0o, 0b) are validated by parseInteger
+				if hasIntegerPrefix(line[i:]) {
+					i += 2
+					for i < len(line) && (isAlpha(rune(line[i])) || isNumeric(rune(line[i]))) {
+						i++
+					}
+					tokens = append(tokens, token{typ: tokenInteger, value: line[start:i]})
+					continue
+				}
+
 				// Scan the rest
 				for i < len(line) {
 					c := line[i]
 					if unicode.IsDigit(rune(c)) {
 						hasDigit = true
 						i++
-					} else if c == '.' {
+					} else if c == '.' && !hasExponent {
 						dotCount++
 						if dotCount > 1 {
-							return nil, errorf(fn, fmt.Errorf(errInvalidFloat))
+							return nil, errorf(fmt.Errorf(errInvalidFloat))
 						}
 						i++
+					} else if (c == 'e' || c == 'E') && hasDigit && !hasExponent {
+						// Exponent, with an optional sign (1e+10, 2.5E-3)
+						hasExponent = true
+						i++
+						if i < len(line) && (line[i] == '+' || line[i] == '-') {
+							i++
+						}
 					} else {
 						break
 					}
 				}
 
 				if !hasDigit {
-					return nil, errorf(fn, fmt.Errorf(errInvalidValue))
+					return nil, errorf(fmt.Errorf(errInvalidValue))
 				}
 
 				value := line[start:i]
-				if dotCount == 0 {
+				if dotCount == 0 && !hasExponent {
 					tokens = append(tokens, token{typ: tokenInteger, value: value})
 				} else {
 					tokens = append(tokens, token{typ: tokenFloat, value: value})
@@ -448,13 +1068,13 @@ func tokenizeLine(line string) ([]token, error) {
 
 	// Check for unterminated array
 	if inArray {
-		return nil, errorf(fn, fmt.Errorf(errUnterminatedArray))
+		return nil, errorf(fmt.Errorf(errUnterminatedArray))
 	}
 
 	// Add final token if buffer not empty
 	if buf.Len() > 0 {
 		if inString {
-			return nil, errorf(fn, fmt.Errorf(errUnterminatedString))
+			return nil, errorf(fmt.Errorf(errUnterminatedString))
 		}
 		tokens = append(tokens, token{typ: tokenKey, value: buf.String()})
 	}
@@ -462,45 +1082,519 @@ func tokenizeLine(line string) ([]token, error) {
 	return tokens, nil
 }
 
+// keyEnd returns the index of the assignment operator (= or +=) that ends the key
+// starting at start, skipping quoted segments, or len(line) if there is none
+func keyEnd(line string, start int) int {
+	inString := false
+	for i := start; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '=':
+			return i
+		case c == '+' && i+1 < len(line) && line[i+1] == '=':
+			return i
+		}
+	}
+	return len(line)
+}
+
+// parseInteger converts a decimal, hexadecimal (0x), octal (0o) or binary (0b)
+// literal with an optional sign into an int64
+// All bases share the same range check against the int64 boundaries
+func parseInteger(s string) (int64, error) {
+	digits := s
+	negative := false
+	if digits != "" && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	base := 10
+	if hasIntegerPrefix(digits) {
+		switch digits[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		digits = digits[2:]
+	}
+
+	// ParseUint accepts neither a second sign nor an empty digit string
+	if digits == "" || digits[0] == '-' || digits[0] == '+' {
+		return 0, errorf(fmt.Errorf(errInvalidInteger), s)
+	}
+
+	u, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, errorf(&OverflowError{Literal: s, BitSize: 64})
+		}
+		return 0, errorf(fmt.Errorf(errInvalidInteger), s)
+	}
+
+	if negative {
+		if u > -math.MinInt64 {
+			return 0, errorf(&OverflowError{Literal: s, BitSize: 64})
+		}
+		return int64(-u), nil
+	}
+	if u > math.MaxInt64 {
+		return 0, errorf(&OverflowError{Literal: s, BitSize: 64})
+	}
+	return int64(u), nil
+}
+
+// hasIntegerPrefix checks if a literal starts with a 0x, 0o or 0b base prefix
+func hasIntegerPrefix(s string) bool {
+	return len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o' || s[1] == 'b')
+}
+
+// checkLeadingZeros rejects a decimal integer literal with a leading zero (042)
+// when DisallowLeadingZeros is set. A lone 0 and prefixed integers (0x2A) pass.
+// Otherwise such a literal is only recorded as a warning.
+func (d *Decoder) checkLeadingZeros(s string) error {
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) < 2 || digits[0] != '0' || hasIntegerPrefix(digits) {
+		return nil
+	}
+	if d.noLeadingZeros {
+		return errorf(fmt.Errorf(errInvalidInteger), "leading zero", s)
+	}
+	d.warn(0, WarnLeadingZero, "leading zero in %s, read as a decimal integer", s)
+	return nil
+}
+
+// isIntegerLiteral checks if an array element is meant as an integer:
+// an optional sign followed by a digit, without a decimal point or exponent
+func isIntegerLiteral(s string) bool {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || digits == "" || !isNumeric(rune(digits[0])) {
+		return false
+	}
+	return hasIntegerPrefix(digits) || !strings.ContainsAny(digits, ".eE")
+}
+
+// isFloatLiteral checks if a value is a decimal float the parser accepts:
+// an optional sign, digits, then a decimal point and more digits (+19.99, -0.5),
+// an exponent with an optional sign (1e+10, 2.5E-3), or both
+// Both sides of the point need a digit, so 5. and .5 are rejected, values and
+// array elements alike, instead of whatever strconv.ParseFloat would allow
+func isFloatLiteral(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exponent := s[i+1:]
+		if exponent != "" && (exponent[0] == '+' || exponent[0] == '-') {
+			exponent = exponent[1:]
+		}
+		if !isDigits(exponent) {
+			return false
+		}
+		whole, frac, ok := strings.Cut(s[:i], ".")
+		return isDigits(whole) && (!ok || isDigits(frac))
+	}
+	whole, frac, ok := strings.Cut(s, ".")
+	return ok && isDigits(whole) && isDigits(frac)
+}
+
+// isDigits reports whether s is a non-empty run of decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !isNumeric(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDatetime checks if a value starts with a full date (YYYY-MM-DD)
+// Used to tell datetimes apart from numbers, which share a leading digit
+func isDatetime(s string) bool {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	for _, i := range []int{0, 1, 2, 3, 5, 6, 8, 9} {
+		if !isNumeric(rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDatetimeChar checks if a character can appear in an RFC 3339 datetime
+func isDatetimeChar(c byte) bool {
+	switch c {
+	case '-', '+', ':', '.', 'T', 't', 'Z', 'z':
+		return true
+	default:
+		return isNumeric(rune(c))
+	}
+}
+
+// parseDatetime converts an RFC 3339 offset date-time into a time.Time
+// TOML allows lowercase 't' and 'z', which time.Parse does not
+func parseDatetime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+	if err != nil {
+		return time.Time{}, errorf(fmt.Errorf(errInvalidDatetime), s)
+	}
+	return t, nil
+}
+
+// checkIndent reports an array continuation line whose indentation mixes tabs and
+// spaces, or uses a different character than the earlier lines of the array,
+// recorded in style. Unindented and blank lines are not checked.
+func checkIndent(line []byte, style *byte) error {
+	indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+	if len(indent) == 0 || len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+
+	tabs := bytes.Count(indent, []byte{'\t'})
+	if tabs > 0 && tabs < len(indent) {
+		return errorf(fmt.Errorf(errMixedIndent), "tabs and spaces")
+	}
+	current := indent[0]
+	if *style == 0 {
+		*style = current
+		return nil
+	}
+	if current != *style {
+		return errorf(fmt.Errorf(errMixedIndent), "indent "+indentName(current), "array indent "+indentName(*style))
+	}
+	return nil
+}
+
+// indentName names an indentation character for error messages
+func indentName(c byte) string {
+	if c == '\t' {
+		return "tabs"
+	}
+	return "spaces"
+}
+
+// hasTabIndent checks if a raw line's leading whitespace contains a tab
+// Whitespace-only lines are not indented and never count
+func hasTabIndent(line []byte) bool {
+	tab := false
+	for _, c := range line {
+		switch c {
+		case '\t':
+			tab = true
+		case ' ', '\r', '\n':
+		default:
+			return tab
+		}
+	}
+	return false
+}
+
+// openArrayDepth returns how many arrays in a key-value line are still open
+// at the end of the line, ignoring brackets inside strings and table headers
+func openArrayDepth(line string) int {
+	depth := 0
+	inValue, inString := false, false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case !inValue:
+			// An = inside a quoted key ("k = v" = [) does not start the value
+			inValue = c == '='
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// foldMultilineStrings returns the physical line at *lineNum with every multi-line
+// basic string ("""...""") in value position rewritten as a one-line basic string,
+// so the rest of the parser only sees "..." strings. A string left open consumes
+// the following lines, advancing *lineNum past them. inValue is set for array
+// continuation lines, which have no = of their own.
+func (d *Decoder) foldMultilineStrings(lines [][]byte, lineNum *int, inValue bool) (string, error) {
+	line := string(lines[*lineNum])
+	if !strings.Contains(line, `"""`) {
+		return line, nil
+	}
+
+	var out strings.Builder
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString:
+			if c == '\\' && i+1 < len(line) {
+				out.WriteByte(c)
+				i++
+				c = line[i]
+			} else if c == '"' {
+				inString = false
+			}
+			out.WriteByte(c)
+		case isCommentStart(line[i:], d.commentPrefixes):
+			out.WriteString(line[i:]) // comment, removed by cleanLine
+			return out.String(), nil
+		case c == '=':
+			inValue = true
+			out.WriteByte(c)
+		case inValue && strings.HasPrefix(line[i:], `"""`):
+			// Find the closing delimiter, reading further lines as needed
+			start := i + 3
+			end := -1
+			for j := start; end < 0; j++ {
+				for j >= len(line) {
+					if *lineNum+1 >= len(lines) {
+						return "", errorf(fmt.Errorf(errUnterminatedString), "multi-line string")
+					}
+					*lineNum++
+					if err := d.checkLineLength(len(lines[*lineNum]), *lineNum+1); err != nil {
+						return "", err
+					}
+					line += "\n" + string(lines[*lineNum])
+				}
+				if line[j] == '\\' {
+					j++
+				} else if strings.HasPrefix(line[j:], `"""`) {
+					end = j
+				}
+			}
+
+			// Up to two quotes right before the delimiter belong to the content (""""" ends in "")
+			close := end + 3
+			for extra := 0; extra < 2 && close < len(line) && line[close] == '"'; extra++ {
+				close++
+			}
+			out.WriteByte('"')
+			out.WriteString(multilineBody(line[start : close-3]))
+			out.WriteByte('"')
+			i = close - 1
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// multilineBody converts the content of a multi-line basic string into the body
+// of an equivalent one-line basic string, applying the TOML trimming rules:
+// a newline right after the opening delimiter is dropped, and a backslash ending
+// a line removes itself with all whitespace and newlines up to the next
+// non-whitespace character. Other newlines become \n escapes, bare quotes are
+// escaped, and existing escapes are kept for UnescapeString.
+func multilineBody(content string) string {
+	if strings.HasPrefix(content, "\r\n") {
+		content = content[2:]
+	} else {
+		content = strings.TrimPrefix(content, "\n")
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\\':
+			// Line-ending backslash: only spaces or tabs may follow it on the line
+			j := i + 1
+			for j < len(content) && (content[j] == ' ' || content[j] == '\t' || content[j] == '\r') {
+				j++
+			}
+			if j < len(content) && content[j] == '\n' {
+				for j < len(content) && strings.IndexByte(" \t\r\n", content[j]) >= 0 {
+					j++
+				}
+				i = j - 1
+				continue
+			}
+			buf.WriteByte(c)
+			if i+1 < len(content) {
+				i++
+				buf.WriteByte(content[i])
+			}
+		case c == '"':
+			buf.WriteString(`\"`)
+		case c == '\r' && i+1 < len(content) && content[i+1] == '\n':
+			// CRLF line endings read as a single newline
+		case c == '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
 // cleanLine removes comments and trims whitespace from a TOML line
 // Preserves text within strings, including comment characters
-func cleanLine(line string) string {
+// A comment starts at # or at any of the extra prefixes set by AllowCommentPrefixes
+func cleanLine(line string, prefixes ...string) string {
+	// Lines without a comment character need no scanning
+	if strings.IndexByte(line, '#') < 0 && !containsAny(line, prefixes) {
+		return strings.TrimSpace(line)
+	}
+
 	var buf strings.Builder
 	inString := false
 
 	for i := 0; i < len(line); i++ {
-		c := rune(line[i])
+		c := line[i]
+
+		// An escape inside a string keeps the next byte, so \" never ends the
+		// string and \\" does
+		if inString && c == '\\' && i+1 < len(line) {
+			buf.WriteByte(c)
+			buf.WriteByte(line[i+1])
+			i++
+			continue
+		}
 
 		// Handle string content
 		if c == '"' {
-			if i > 0 && line[i-1] == '\\' {
-				buf.WriteRune(c)
-				continue
-			}
 			inString = !inString
-			buf.WriteRune(c)
+			buf.WriteByte(c)
 			continue
 		}
 
 		// Handle comment outside string
-		if c == '#' && !inString {
+		if !inString && isCommentStart(line[i:], prefixes) {
 			break
 		}
 
-		buf.WriteRune(c)
+		buf.WriteByte(c)
 	}
 
 	return strings.TrimSpace(buf.String())
 }
 
+// isCommentStart reports whether s begins with # or one of the extra comment prefixes
+func isCommentStart(s string, prefixes []string) bool {
+	if strings.HasPrefix(s, "#") {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether s contains any of substrs
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTableHeader extracts the table name from a header line
+// The header must be exactly one balanced bracket pair with nothing after the closing bracket
+func parseTableHeader(line string) (string, error) {
+	end := -1
+	inString := false
+	for i := 1; i < len(line) && end < 0; i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[':
+			return "", errorf(fmt.Errorf(errInvalidTableHeader), "unbalanced brackets")
+		case c == ']':
+			end = i
+		}
+	}
+	if end < 0 {
+		return "", errorf(fmt.Errorf(errInvalidTableHeader), "missing closing bracket")
+	}
+
+	if rest := strings.TrimSpace(line[end+1:]); rest != "" {
+		return "", errorf(fmt.Errorf(errInvalidTableHeader), "unexpected "+rest+" after closing bracket")
+	}
+	return strings.TrimSpace(line[1:end]), nil
+}
+
 // getTableSegments splits a table name into its dot-separated segments
-// Validates each segment as a valid TOML key
-func getTableSegments(tableName string) ([]string, error) {
-	segments := strings.Split(tableName, ".")
-	for _, segment := range segments {
-		if strings.Contains(segment, " ") || !isValidKey(segment) {
+// Bare segments must be valid TOML keys, or, with indices set, digits after
+// the first segment (items.0); quoted segments may contain any character,
+// including dots and spaces, and support the basic escapes
+func getTableSegments(tableName string, indices bool) ([]string, error) {
+	var segments []string
+	for i := 0; ; i++ {
+		if i < len(tableName) && tableName[i] == '"' {
+			segment, n, err := readQuotedSegment(tableName[i:])
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			i += n
+		} else {
+			end := i
+			for end < len(tableName) && tableName[end] != '.' && tableName[end] != '"' {
+				end++
+			}
+			segment := tableName[i:end]
+			// Index segments are allowed after the first segment, as in items.0
+			if strings.Contains(segment, " ") || !isValidKey(segment) && (!indices || len(segments) == 0 || !isDigits(segment)) {
+				return nil, fmt.Errorf(errInvalidTableName)
+			}
+			segments = append(segments, segment)
+			i = end
+		}
+
+		if i == len(tableName) {
+			return segments, nil
+		}
+		if tableName[i] != '.' {
 			return nil, fmt.Errorf(errInvalidTableName)
 		}
 	}
-	return segments, nil
+}
+
+// readQuotedSegment reads a double-quoted key segment at the start of s
+// Returns the unescaped segment and the number of bytes consumed, including quotes
+func readQuotedSegment(s string) (string, int, error) {
+	var buf strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return buf.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf(errUnterminatedEscape)
+			}
+			i++
+			unescaped, ok := escapeSequences[s[i]]
+			if !ok {
+				return "", 0, invalidEscapeError(s[i])
+			}
+			buf.WriteByte(unescaped)
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf(errUnterminatedString)
 }
@@ -1,9 +1,11 @@
 package tinytoml
 
 import (
+	"math"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestUnmarshal_SingleValue(t *testing.T) {
@@ -35,6 +37,20 @@ func TestUnmarshal_SingleValue(t *testing.T) {
 			wantErr:  false,
 			errormsg: "",
 		},
+		{
+			name:     "leading byte-order mark",
+			input:    "\ufeffname = \"value\"\ncount = 1",
+			want:     map[string]any{"name": "value", "count": int64(1)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "byte-order mark only at start",
+			input:    "name = \"value\"\n\ufeffcount = 1",
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidKey,
+		},
 		{
 			name:     "boolean value",
 			input:    "active = true",
@@ -65,17 +81,73 @@ func TestUnmarshal_SingleValue(t *testing.T) {
 		},
 		{
 			name:     "bad float",
-			input:    `bad_float = 12.5e9`,
+			input:    `bad_float = 12.5e`,
 			want:     map[string]any{"name": "value"},
 			wantErr:  true,
 			errormsg: "",
 		},
+		{
+			name:     "exponent float value",
+			input:    `big = 12.5e9`,
+			want:     map[string]any{"big": 12.5e9},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "float without fraction digits",
+			input:    `price = 5.`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidFloat,
+		},
+		{
+			name:     "float without whole digits",
+			input:    `price = +.5`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidFloat,
+		},
+		{
+			name:     "array float without fraction digits",
+			input:    `prices = [1.5, 2.]`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidValue,
+		},
+		{
+			name:     "array word float",
+			input:    `prices = [1.5, inf]`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidValue,
+		},
 		{
 			name:     "bad integer",
 			input:    `bad_int = -129 9`,
 			want:     map[string]any{"name": "value"},
 			wantErr:  true,
-			errormsg: errInvalidFormat,
+			errormsg: errUnexpectedToken + " [key, bad_int, value -129, unexpected 9, line 1]",
+		},
+		{
+			name:     "adjacent strings",
+			input:    "name = \"web\"\nkey = \"a\" \"b\"",
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnexpectedToken + ` [key, key, value "a", unexpected "b", line 2]`,
+		},
+		{
+			name:     "adjacent arrays",
+			input:    "ports = [80] [443]",
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnexpectedToken + " [key, ports, value [80], unexpected [443], line 1]",
+		},
+		{
+			name:     "values without brackets",
+			input:    `tags = "a", "b"`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnexpectedToken + ` [key, tags, value "a", unexpected`,
 		},
 		{
 			name:     "invalid format",
@@ -142,6 +214,111 @@ func TestUnmarshal_SingleValue(t *testing.T) {
 			wantErr:  true,
 			errormsg: errInvalidEscape,
 		},
+		{
+			name:     "empty quoted key",
+			input:    `"" = "value"`,
+			want:     map[string]any{"": "value"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "quoted key with spaces and dots",
+			input:    `"my key.name" = 1`,
+			want:     map[string]any{"my key.name": int64(1)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "dotted key with quoted segment",
+			input:    `site."google.com".rank = 1`,
+			want:     map[string]any{"site": map[string]any{"google.com": map[string]any{"rank": int64(1)}}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "quoted key with escape and append",
+			input:    "\"a\\\"b\" = [1]\n\"a\\\"b\" += [2]",
+			want:     map[string]any{`a"b`: []any{int64(1), int64(2)}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "error: empty bare key",
+			input:    `= "value"`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errMissingKey,
+		},
+		{
+			name:     "error: empty bare segment after quoted key",
+			input:    `"a".. = 1`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidKey,
+		},
+		{
+			name:     "error: unterminated quoted key",
+			input:    `"key = 1`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidKey,
+		},
+		{
+			name:     "hash inside string",
+			input:    `url = "http://x#y"`,
+			want:     map[string]any{"url": "http://x#y"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "hash inside string with trailing comment",
+			input:    `url = "http://x#y" # mirror`,
+			want:     map[string]any{"url": "http://x#y"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "escaped quotes around hash",
+			input:    `msg = "say \"#1\" # not a comment" # comment`,
+			want:     map[string]any{"msg": `say "#1" # not a comment`},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "escaped backslash before closing quote",
+			input:    `path = "C:\\" # "comment"`,
+			want:     map[string]any{"path": `C:\`},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "equals and hash inside string",
+			input:    `query = "a=1#b=2"`,
+			want:     map[string]any{"query": "a=1#b=2"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "multi-byte characters with comment",
+			input:    `name = "café #1" # ✓`,
+			want:     map[string]any{"name": "café #1"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "error: escaped closing quote",
+			input:    `path = "C:\" # comment`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnterminatedString,
+		},
+		{
+			name:     "string array with commas",
+			input:    `names = ["a, b", "c"]`,
+			want:     map[string]any{"names": []any{"a, b", "c"}},
+			wantErr:  false,
+			errormsg: "",
+		},
 		{
 			name:     "valid string array",
 			input:    `files = ["readme.txt", "operation.log", "data1234.txt"]`,
@@ -172,6 +349,20 @@ name = "value"`,
 			wantErr:  false,
 			errormsg: "",
 		},
+		{
+			name:     "nested arrays",
+			input:    `matrix = [[1, 2], [3, [4.5, "x]"]], []]`,
+			want:     map[string]any{"matrix": []any{[]any{int64(1), int64(2)}, []any{int64(3), []any{4.5, "x]"}}, []any{}}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "error: invalid element in nested array",
+			input:    `matrix = [[1, 2], [3, four]]`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidValue,
+		},
 		{
 			name:     "error: invalid array syntax",
 			input:    "invalid = [1, 2, 3",
@@ -179,6 +370,200 @@ name = "value"`,
 			wantErr:  true,
 			errormsg: errUnterminatedArray,
 		},
+		{
+			name: "multi-line array",
+			input: `ports = [
+    8080,
+    8081,
+]
+name = "value"`,
+			want:     map[string]any{"ports": []any{int64(8080), int64(8081)}, "name": "value"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "multi-line array with bracket in string",
+			input: `hosts = [
+    "a]b",
+    "c"
+]`,
+			want:     map[string]any{"hosts": []any{"a]b", "c"}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "multi-line array under quoted key with equals sign",
+			input: `"k = v" = [
+    1,
+    2,
+]`,
+			want:     map[string]any{"k = v": []any{int64(1), int64(2)}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "multi-line array with comments",
+			input: `ports = [ # listeners
+    8080, # http
+    # 8081, disabled
+    8443 # https
+] # end
+tags = [
+    "#one", # hash in string
+    "two\"#", # escaped quote before hash
+]`,
+			want: map[string]any{
+				"ports": []any{int64(8080), int64(8443)},
+				"tags":  []any{"#one", "two\"#"},
+			},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "error: bracket only in comment of multi-line array",
+			input: `ports = [
+    8080 # ]
+`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnterminatedArray,
+		},
+		{
+			name:     "no space after equals: string",
+			input:    "k=\"no-spaces\"",
+			want:     map[string]any{"k": "no-spaces"},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: integer",
+			input:    "k=42",
+			want:     map[string]any{"k": int64(42)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: negative integer",
+			input:    "k=-42",
+			want:     map[string]any{"k": int64(-42)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: hexadecimal integer",
+			input:    "k=0x2A",
+			want:     map[string]any{"k": int64(42)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: float",
+			input:    "k=1.5",
+			want:     map[string]any{"k": 1.5},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: boolean true",
+			input:    "k=true",
+			want:     map[string]any{"k": true},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: boolean false",
+			input:    "k=false",
+			want:     map[string]any{"k": false},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: array",
+			input:    "k=[1,2]",
+			want:     map[string]any{"k": []any{int64(1), int64(2)}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: empty array",
+			input:    "k=[]",
+			want:     map[string]any{"k": []any{}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: datetime",
+			input:    "k=2023-01-01T00:00:00Z",
+			want:     map[string]any{"k": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "no space after equals: dotted key",
+			input:    "a.b=1",
+			want:     map[string]any{"a": map[string]any{"b": int64(1)}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "error: no space after equals with trailing text",
+			input:    "k=truex",
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnexpectedToken,
+		},
+		{
+			name: "append to array",
+			input: `tags = ["a"]
+tags += ["b", "c"]
+tags+=[]`,
+			want:     map[string]any{"tags": []any{"a", "b", "c"}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name: "append to dotted and multi-line array",
+			input: `server.ports = [80]
+server.ports += [
+    443,
+    8443,
+]`,
+			want:     map[string]any{"server": map[string]any{"ports": []any{int64(80), int64(443), int64(8443)}}},
+			wantErr:  false,
+			errormsg: "",
+		},
+		{
+			name:     "error: append to undefined key",
+			input:    `tags += ["a"]`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidAppend,
+		},
+		{
+			name: "error: append to non-array",
+			input: `count = 1
+count += [2]`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidAppend,
+		},
+		{
+			name: "error: append non-array value",
+			input: `tags = ["a"]
+tags += "b"`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errInvalidAppend,
+		},
+		{
+			name: "error: unterminated multi-line array",
+			input: `ports = [
+    8080,
+    8081`,
+			want:     nil,
+			wantErr:  true,
+			errormsg: errUnterminatedArray,
+		},
 	}
 
 	for _, tt := range tests {
@@ -317,61 +702,132 @@ name = "web2"`,
 			input: `[server
 name = "web"`,
 			wantErr:  true,
-			errormsg: errInvalidFormat,
+			errormsg: errInvalidTableHeader + " [missing closing bracket]",
 		},
 		{
-			name: "comment-only table line",
-			input: `[server] # comment
-# another comment
+			name: "text after table header",
+			input: `[a]b[c]
+name = "web"`,
+			wantErr:  true,
+			errormsg: errInvalidTableHeader,
+		},
+		{
+			name:     "assignment to table header",
+			input:    `[a] = 1`,
+			wantErr:  true,
+			errormsg: errInvalidTableHeader,
+		},
+		{
+			name: "nested brackets in table header",
+			input: `[a[b]]
+name = "web"`,
+			wantErr:  true,
+			errormsg: errInvalidTableHeader,
+		},
+		{
+			name: "quoted table name with dot",
+			input: `["a.b"]
 name = "web"`,
 			expected: map[string]any{
-				"server": map[string]any{
+				"a.b": map[string]any{
 					"name": "web",
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "quoted strings in comments",
-			input: `[server] # don't "parse" this
-name = "web" # or "this" one`,
+			name: "quoted segments with spaces and brackets",
+			input: `[server."my key"."x]y"]
+name = "web"`,
 			expected: map[string]any{
 				"server": map[string]any{
-					"name": "web",
+					"my key": map[string]any{
+						"x]y": map[string]any{
+							"name": "web",
+						},
+					},
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "tables with empty lines and comments",
-			input: `
-# header comment
-[server]
-
-# mid comment
-name = "web"
-
-# another comment
-
-[database]
-host = "localhost"
-`,
+			name: "quoted segment with escaped quote",
+			input: `["say \"hi\""]
+name = "web"`,
 			expected: map[string]any{
-				"server": map[string]any{
+				"say \"hi\"": map[string]any{
 					"name": "web",
 				},
-				"database": map[string]any{
-					"host": "localhost",
-				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "basic dotted table",
-			input: `[server.network]
-ip = "1.2.3.4"
-port = 8080`,
-			expected: map[string]any{
+			name: "unterminated quoted segment",
+			input: `["server]
+name = "web"`,
+			wantErr:  true,
+			errormsg: errInvalidTableHeader + " [missing closing bracket]",
+		},
+		{
+			name: "quoted segment followed by text",
+			input: `["server"x]
+name = "web"`,
+			wantErr:  true,
+			errormsg: errInvalidTableName,
+		},
+		{
+			name: "comment-only table line",
+			input: `[server] # comment
+# another comment
+name = "web"`,
+			expected: map[string]any{
+				"server": map[string]any{
+					"name": "web",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "quoted strings in comments",
+			input: `[server] # don't "parse" this
+name = "web" # or "this" one`,
+			expected: map[string]any{
+				"server": map[string]any{
+					"name": "web",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tables with empty lines and comments",
+			input: `
+# header comment
+[server]
+
+# mid comment
+name = "web"
+
+# another comment
+
+[database]
+host = "localhost"
+`,
+			expected: map[string]any{
+				"server": map[string]any{
+					"name": "web",
+				},
+				"database": map[string]any{
+					"host": "localhost",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "basic dotted table",
+			input: `[server.network]
+ip = "1.2.3.4"
+port = 8080`,
+			expected: map[string]any{
 				"server": map[string]any{
 					"network": map[string]any{
 						"ip":   "1.2.3.4",
@@ -480,6 +936,92 @@ ip = "1.2.3.4"`,
 			wantErr:  true,
 			errormsg: errInvalidTableName,
 		},
+		{
+			name:  "deep dotted key at root",
+			input: `a.b.c.d = 1`,
+			expected: map[string]any{
+				"a": map[string]any{"b": map[string]any{"c": map[string]any{"d": int64(1)}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "deep dotted key merged with later header",
+			input: `a.b.c.d = 1
+a.b.e = "sibling"
+
+[a]
+x = 2
+
+[a.b.c]
+f = true`,
+			expected: map[string]any{
+				"a": map[string]any{
+					"x": int64(2),
+					"b": map[string]any{
+						"e": "sibling",
+						"c": map[string]any{"d": int64(1), "f": true},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "dotted keys in consecutive tables stay relative",
+			input: `[x]
+a.b = 1
+
+[y]
+a.b = 2`,
+			expected: map[string]any{
+				"x": map[string]any{"a": map[string]any{"b": int64(1)}},
+				"y": map[string]any{"a": map[string]any{"b": int64(2)}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "dotted key through a value",
+			input: `a.b = 1
+a.b.c = 2`,
+			wantErr:  true,
+			errormsg: "cannot redefine key as a table [key, a.b] [line 2]",
+		},
+		{
+			name: "header through a value",
+			input: `a.b = 1
+
+[a.b.c]`,
+			wantErr:  true,
+			errormsg: "cannot redefine key as a table [key, a.b] [line 3]",
+		},
+		{
+			name: "header redefines a dotted key value",
+			input: `a.b = 1
+
+[a.b]
+c = 2`,
+			wantErr:  true,
+			errormsg: "cannot redefine key as a table [key, a.b] [line 3]",
+		},
+		{
+			name: "value redefines a dotted key table",
+			input: `a.b.c = 1
+a.b = 2`,
+			wantErr:  true,
+			errormsg: "cannot redefine table as a value [key, a.b, line 2]",
+		},
+		{
+			name: "value redefines a table header",
+			input: `[server]
+tls.enabled = true
+
+[server.tls.ca]
+path = "ca.pem"
+
+[server]
+tls = "off"`,
+			wantErr:  true,
+			errormsg: "cannot redefine table as a value [key, server.tls, line 8]",
+		},
 		{
 			name: "out of order table definition",
 			input: `[server.network.ssl]
@@ -503,6 +1045,71 @@ ip = "1.2.3.4"`,
 			},
 			wantErr: false,
 		},
+		{
+			name: "array of tables",
+			input: `title = "fleet"
+
+[[servers]] # first
+name = "alpha"
+tls.enabled = false
+
+[[servers]]
+name = "beta"
+
+[servers.tls]
+enabled = true
+
+[[servers.ports]]
+port = 443
+
+[[db."read replicas"]]
+host = "a"`,
+			expected: map[string]any{
+				"title": "fleet",
+				"servers": []any{
+					map[string]any{"name": "alpha", "tls": map[string]any{"enabled": false}},
+					map[string]any{
+						"name":  "beta",
+						"tls":   map[string]any{"enabled": true},
+						"ports": []any{map[string]any{"port": int64(443)}},
+					},
+				},
+				"db": map[string]any{
+					"read replicas": []any{map[string]any{"host": "a"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "array of tables over a table",
+			input:    "[servers]\nname = \"a\"\n[[servers]]",
+			wantErr:  true,
+			errormsg: "cannot redefine key as an array of tables [key, servers, line 3]",
+		},
+		{
+			name:     "array of tables over a value",
+			input:    "servers = [1, 2]\n[[servers]]",
+			wantErr:  true,
+			errormsg: "cannot redefine key as an array of tables [key, servers, line 2]",
+		},
+		{
+			name:     "table over an array of tables",
+			input:    "[[servers]]\nname = \"a\"\n[servers]",
+			wantErr:  true,
+			errormsg: "cannot redefine key as a table [key, servers, line 3]",
+		},
+		{
+			name:     "value over an array of tables",
+			input:    "[[servers]]\nname = \"a\"\n[root]\n[[root.x]]\n[root]\nx = 1",
+			wantErr:  true,
+			errormsg: "cannot redefine table as a value [key, root.x, line 6]",
+		},
+		{
+			name:     "unclosed array of tables header",
+			input:    "[[servers]",
+			wantErr:  true,
+			errormsg: errInvalidTableHeader,
+		},
 	}
 
 	for _, tt := range tests {
@@ -532,3 +1139,852 @@ ip = "1.2.3.4"`,
 		})
 	}
 }
+
+func TestUnmarshalMultilineStrings(t *testing.T) {
+	const quickFox = "The quick brown fox jumps over the lazy dog."
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:     "newline after the delimiter is trimmed",
+			input:    "str = \"\"\"\nRoses are red\nViolets are blue\"\"\"",
+			expected: "Roses are red\nViolets are blue",
+		},
+		{
+			name:     "line-ending backslash with blank lines",
+			input:    "str = \"\"\"\nThe quick brown \\\n\n\n  fox jumps over \\\n    the lazy dog.\"\"\"",
+			expected: quickFox,
+		},
+		{
+			name:     "line-ending backslash on every line",
+			input:    "str = \"\"\"\\\n       The quick brown \\\n       fox jumps over \\\n       the lazy dog.\\\n       \"\"\"",
+			expected: quickFox,
+		},
+		{
+			name:     "backslash followed by trailing spaces",
+			input:    "str = \"\"\"a \\   \n   b\"\"\"",
+			expected: "a b",
+		},
+		{
+			name:     "indentation is preserved without a backslash",
+			input:    "str = \"\"\"\n  indented\n\tand tabbed\n\"\"\"",
+			expected: "  indented\n\tand tabbed\n",
+		},
+		{
+			name:     "escaped backslash at line end is kept",
+			input:    "str = \"\"\"C:\\\\\nnext\"\"\"",
+			expected: "C:\\\nnext",
+		},
+		{
+			name:     "two quotes inside",
+			input:    `str = """Here are two quotation marks: "". Simple enough."""`,
+			expected: `Here are two quotation marks: "". Simple enough.`,
+		},
+		{
+			name:     "escaped third quote",
+			input:    `str = """Here are three quotation marks: ""\"."""`,
+			expected: `Here are three quotation marks: """.`,
+		},
+		{
+			name:     "escaped quotes throughout",
+			input:    `str = """Here are fifteen quotation marks: ""\"""\"""\"""\"""\"."""`,
+			expected: `Here are fifteen quotation marks: """"""""""""""".`,
+		},
+		{
+			name:     "quotes next to the delimiters",
+			input:    `str = """"This," she said, "is just a pointless statement.""""`,
+			expected: `"This," she said, "is just a pointless statement."`,
+		},
+		{
+			name:     "comment characters and CRLF line endings",
+			input:    "str = \"\"\"\r\n# not a comment\r\nend\"\"\" # comment",
+			expected: "# not a comment\nend",
+		},
+		{
+			name:     "unterminated",
+			input:    "str = \"\"\"\nnever closed",
+			wantErr:  true,
+			errormsg: errUnterminatedString,
+		},
+		{
+			name:     "invalid escape",
+			input:    "str = \"\"\"\nbad \\q\n\"\"\"",
+			wantErr:  true,
+			errormsg: "invalid escape sequence",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Str string `toml:"str"`
+			}
+			err := Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got.Str != tt.expected {
+				t.Errorf("Unmarshal() = %q, want %q", got.Str, tt.expected)
+			}
+		})
+	}
+
+	// Keys after a multi-line string and multi-line strings in arrays
+	input := "a = \"\"\"\nfirst\nsecond\"\"\"\nb = [\n  \"\"\"x\ny\"\"\",\n  \"z\",\n]\nc = 1"
+	var doc map[string]any
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := map[string]any{"a": "first\nsecond", "b": []any{"x\ny", "z"}, "c": int64(1)}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", doc, want)
+	}
+}
+
+func TestUnmarshalExponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:  "signed exponents",
+			input: "a = 1e+10\nb = 2E-3\nc = -1.5e+2\nd = +5e3",
+			want:  map[string]any{"a": 1e10, "b": 2e-3, "c": -150.0, "d": 5000.0},
+		},
+		{
+			name:  "signed exponents in arrays",
+			input: "vals = [1e+3, 2e-3, -4.5E+1, 6e2]",
+			want:  map[string]any{"vals": []any{1e3, 2e-3, -45.0, 600.0}},
+		},
+		{
+			name:  "exponents in nested arrays",
+			input: "grid = [[1e+1, 2e-1], [3E2]]",
+			want:  map[string]any{"grid": []any{[]any{10.0, 0.2}, []any{300.0}}},
+		},
+		{
+			name:    "exponent without digits",
+			input:   "a = 1e+",
+			wantErr: errInvalidFloat,
+		},
+		{
+			name:    "exponent without mantissa fraction",
+			input:   "a = 1.e5",
+			wantErr: errInvalidFloat,
+		},
+		{
+			name:    "array exponent without digits",
+			input:   "vals = [1e3, 2e-]",
+			wantErr: errInvalidValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			err := Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSignedNumbers(t *testing.T) {
+	type Prices struct {
+		Price    float64   `toml:"price"`
+		Discount float64   `toml:"discount"`
+		Ratio    float32   `toml:"ratio"`
+		Count    int       `toml:"count"`
+		Offset   int8      `toml:"offset"`
+		Size     uint16    `toml:"size"`
+		Deltas   []float64 `toml:"deltas"`
+		Steps    []int     `toml:"steps"`
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Prices
+		wantErr string
+	}{
+		{
+			name: "signed values",
+			input: `price = +19.99
+discount = -0.5
+ratio = +1.25
+count = +42
+offset = -128
+size = +65535
+deltas = [+1.5, -2.5, 3.0]
+steps = [+1, -2, 3]`,
+			want: Prices{
+				Price: 19.99, Discount: -0.5, Ratio: 1.25, Count: 42, Offset: -128, Size: 65535,
+				Deltas: []float64{1.5, -2.5, 3}, Steps: []int{1, -2, 3},
+			},
+		},
+		{
+			name:  "signed integers into float fields",
+			input: "price = +19\ndiscount = -3",
+			want:  Prices{Price: 19, Discount: -3},
+		},
+		{
+			name:    "signed float into integer field",
+			input:   "count = +1.5",
+			wantErr: errInvalidInteger + " [fractional value 1.5, type, int]",
+		},
+		{
+			name:    "negative value into unsigned field",
+			input:   "size = -1",
+			wantErr: "size",
+		},
+		{
+			name:    "double sign",
+			input:   "price = +-1.5",
+			wantErr: errInvalidValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Prices
+			err := Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalDatetime(t *testing.T) {
+	type Schedule struct {
+		Start  time.Time   `toml:"start"`
+		Events []time.Time `toml:"events"`
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected Schedule
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:  "utc datetime",
+			input: `start = 2023-01-01T00:00:00Z`,
+			expected: Schedule{
+				Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: false,
+		},
+		{
+			name:  "lowercase separators",
+			input: `start = 2023-01-01t08:15:00z`,
+			expected: Schedule{
+				Start: time.Date(2023, 1, 1, 8, 15, 0, 0, time.UTC),
+			},
+			wantErr: false,
+		},
+		{
+			name: "mixed offset datetime array",
+			input: `start = 2023-01-01T00:00:00-05:00
+events = [2023-01-01T00:00:00Z, 2023-06-01T12:30:00.5+02:00]`,
+			expected: Schedule{
+				Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.FixedZone("", -5*60*60)),
+				Events: []time.Time{
+					time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					time.Date(2023, 6, 1, 12, 30, 0, 500000000, time.FixedZone("", 2*60*60)),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "local datetime",
+			input:    `start = 2023-01-01T00:00:00`,
+			wantErr:  true,
+			errormsg: errInvalidDatetime,
+		},
+		{
+			name:     "invalid datetime in array",
+			input:    `events = [2023-13-01T00:00:00Z]`,
+			wantErr:  true,
+			errormsg: errInvalidDatetime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Schedule
+			err := Unmarshal([]byte(tt.input), &got)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Unmarshal() error = nil, wantErr %v", tt.wantErr)
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unmarshal() error = %v", err)
+				return
+			}
+
+			if !got.Start.Equal(tt.expected.Start) || len(got.Events) != len(tt.expected.Events) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+				return
+			}
+			for i := range got.Events {
+				if !got.Events[i].Equal(tt.expected.Events[i]) {
+					t.Errorf("Unmarshal() = %v, want %v", got, tt.expected)
+				}
+			}
+
+			// Roundtrip must keep every instant and offset
+			output, err := Marshal(got)
+			if err != nil {
+				t.Errorf("Marshal() error = %v", err)
+				return
+			}
+			var again Schedule
+			if err := Unmarshal(output, &again); err != nil {
+				t.Errorf("Unmarshal() roundtrip error = %v", err)
+				return
+			}
+			if again.Start.Format(time.RFC3339Nano) != got.Start.Format(time.RFC3339Nano) {
+				t.Errorf("roundtrip = %v, want %v", again.Start, got.Start)
+			}
+		})
+	}
+}
+
+func TestUnmarshalIntegerBases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     map[string]any
+		wantErr  bool
+		errormsg string
+	}{
+		{
+			name:  "hexadecimal",
+			input: "value = 0xDEADbeef",
+			want:  map[string]any{"value": int64(0xdeadbeef)},
+		},
+		{
+			name:  "negative hexadecimal",
+			input: "value = -0x10",
+			want:  map[string]any{"value": int64(-16)},
+		},
+		{
+			name:  "octal",
+			input: "value = 0o755",
+			want:  map[string]any{"value": int64(0o755)},
+		},
+		{
+			name:  "binary",
+			input: "value = +0b1101",
+			want:  map[string]any{"value": int64(13)},
+		},
+		{
+			name:  "prefixed integers in array",
+			input: "values = [0x1F, -0o17, 0b11, 42]",
+			want:  map[string]any{"values": []any{int64(31), int64(-15), int64(3), int64(42)}},
+		},
+		{
+			name:  "decimal boundaries",
+			input: "max = 9223372036854775807\nmin = -9223372036854775808",
+			want:  map[string]any{"max": int64(math.MaxInt64), "min": int64(math.MinInt64)},
+		},
+		{
+			name:  "hexadecimal boundaries",
+			input: "max = 0x7fffffffffffffff\nmin = -0x8000000000000000",
+			want:  map[string]any{"max": int64(math.MaxInt64), "min": int64(math.MinInt64)},
+		},
+		{
+			name:  "octal boundaries",
+			input: "max = 0o777777777777777777777\nmin = -0o1000000000000000000000",
+			want:  map[string]any{"max": int64(math.MaxInt64), "min": int64(math.MinInt64)},
+		},
+		{
+			name:  "binary boundaries",
+			input: "max = 0b" + strings.Repeat("1", 63) + "\nmin = -0b1" + strings.Repeat("0", 63),
+			want:  map[string]any{"max": int64(math.MaxInt64), "min": int64(math.MinInt64)},
+		},
+		{
+			name:     "decimal overflow",
+			input:    "value = 9223372036854775808",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "decimal underflow",
+			input:    "value = -9223372036854775809",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "hexadecimal overflow",
+			input:    "value = 0x8000000000000000",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "octal overflow",
+			input:    "value = 0o1000000000000000000000",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "binary underflow",
+			input:    "value = -0b1" + strings.Repeat("0", 62) + "1",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "overflow in array",
+			input:    "values = [1, 0xffffffffffffffffff]",
+			wantErr:  true,
+			errormsg: errIntegerOverflow,
+		},
+		{
+			name:     "invalid hexadecimal digit",
+			input:    "value = 0xZZ",
+			wantErr:  true,
+			errormsg: errInvalidInteger,
+		},
+		{
+			name:     "invalid binary digit",
+			input:    "value = 0b102",
+			wantErr:  true,
+			errormsg: errInvalidInteger,
+		},
+		{
+			name:     "missing digits",
+			input:    "value = 0x",
+			wantErr:  true,
+			errormsg: errInvalidInteger,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			err := Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errormsg) {
+					t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.errormsg)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalFinalLine(t *testing.T) {
+	want := map[string]any{
+		"name":  "value",
+		"ports": []any{int64(80), int64(443)},
+	}
+
+	base := "name = \"value\"\nports = [\n    80,\n    443,\n]"
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "no trailing newline", input: base},
+		{name: "trailing newline", input: base + "\n"},
+		{name: "several trailing newlines", input: base + "\n\n\n"},
+		{name: "windows line endings", input: strings.ReplaceAll(base, "\n", "\r\n")},
+		{name: "windows line endings with trailing newline", input: strings.ReplaceAll(base, "\n", "\r\n") + "\r\n"},
+		{name: "final comment without newline", input: base + "\n# done"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			if err := Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Errorf("Unmarshal() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// A key-value pair that is the last byte of the input must not be dropped
+	var got map[string]any
+	if err := Unmarshal([]byte(`key = "value"`), &got); err != nil || got["key"] != "value" {
+		t.Errorf("Unmarshal() = %v, %v, want key = value", got, err)
+	}
+}
+
+func TestUnmarshalPath(t *testing.T) {
+	type Pool struct {
+		MaxOpen int `toml:"max_open"`
+	}
+	type DBConfig struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+		Pool Pool   `toml:"pool"`
+	}
+
+	input := `name = "app"
+
+[server]
+host = "example.com"
+
+[database]
+host = "localhost"
+port = 5432
+
+[database.pool]
+max_open = 10
+
+[labels."eu.west"]
+zone = "a"`
+
+	var db DBConfig
+	if err := UnmarshalPath([]byte(input), "database", &db); err != nil {
+		t.Fatalf("UnmarshalPath() error = %v", err)
+	}
+	if want := (DBConfig{Host: "localhost", Port: 5432, Pool: Pool{MaxOpen: 10}}); db != want {
+		t.Errorf("UnmarshalPath() = %+v, want %+v", db, want)
+	}
+
+	var pool Pool
+	if err := UnmarshalPath([]byte(input), "database.pool", &pool); err != nil || pool.MaxOpen != 10 {
+		t.Errorf("UnmarshalPath() = %+v, %v, want max_open 10", pool, err)
+	}
+
+	var zone map[string]any
+	if err := UnmarshalPath([]byte(input), `labels."eu.west"`, &zone); err != nil || zone["zone"] != "a" {
+		t.Errorf("UnmarshalPath() = %v, %v, want zone a", zone, err)
+	}
+
+	var host string
+	if err := UnmarshalPath([]byte(input), "server.host", &host); err != nil || host != "example.com" {
+		t.Errorf("UnmarshalPath() = %q, %v, want example.com", host, err)
+	}
+
+	errTests := []struct {
+		name     string
+		path     string
+		errormsg string
+	}{
+		{name: "missing table", path: "cache", errormsg: errMissingKey + " [path, cache]"},
+		{name: "missing nested table", path: "database.replica", errormsg: errMissingKey},
+		{name: "path through a value", path: "name.first", errormsg: errMissingKey},
+		{name: "invalid path", path: "database..pool", errormsg: errInvalidTableName},
+	}
+
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			err := UnmarshalPath([]byte(input), tt.path, &got)
+			if err == nil || !strings.Contains(err.Error(), tt.errormsg) {
+				t.Errorf("UnmarshalPath() error = %v, want error containing %v", err, tt.errormsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTargets(t *testing.T) {
+	input := []byte("name = \"app\"\nport = 8080")
+
+	var config struct {
+		Name string `toml:"name"`
+	}
+	var doc map[string]any
+	var values map[string]int64
+	var iface any
+	var ptr *map[string]any
+
+	for _, target := range []any{&config, &doc, &iface, &ptr} {
+		if err := Unmarshal(input, target); err != nil {
+			t.Errorf("Unmarshal(%T) error = %v", target, err)
+		}
+	}
+	if config.Name != "app" || doc["port"] != int64(8080) || ptr == nil || (*ptr)["name"] != "app" {
+		t.Errorf("Unmarshal() = %+v, %v, %v", config, doc, ptr)
+	}
+	if got, ok := iface.(map[string]any); !ok || got["name"] != "app" {
+		t.Errorf("Unmarshal() into interface = %#v, want map[string]any", iface)
+	}
+
+	var n int
+	var s []string
+	var keyed map[int]any
+	var when time.Time
+	tests := []struct {
+		name    string
+		target  any
+		wantErr string
+	}{
+		{name: "pointer to int", target: &n, wantErr: "[got int, want a pointer to a struct, map or interface]"},
+		{name: "pointer to slice", target: &s, wantErr: "[got []string, "},
+		{name: "map with int keys", target: &keyed, wantErr: "[map key int, want string keys]"},
+		{name: "time.Time", target: &when, wantErr: "[got time.Time, "},
+		{name: "string map with other values", target: &values, wantErr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal(input, tt.target)
+			if tt.wantErr == "" {
+				// The target kind is fine, so any error comes from the values
+				if err != nil && strings.Contains(err.Error(), errInvalidTarget) {
+					t.Errorf("Unmarshal() error = %v, want no target error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), errInvalidTarget) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPointerFields(t *testing.T) {
+	type SubConfig struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	}
+	type Common struct {
+		Name string `toml:"name"`
+	}
+	type Config struct {
+		Common   *Common    `toml:",inline"`
+		Database *SubConfig `toml:"database"`
+		Cache    *SubConfig `toml:"cache"`
+		Limit    *int       `toml:"limit"`
+		Unset    *int       `toml:"unset"`
+	}
+
+	input := `name = "app"
+limit = 10
+
+[database]
+host = "localhost"
+port = 5432`
+
+	// A nil *Config reached through its address is allocated, as are pointer
+	// fields whose table or key exists; the others stay nil
+	var cfg *Config
+	if err := Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	limit := 10
+	want := &Config{
+		Common:   &Common{Name: "app"},
+		Database: &SubConfig{Host: "localhost", Port: 5432},
+		Limit:    &limit,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", cfg, want)
+	}
+
+	// The nil pointer itself has nowhere to store the result
+	var nilCfg *Config
+	err := Unmarshal([]byte(input), nilCfg)
+	if err == nil || !strings.Contains(err.Error(), errInvalidTarget) || !strings.Contains(err.Error(), "&v") {
+		t.Errorf("Unmarshal() error = %v, want error containing %v and a hint", err, errInvalidTarget)
+	}
+
+	err = Unmarshal([]byte(input), Config{})
+	if err == nil || !strings.Contains(err.Error(), "want a pointer") {
+		t.Errorf("Unmarshal() error = %v, want error containing %v", err, "want a pointer")
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	type Server struct {
+		Name string `toml:"name"`
+	}
+	type Config struct {
+		Name     string `toml:"name"`
+		Region   string
+		Ports    []int64        `toml:"ports"`
+		Labels   map[string]any `toml:"labels"`
+		Servers  []Server       `toml:"servers"`
+		Database struct {
+			Host string `toml:"host"`
+		} `toml:"database"`
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "valid document",
+			input: "name = \"app\"\nports = [80, 443]\n[labels]\nanything = 1\n[database]\nhost = \"db\"\n[[servers]]\nname = \"a\"\n[[servers]]\nname = \"b\"",
+		},
+		{name: "keys matched as decode matches them", input: "NAME = \"app\"\nregion = \"eu\""},
+		{name: "duplicate key", input: "name = \"a\"\nname = \"b\"", wantErr: errDuplicateKey + " [key, name, line 2]"},
+		{name: "duplicate dotted key", input: "database.host = \"a\"\n[database]\nhost = \"b\"", wantErr: errDuplicateKey + " [key, database.host, line 3]"},
+		{name: "duplicate table", input: "[database]\nhost = \"a\"\n[database]", wantErr: errDuplicateTable + " [table, database, line 3]"},
+		{name: "unknown key", input: "name = \"app\"\nnmae = \"typo\"", wantErr: errUnknownKey + " [key, nmae]"},
+		{name: "unknown nested key", input: "[database]\nhots = \"db\"", wantErr: errUnknownKey + " [key, database.hots]"},
+		{name: "unknown key in array of tables", input: "[[servers]]\nname = \"a\"\n[[servers]]\nnmae = \"b\"", wantErr: errUnknownKey + " [key, servers[1].nmae]"},
+		{name: "mixed array", input: "ports = [80, \"443\"]", wantErr: errMixedArray + " [element 1 is string, element 0 is integer]"},
+		{name: "integer and float", input: "ports = [80, 1.5]", wantErr: errMixedArray},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			err := UnmarshalStrict([]byte(tt.input), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("UnmarshalStrict() error = %v, want error containing %v", err, tt.wantErr)
+				}
+				// The same input is accepted without the strict checks
+				if err := Unmarshal([]byte(tt.input), &map[string]any{}); err != nil {
+					t.Errorf("Unmarshal() error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("UnmarshalStrict() error = %v", err)
+			}
+		})
+	}
+
+	// Map targets accept any key
+	var m map[string]any
+	if err := UnmarshalStrict([]byte("anything = [[1], [\"a\"]]"), &m); err != nil {
+		t.Errorf("UnmarshalStrict() error = %v", err)
+	}
+
+	// Recursive types are checked as deep as the document goes
+	type Tree struct {
+		Name string `toml:"name"`
+		Left *Tree  `toml:"left"`
+	}
+	var tree Tree
+	if err := UnmarshalStrict([]byte("[left.left]\nnmae = \"leaf\""), &tree); err == nil || !strings.Contains(err.Error(), errUnknownKey+" [key, left.left.nmae]") {
+		t.Errorf("UnmarshalStrict() error = %v, want unknown key left.left.nmae", err)
+	}
+}
+
+func TestUnmarshalExpectedTable(t *testing.T) {
+	type Pool struct {
+		MaxOpen int `toml:"max_open"`
+	}
+	type Config struct {
+		Name     string `toml:"name"`
+		Database struct {
+			Host string `toml:"host"`
+			Pool Pool   `toml:"pool"`
+		} `toml:"database"`
+		Cache  *Pool             `toml:"cache"`
+		Labels map[string]string `toml:"labels"`
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "string for nested struct", input: "[database]\nhost = \"db\"\npool = \"oops\"", wantErr: "expected table [key, database.pool, got string, line 3]"},
+		{name: "dotted key", input: "name = \"app\"\ndatabase.pool = 5", wantErr: "expected table [key, database.pool, got integer, line 2]"},
+		{name: "parent table", input: "database = true", wantErr: "expected table [key, database, got boolean, line 1]"},
+		{name: "pointer struct", input: "cache = [1, 2]", wantErr: "expected table [key, cache, got array, line 1]"},
+		{name: "map field", input: "labels = \"x\"", wantErr: "expected table [key, labels, got string, line 1]"},
+		{name: "other errors unchanged", input: "name = 1\n[database]\nhost = \"db\"", wantErr: "error decoding 'name'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			err := Unmarshal([]byte(tt.input), &got)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Unmarshal() error = %v, want error containing %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Recursive types fail with the type error, or the table their field expects
+	type Tree struct {
+		Name string `toml:"name"`
+		Left *Tree  `toml:"left"`
+	}
+	if err := Unmarshal([]byte("name = 1"), &Tree{}); err == nil || !strings.Contains(err.Error(), "error decoding 'name'") {
+		t.Errorf("Unmarshal() error = %v, want error decoding 'name'", err)
+	}
+	if err := Unmarshal([]byte("[left]\nleft = 1"), &Tree{}); err == nil || !strings.Contains(err.Error(), "expected table [key, left.left, got integer, line 2]") {
+		t.Errorf("Unmarshal() error = %v, want expected table left.left", err)
+	}
+}
+
+func TestUnmarshalEmptyArrays(t *testing.T) {
+	type Config struct {
+		Ints    []int    `toml:"ints"`
+		Strings []string `toml:"strings"`
+		Nested  [][]int  `toml:"nested"`
+		Any     any      `toml:"any"`
+	}
+
+	var got Config
+	if err := Unmarshal([]byte("ints = []\nstrings = [ ]\nnested = [[], [1]]\nany = []"), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := Config{Ints: []int{}, Strings: []string{}, Nested: [][]int{{}, {1}}, Any: []any{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", got, want)
+	}
+
+	// Untyped targets hold an empty, non-nil []any as well
+	var doc map[string]any
+	if err := Unmarshal([]byte("empty = []\nmultiline = [\n]"), &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for _, key := range []string{"empty", "multiline"} {
+		if arr, ok := doc[key].([]any); !ok || arr == nil || len(arr) != 0 {
+			t.Errorf("Unmarshal() %s = %#v, want []any{}", key, doc[key])
+		}
+	}
+}
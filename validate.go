@@ -0,0 +1,339 @@
+// Package tinytoml provides a simplified TOML encoder and decoder
+package tinytoml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ValidateAgainst checks a TOML document against the struct type of v without
+// populating v, and reports every problem at once instead of failing on the first:
+// values that do not decode into their field's type, keys that no field maps to,
+// and keys of fields tagged toml:"name,required" that are missing. Keys are
+// matched as Unmarshal matches them, and the required fields of a pointer to a
+// struct are only checked when its table is present.
+// A document that does not parse is reported as the parse error alone.
+// The problems are returned as a *ValidationError.
+func ValidateAgainst(data []byte, v any) error {
+	if _, err := Describe(v); err != nil {
+		return errorf(err)
+	}
+
+	d := &Decoder{}
+	doc, err := d.parse(data)
+	if err != nil {
+		return errorf(err)
+	}
+
+	var problems []error
+
+	// Type mismatches, found by decoding into a scratch value of the same type
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if err := d.decode(doc, reflect.New(t).Interface()); err != nil {
+		var decodeErr *mapstructure.Error
+		if errors.As(err, &decodeErr) {
+			for _, msg := range decodeErr.Errors {
+				problems = append(problems, errors.New(msg))
+			}
+		} else {
+			problems = append(problems, err)
+		}
+	}
+
+	// Unknown and missing keys, from the metadata of a decode that skips the
+	// mismatched values, as mapstructure records none for a table that failed
+	d.lenient = true
+	var md mapstructure.Metadata
+	if err := d.decodeMetadata(doc, reflect.New(t).Interface(), &md); err != nil {
+		return errorf(err)
+	}
+	problems = append(problems, unknownKeyErrors(md.Unused)...)
+
+	var missing []string
+	for _, path := range md.Unset {
+		missing = append(missing, unsetRequired(t, path, nil)...)
+	}
+	slices.Sort(missing)
+	for _, key := range missing {
+		problems = append(problems, errorf(fmt.Errorf(errMissingRequired), "key", key))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Errors: problems}
+	}
+	return nil
+}
+
+// lenientHooks wraps decode hooks for ValidateAgainst: a value that fails a hook,
+// or that mapstructure could not store into its target, decodes as the target's
+// zero value instead, so every table decodes and has its keys recorded
+func lenientHooks(hooks []mapstructure.DecodeHookFunc) []mapstructure.DecodeHookFunc {
+	wrapped := make([]mapstructure.DecodeHookFunc, 0, len(hooks)+1)
+	for _, hook := range hooks {
+		wrapped = append(wrapped, func(from, to reflect.Value) (any, error) {
+			data, err := mapstructure.DecodeHookExec(hook, from, to)
+			if err != nil {
+				return lenientZero(from, to), nil
+			}
+			return data, nil
+		})
+	}
+	return append(wrapped, storableHook)
+}
+
+// storableHook replaces a value that mapstructure could not store into the target
+// with the target's zero value. Tables and arrays are checked by their shape only,
+// as their content is decoded through the hooks again
+func storableHook(from, to reflect.Value) (any, error) {
+	data := from.Interface()
+	t := to.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch data.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Interface:
+			return data, nil
+		}
+	case []any:
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Interface:
+			return data, nil
+		}
+	default:
+		if mapstructure.Decode(data, reflect.New(t).Interface()) == nil {
+			return data, nil
+		}
+	}
+	return lenientZero(from, to), nil
+}
+
+// lenientZero is the zero value of the target a rejected value is replaced with
+// An interface target keeps the value, as it accepts any
+func lenientZero(from, to reflect.Value) any {
+	if to.Kind() == reflect.Interface {
+		return from.Interface()
+	}
+	return reflect.Zero(to.Type()).Interface()
+}
+
+// unsetRequired returns the keys of required fields that a decode left unset,
+// given the decode path of an unset field (pool.max_open, servers[0].name) from
+// struct type t: the field itself when it is required, and the required fields
+// of a struct value it holds. Pointers to structs that are unset are optional.
+func unsetRequired(t reflect.Type, path string, keys []string) []string {
+	// The longest matching name wins, so server.port is not read as a field
+	// port of a struct named server
+	var field reflect.StructField
+	var tag fieldTag
+	var rest string
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		candidate := t.Field(i)
+		candidateTag := parseTag(candidate)
+		if !candidate.IsExported() || candidateTag.skip || (found && len(candidateTag.key) <= len(tag.key)) {
+			continue
+		}
+		after, ok := strings.CutPrefix(path, candidateTag.key)
+		if ok && (after == "" || after[0] == '.' || after[0] == '[') {
+			field, tag, rest, found = candidate, candidateTag, after, true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	if !tag.has("inline") {
+		keys = append(keys[:len(keys):len(keys)], tag.name)
+	}
+	typ := field.Type
+	if index, after, ok := strings.Cut(rest, "]"); ok && rest[0] == '[' {
+		// An element of a slice or array of tables
+		keys[len(keys)-1] += index + "]"
+		rest, typ = after, typ.Elem()
+	}
+
+	if rest == "" {
+		if tag.has("required") {
+			return []string{strings.Join(keys, ".")}
+		}
+		return requiredFields(typ, keys)
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if rest[0] != '.' || !isStructType(typ) {
+		return nil
+	}
+	return unsetRequired(typ, rest[1:], keys)
+}
+
+// requiredFields returns the keys of the required fields of struct value type t,
+// found under keys, descending into nested struct values but not pointers
+func requiredFields(t reflect.Type, keys []string) []string {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil
+	}
+
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if !field.IsExported() || tag.skip {
+			continue
+		}
+		fieldKeys := keys
+		if !tag.has("inline") {
+			fieldKeys = append(keys[:len(keys):len(keys)], tag.name)
+		}
+		if tag.has("required") {
+			required = append(required, strings.Join(fieldKeys, "."))
+			continue
+		}
+		required = append(required, requiredFields(field.Type, fieldKeys)...)
+	}
+	return required
+}
+
+// unknownKeyErrors reports the unused keys recorded by a decode, in sorted order
+func unknownKeyErrors(unused []string) []error {
+	unused = slices.Sorted(slices.Values(unused))
+	problems := make([]error, len(unused))
+	for i, name := range unused {
+		problems[i] = errorf(fmt.Errorf(errUnknownKey), "key", name)
+	}
+	return problems
+}
+
+// checkTableShapes explains a failed decode of data into the struct target v:
+// it looks for a key of the parsed doc holding a value where v expects a table,
+// such as pool = "oops" for a nested struct field, which mapstructure reports
+// only as a generic type error.
+// Returns nil when no such key is found, leaving the decode error as it is.
+func (d *Decoder) checkTableShapes(data []byte, doc map[string]any, v any) error {
+	t := reflect.TypeOf(v).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !isStructType(t) {
+		return nil
+	}
+
+	name, value, ok := tableShapeMismatch(doc, nil, nil, structTables(t, nil, map[reflect.Type]bool{}))
+	if !ok {
+		return nil
+	}
+	context := []string{"key", name, "got " + typeName(value)}
+	if line, ok := d.keyLine(data, name); ok {
+		context = append(context, fmt.Sprintf("line %d", line))
+	}
+	return errorf(fmt.Errorf(errExpectedTable), context...)
+}
+
+// structTables maps the key paths of the tables a value of struct type t holds,
+// relative to its own table, to their type: a nested struct, a map, or nil for
+// the tables on the way to a dotted name. Inline structs add their tables to the
+// enclosing one; inline holds the types being flattened, so recursive types end.
+func structTables(t reflect.Type, tables map[string]reflect.Type, inline map[reflect.Type]bool) map[string]reflect.Type {
+	if tables == nil {
+		tables = map[string]reflect.Type{}
+	}
+	inline[t] = true
+	defer delete(inline, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if !field.IsExported() || tag.skip {
+			continue
+		}
+		typ := field.Type
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if tag.has("inline") && isStructType(typ) {
+			if !inline[typ] {
+				structTables(typ, tables, inline)
+			}
+			continue
+		}
+
+		segments := strings.Split(tag.name, ".")
+		for i := 1; i < len(segments); i++ {
+			if name := formatPath(segments[:i]); tables[name] == nil {
+				tables[name] = nil
+			}
+		}
+		if typ.Kind() == reflect.Map || isStructType(typ) {
+			tables[formatPath(segments)] = typ
+		}
+	}
+	return tables
+}
+
+// tableShapeMismatch walks a parsed table in sorted key order, along with the
+// tables of the struct it decodes into (keyed relative to the struct's table,
+// which starts at rel), and returns the first key path, and its value, that
+// holds a value where a table is expected
+func tableShapeMismatch(table map[string]any, path, rel []string, tables map[string]reflect.Type) (string, any, bool) {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := append(path[:len(path):len(path)], key)
+		relPath := append(rel[:len(rel):len(rel)], key)
+		typ, isTable := tables[formatPath(relPath)]
+		if !isTable {
+			continue
+		}
+		switch value := table[key].(type) {
+		case map[string]any:
+			var name string
+			var mismatch any
+			var ok bool
+			switch {
+			case typ == nil:
+				name, mismatch, ok = tableShapeMismatch(value, keyPath, relPath, tables)
+			case typ.Kind() == reflect.Struct:
+				// A nested struct, whose keys start over from its own table
+				name, mismatch, ok = tableShapeMismatch(value, keyPath, nil, structTables(typ, nil, map[reflect.Type]bool{}))
+			}
+			if ok {
+				return name, mismatch, true
+			}
+		case nil:
+			// null clears the field, whatever its type
+		default:
+			return formatPath(keyPath), value, true
+		}
+	}
+	return "", nil, false
+}
+
+// keyLine returns the line a key path is assigned on by re-parsing data with
+// line tracking, a cost only worth paying once a decode has failed
+func (d *Decoder) keyLine(data []byte, name string) (int, bool) {
+	warnings := d.warnings
+	d.keyLines = map[string]int{}
+	defer func() { d.keyLines, d.warnings = nil, warnings }()
+
+	if _, err := d.parse(data); err != nil {
+		return 0, false
+	}
+	line, ok := d.keyLines[name]
+	return line, ok
+}
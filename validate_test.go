@@ -0,0 +1,115 @@
+package tinytoml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAgainst(t *testing.T) {
+	type Pool struct {
+		MaxOpen int64 `toml:"max_open,required"`
+	}
+	type Server struct {
+		Name string `toml:"name,required"`
+	}
+	type Config struct {
+		Name    string `toml:"name,required"`
+		Region  string
+		Port    int64          `toml:"server.port"`
+		Debug   bool           `toml:"debug"`
+		Pool    *Pool          `toml:"pool"`
+		Extra   map[string]any `toml:"extra"`
+		Servers []Server       `toml:"servers"`
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr []string
+	}{
+		{
+			name: "valid document",
+			input: `name = "app"
+[server]
+port = 8080
+[pool]
+max_open = 10
+[extra]
+anything = "goes"
+[extra.nested]
+too = 1`,
+		},
+		{
+			name: "all problems reported",
+			input: `debug = "yes"
+color = "red"
+[server]
+port = "8080"
+timeout = 5
+[pool]
+max_idle = 2`,
+			wantErr: []string{
+				"'debug' expected type 'bool'",
+				"'server.port' expected type 'int64'",
+				errUnknownKey + " [key, color]",
+				errUnknownKey + " [key, pool.max_idle]",
+				errUnknownKey + " [key, server.timeout]",
+				errMissingRequired + " [key, name]",
+				errMissingRequired + " [key, pool.max_open]",
+			},
+		},
+		{
+			name:  "keys matched as Unmarshal matches them",
+			input: "NAME = \"app\"\nregion = \"eu\"",
+		},
+		{
+			name:  "array of tables",
+			input: "name = \"app\"\n[[servers]]\nname = \"a\"\n[[servers]]\nnmae = \"b\"",
+			wantErr: []string{
+				errUnknownKey + " [key, servers[1].nmae]",
+				errMissingRequired + " [key, servers[1].name]",
+			},
+		},
+		{
+			name:    "parse error alone",
+			input:   `name = `,
+			wantErr: []string{errMissingValue},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgainst([]byte(tt.input), &Config{})
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateAgainst() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateAgainst() error = nil, want errors containing %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateAgainst() error = %v, want error containing %v", err, want)
+				}
+			}
+		})
+	}
+
+	// Every problem is a separate error and the target is never written to
+	cfg := Config{Name: "kept"}
+	err := ValidateAgainst([]byte("name = 1\nunknown = 2\n[pool]\nmax_open = 1"), &cfg)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 2 {
+		t.Errorf("ValidateAgainst() error = %v, want *ValidationError with 2 problems", err)
+	}
+	if cfg.Name != "kept" {
+		t.Errorf("ValidateAgainst() modified the target: Name = %q", cfg.Name)
+	}
+
+	if err := ValidateAgainst([]byte(`a = 1`), map[string]any{}); err == nil || !strings.Contains(err.Error(), errUnsupported) {
+		t.Errorf("ValidateAgainst() error = %v, want error containing %v", err, errUnsupported)
+	}
+}